@@ -0,0 +1,71 @@
+package otlp
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+// KeyValidator decides whether an API key is allowed to send data.
+// Implementations return nil for a valid key; any other error rejects the request.
+type KeyValidator interface {
+	ValidateKey(ctx context.Context, apiKey string) error
+}
+
+// KeyValidatorFunc adapts an ordinary function to the KeyValidator interface
+type KeyValidatorFunc func(ctx context.Context, apiKey string) error
+
+// ValidateKey calls f(ctx, apiKey)
+func (f KeyValidatorFunc) ValidateKey(ctx context.Context, apiKey string) error {
+	return f(ctx, apiKey)
+}
+
+// AuthMiddleware returns an http.Handler that validates the request's API key
+// before passing the request to next. Requests without a key, or with a key
+// rejected by the validator, receive a 401 response written by WriteOtlpHttpError.
+// CORS preflight requests carry no key and are passed straight to next, so an
+// HTTPHandler's CORS options can answer them.
+func AuthMiddleware(validator KeyValidator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isCORSPreflight(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ri := GetRequestInfoFromHTTPRequest(r)
+		if err := authenticate(r.Context(), validator, ri.ApiKey); err != nil {
+			WriteOtlpHttpError(w, ri, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthUnaryServerInterceptor returns a gRPC unary interceptor that validates the
+// API key from the incoming metadata before invoking the handler. Requests without
+// a key, or with a key rejected by the validator, fail with UNAUTHENTICATED.
+func AuthUnaryServerInterceptor(validator KeyValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ri := GetRequestInfoFromGrpcMetadata(ctx)
+		if err := authenticate(ctx, validator, ri.ApiKey); err != nil {
+			return nil, AsGRPCError(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authenticate always returns an OTLPError or a RetryableError so callers can
+// map it to a response
+func authenticate(ctx context.Context, validator KeyValidator, apiKey string) error {
+	if apiKey == "" {
+		return ErrMissingAPIKeyHeader
+	}
+	if err := validator.ValidateKey(ctx, apiKey); err != nil {
+		switch err := err.(type) {
+		case OTLPError, RetryableError:
+			return err
+		}
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var testKeyValidator = KeyValidatorFunc(func(ctx context.Context, apiKey string) error {
+	if apiKey != "good-key" {
+		return errors.New("nope")
+	}
+	return nil
+})
+
+func TestAuthMiddleware(t *testing.T) {
+	testCases := []struct {
+		name           string
+		header         http.Header
+		expectedStatus int
+		expectedErr    OTLPError
+	}{
+		{name: "missing key", header: http.Header{}, expectedStatus: http.StatusUnauthorized, expectedErr: ErrMissingAPIKeyHeader},
+		{name: "invalid key", header: http.Header{"X-Honeycomb-Team": {"bad-key"}}, expectedStatus: http.StatusUnauthorized, expectedErr: ErrInvalidAPIKey},
+		{name: "invalid key as json", header: http.Header{"X-Honeycomb-Team": {"bad-key"}, "Content-Type": {"application/json"}}, expectedStatus: http.StatusUnauthorized, expectedErr: ErrInvalidAPIKey},
+		{name: "valid key", header: http.Header{"X-Honeycomb-Team": {"good-key"}}, expectedStatus: http.StatusOK},
+		{name: "valid bearer token", header: http.Header{"Authorization": {"Bearer good-key"}}, expectedStatus: http.StatusOK},
+		{name: "non-bearer authorization", header: http.Header{"Authorization": {"Basic good-key"}}, expectedStatus: http.StatusUnauthorized, expectedErr: ErrMissingAPIKeyHeader},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/traces", nil)
+			r.Header = tc.header
+			w := httptest.NewRecorder()
+			AuthMiddleware(testKeyValidator, next).ServeHTTP(w, r)
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedErr.Message == "" {
+				assert.Equal(t, "ok", w.Body.String())
+				return
+			}
+			// errors are a google.rpc.Status in the request's content type
+			decoded := &spb.Status{}
+			if tc.header.Get("Content-Type") == "application/json" {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				require.NoError(t, protojson.Unmarshal(w.Body.Bytes(), decoded))
+			} else {
+				assert.Equal(t, "application/protobuf", w.Header().Get("Content-Type"))
+				require.NoError(t, proto.Unmarshal(w.Body.Bytes(), decoded))
+			}
+			assert.Equal(t, int32(codes.Unauthenticated), decoded.Code)
+			assert.Equal(t, tc.expectedErr.Message, decoded.Message)
+		})
+	}
+}
+
+func TestAuthMiddlewarePassesCORSPreflights(t *testing.T) {
+	handler := NewHTTPHandler(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		return nil
+	})
+	handler.CORS = &CORSOptions{AllowedOrigins: []string{"https://example.com"}}
+	server := AuthMiddleware(testKeyValidator, handler)
+
+	r := httptest.NewRequest(http.MethodOptions, TracesPath, nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// other OPTIONS requests still need a key
+	r = httptest.NewRequest(http.MethodOptions, TracesPath, nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthUnaryServerInterceptor(t *testing.T) {
+	testCases := []struct {
+		name         string
+		md           metadata.MD
+		expectedCode codes.Code
+	}{
+		{name: "missing key", md: metadata.MD{}, expectedCode: codes.Unauthenticated},
+		{name: "invalid key", md: metadata.Pairs(apiKeyHeader, "bad-key"), expectedCode: codes.Unauthenticated},
+		{name: "valid key", md: metadata.Pairs(apiKeyHeader, "good-key"), expectedCode: codes.OK},
+		{name: "valid bearer token", md: metadata.Pairs("authorization", "Bearer good-key"), expectedCode: codes.OK},
+	}
+
+	interceptor := AuthUnaryServerInterceptor(testKeyValidator)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package otlp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBudget bounds the bytes of translated events in flight across requests,
+// so one huge batch or a burst of large ones can't run a small ingest node out of
+// memory. Share one budget between TraceServer, LogsServer and HTTPHandler to bound
+// them together, or reserve from it directly around your own translations.
+type MemoryBudget struct {
+	limit int64
+
+	mu       sync.Mutex
+	used     int64
+	released chan struct{} // closed and replaced whenever bytes are released
+
+	// Wait makes reservations that don't fit wait for bytes to be released until
+	// their context is done, rather than failing straight away
+	Wait bool
+}
+
+// NewMemoryBudget returns a MemoryBudget allowing limit bytes in flight
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit, released: make(chan struct{})}
+}
+
+// Used returns the number of bytes currently reserved
+func (b *MemoryBudget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Reserve takes n bytes from the budget. Reservations bigger than the whole budget
+// fail with ErrRequestTooLarge. Others that don't fit fail with NewThrottledError,
+// or wait if Wait is set. Give the bytes back with Release.
+func (b *MemoryBudget) Reserve(ctx context.Context, n int64) error {
+	if n > b.limit {
+		return ErrRequestTooLarge
+	}
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		released := b.released
+		b.mu.Unlock()
+
+		if !b.Wait {
+			return NewThrottledError(time.Second)
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release returns n reserved bytes to the budget
+func (b *MemoryBudget) Release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// grow takes n more bytes without waiting, for memory that's already allocated
+func (b *MemoryBudget) grow(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used += n
+}
+
+// memoryReservation tracks a request's share of a budget. It's safe to use with a
+// nil budget, where it does nothing.
+type memoryReservation struct {
+	budget *MemoryBudget
+	n      int64
+}
+
+// reserveMemory reserves estimate bytes of budget for a request, before it's translated
+func reserveMemory(ctx context.Context, budget *MemoryBudget, estimate int64) (*memoryReservation, error) {
+	if budget == nil {
+		return &memoryReservation{}, nil
+	}
+	if err := budget.Reserve(ctx, estimate); err != nil {
+		return nil, err
+	}
+	return &memoryReservation{budget: budget, n: estimate}, nil
+}
+
+// resize replaces the estimate with the size of the translated events
+func (r *memoryReservation) resize(result *TranslateOTLPRequestResult) {
+	if r.budget == nil || result == nil {
+		return
+	}
+	var n int64
+	for _, batch := range result.Batches {
+		for _, event := range batch.Events {
+			n += int64(event.SizeBytes())
+		}
+	}
+	if n > r.n {
+		r.budget.grow(n - r.n)
+	} else if n < r.n {
+		r.budget.Release(r.n - n)
+	}
+	r.n = n
+}
+
+func (r *memoryReservation) release() {
+	if r.budget != nil {
+		r.budget.Release(r.n)
+		r.n = 0
+	}
+}
@@ -0,0 +1,79 @@
+package otlp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	budget := NewMemoryBudget(100)
+	require.NoError(t, budget.Reserve(context.Background(), 60))
+	assert.Equal(t, int64(60), budget.Used())
+
+	assert.Equal(t, ErrRequestTooLarge, budget.Reserve(context.Background(), 101))
+	assert.Equal(t, NewThrottledError(time.Second), budget.Reserve(context.Background(), 50))
+
+	// waiting reservations go through once enough is released
+	budget.Wait = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, budget.Reserve(ctx, 50))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		budget.Release(60)
+	}()
+	require.NoError(t, budget.Reserve(context.Background(), 50))
+	assert.Equal(t, int64(50), budget.Used())
+}
+
+func TestMemoryReservation(t *testing.T) {
+	budget := NewMemoryBudget(1000)
+	reservation, err := reserveMemory(context.Background(), budget, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), budget.Used())
+
+	event := Event{Attributes: map[string]interface{}{"name": "span"}}
+	reservation.resize(&TranslateOTLPRequestResult{Batches: []Batch{{Events: []Event{event, event}}}})
+	assert.Equal(t, int64(2*event.SizeBytes()), budget.Used())
+	reservation.release()
+	assert.Equal(t, int64(0), budget.Used())
+
+	// nil budgets don't track anything
+	reservation, err = reserveMemory(context.Background(), nil, 10)
+	require.NoError(t, err)
+	reservation.resize(&TranslateOTLPRequestResult{})
+	reservation.release()
+}
+
+func TestTraceServerMemoryBudget(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		apiKeyHeader: "abc123DEF456ghi789jklm",
+	}))
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "span"})
+
+	budget := NewMemoryBudget(10000)
+	server := NewTraceServer(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		// the translated events are held until the handler returns
+		assert.Equal(t, int64(result.Batches[0].Events[0].SizeBytes()), budget.Used())
+		return nil
+	})
+	server.MemoryBudget = budget
+	_, err := server.Export(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), budget.Used())
+
+	server.MemoryBudget = NewMemoryBudget(10)
+	_, err = server.Export(ctx, &collectortrace.ExportTraceServiceRequest{ResourceSpans: req.ResourceSpans})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
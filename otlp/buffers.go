@@ -0,0 +1,103 @@
+package otlp
+
+import (
+	"bytes"
+	"sync"
+)
+
+// bodyBufferTiers are the capacities of pooled request body buffers. Bodies are
+// read into a buffer from the smallest tier that fits their Content-Length, so
+// small requests don't hold on to large buffers.
+var bodyBufferTiers = [...]int{64 << 10, 1 << 20, 8 << 20}
+
+var bodyBufferPools [len(bodyBufferTiers)]sync.Pool
+
+// getBodyBuffer returns an empty buffer with room for sizeHint bytes, from the
+// pool when possible. Return it with putBodyBuffer once its contents are no
+// longer referenced. The hint usually comes from the client's Content-Length, so
+// it's never trusted past maxSize, or past the largest tier if maxSize isn't set;
+// bodies bigger than that grow the buffer as they're read.
+func getBodyBuffer(sizeHint int64, maxSize int64) *bytes.Buffer {
+	if maxSize > 0 && sizeHint > maxSize {
+		sizeHint = maxSize
+	}
+	tier := bodyBufferTier(sizeHint)
+	if tier < 0 {
+		if maxSize <= 0 {
+			tier = len(bodyBufferTiers) - 1
+		} else {
+			// too big to pool
+			return bytes.NewBuffer(make([]byte, 0, sizeHint+bytes.MinRead))
+		}
+	}
+	if buf, ok := bodyBufferPools[tier].Get().(*bytes.Buffer); ok {
+		return buf
+	}
+	return bytes.NewBuffer(make([]byte, 0, bodyBufferTiers[tier]))
+}
+
+// putBodyBuffer returns buf to the pool for its capacity. Buffers that grew
+// past the largest tier are left for the garbage collector.
+func putBodyBuffer(buf *bytes.Buffer) {
+	// pick the largest tier the buffer can fully serve
+	for tier := len(bodyBufferTiers) - 1; tier >= 0; tier-- {
+		if buf.Cap() >= bodyBufferTiers[tier] {
+			if tier == len(bodyBufferTiers)-1 && buf.Cap() > 2*bodyBufferTiers[tier] {
+				return
+			}
+			buf.Reset()
+			bodyBufferPools[tier].Put(buf)
+			return
+		}
+	}
+}
+
+// bodyBufferTier returns the index of the smallest tier that holds size bytes,
+// or -1 if size is bigger than every tier
+func bodyBufferTier(size int64) int {
+	for tier, capacity := range bodyBufferTiers {
+		// leave room for the read that finds EOF, so the buffer doesn't grow
+		if size <= int64(capacity-bytes.MinRead) {
+			return tier
+		}
+	}
+	return -1
+}
+
+// newBatches returns an empty slice to collect n batches in, reusing the
+// caller's BatchBuffer when it has any capacity
+func (opts TranslateOptions) newBatches(n int) []Batch {
+	if cap(opts.BatchBuffer) > 0 {
+		return opts.BatchBuffer[:0]
+	}
+	return make([]Batch, 0, n)
+}
+
+// eventArena hands out event slices carved from a single backing slice, so the
+// events of every batch in a result can share one allocation
+type eventArena struct {
+	buf []Event
+}
+
+// newEventArena returns an arena with room for n events, using buf when it's big enough
+func newEventArena(buf []Event, n int) eventArena {
+	if cap(buf) < n {
+		buf = make([]Event, 0, n)
+	}
+	return eventArena{buf: buf[:0]}
+}
+
+// take returns an empty slice with room for n events, from the arena's spare
+// capacity when there's enough left. Slices never overlap, so appending up to
+// n events to one doesn't affect the others.
+func (a *eventArena) take(n int) []Event {
+	if n == 0 {
+		return nil
+	}
+	if n <= cap(a.buf)-len(a.buf) {
+		start := len(a.buf)
+		a.buf = a.buf[:start+n]
+		return a.buf[start : start : start+n]
+	}
+	return make([]Event, 0, n)
+}
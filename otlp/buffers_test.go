@@ -0,0 +1,107 @@
+package otlp
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestBodyBufferTier(t *testing.T) {
+	assert.Equal(t, 0, bodyBufferTier(0))
+	assert.Equal(t, 0, bodyBufferTier(1000))
+	assert.Equal(t, 1, bodyBufferTier(64<<10))
+	assert.Equal(t, 2, bodyBufferTier(2<<20))
+	assert.Equal(t, -1, bodyBufferTier(8<<20))
+	assert.Equal(t, -1, bodyBufferTier(math.MaxInt64))
+}
+
+func TestBodyBufferPool(t *testing.T) {
+	buf := getBodyBuffer(100<<10, 0)
+	assert.GreaterOrEqual(t, buf.Cap(), 1<<20)
+	assert.Equal(t, 0, buf.Len())
+	buf.WriteString("contents")
+	putBodyBuffer(buf)
+
+	// buffers come back empty
+	buf = getBodyBuffer(100<<10, 0)
+	assert.Equal(t, 0, buf.Len())
+	assert.GreaterOrEqual(t, buf.Cap(), 1<<20)
+
+	// oversized buffers are sized to fit when there's a maximum body size, and not pooled
+	buf = getBodyBuffer(20<<20, 32<<20)
+	assert.GreaterOrEqual(t, buf.Cap(), 20<<20)
+	putBodyBuffer(buf)
+	assert.Less(t, getBodyBuffer(8<<20-bytes.MinRead, 0).Cap(), 20<<20)
+}
+
+func TestBodyBufferSizeHintIsClamped(t *testing.T) {
+	// a client claiming a huge Content-Length mustn't make us allocate it up front
+	assert.LessOrEqual(t, getBodyBuffer(100_000_000_000, 0).Cap(), 2*bodyBufferTiers[len(bodyBufferTiers)-1])
+	assert.LessOrEqual(t, getBodyBuffer(math.MaxInt64, 0).Cap(), 2*bodyBufferTiers[len(bodyBufferTiers)-1])
+	assert.LessOrEqual(t, getBodyBuffer(100_000_000_000, 16<<20).Cap(), 16<<20+bytes.MinRead)
+
+	// the buffer still grows to fit a body bigger than the largest tier
+	buf := getBodyBuffer(math.MaxInt64, 0)
+	_, err := buf.ReadFrom(bytes.NewReader(make([]byte, 10<<20)))
+	require.NoError(t, err)
+	assert.Equal(t, 10<<20, buf.Len())
+}
+
+func TestEventArena(t *testing.T) {
+	arena := eventArena{buf: make([]Event, 0, 5)}
+	assert.Nil(t, arena.take(0))
+	a := arena.take(2)
+	b := arena.take(3)
+	assert.Equal(t, 2, cap(a))
+	assert.Equal(t, 3, cap(b))
+	a = append(a, Event{SampleRate: 1}, Event{SampleRate: 2})
+	b = append(b, Event{SampleRate: 3})
+	assert.Equal(t, int32(2), a[1].SampleRate)
+	assert.Equal(t, &arena.buf[2], &b[0])
+
+	// out of room, so allocated separately
+	c := arena.take(1)
+	assert.Equal(t, 1, cap(c))
+	c = append(c, Event{SampleRate: 4})
+	assert.NotEqual(t, &arena.buf[0], &c[0])
+}
+
+func TestTranslateWithCallerBuffers(t *testing.T) {
+	newRequest := func() *collectortrace.ExportTraceServiceRequest {
+		return buildTestTraceRequest(nil,
+			&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Events: []*trace.Span_Event{{Name: "event"}}},
+			&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+		)
+	}
+	eventBuffer := make([]Event, 0, 10)
+	batchBuffer := make([]Batch, 0, 2)
+	opts := TranslateOptions{EventBuffer: eventBuffer, BatchBuffer: batchBuffer}
+
+	result, err := TranslateTraceRequestWithOptions(newRequest(), testRequestInfo(), opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	require.Equal(t, 3, len(result.Batches[0].Events))
+	assert.Equal(t, &batchBuffer[:1][0], &result.Batches[0])
+	assert.Equal(t, &eventBuffer[:1][0], &result.Batches[0].Events[0])
+
+	// reusing the buffers overwrites the previous result
+	result, err = TranslateTraceRequestWithOptions(newRequest(), testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, &eventBuffer[:1][0], &result.Batches[0].Events[0])
+
+	// buffers that are too small are ignored for the events that don't fit
+	result, err = TranslateTraceRequestWithOptions(newRequest(), testRequestInfo(), TranslateOptions{EventBuffer: make([]Event, 0, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(result.Batches[0].Events))
+
+	logsResult, err := TranslateLogsRequestWithOptions(buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service"), testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, &eventBuffer[:1][0], &logsResult.Batches[0].Events[0])
+}
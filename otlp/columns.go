@@ -0,0 +1,106 @@
+package otlp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultColumnOverflowField holds the attributes folded together by MaxColumnsPerBatch
+const defaultColumnOverflowField = "meta.overflow_attributes"
+
+// ColumnCount returns the number of distinct fields across the batch's events,
+// which become columns of its dataset
+func (b Batch) ColumnCount() int {
+	columns := map[string]struct{}{}
+	for _, ev := range b.Events {
+		ev.eachField(func(k string, v interface{}) {
+			columns[k] = struct{}{}
+		})
+	}
+	return len(columns)
+}
+
+func (opts TranslateOptions) columnOverflowField() string {
+	if opts.ColumnOverflowField != "" {
+		return opts.ColumnOverflowField
+	}
+	return defaultColumnOverflowField
+}
+
+// foldOverflowColumns limits each batch to opts.MaxColumnsPerBatch distinct fields.
+// Fields are admitted in the order they're first seen; fields beyond the limit are
+// encoded together as a JSON object in the overflow field of the event they're on.
+// Trace and span IDs are always kept. It returns a warning for each batch folded.
+func foldOverflowColumns(batches []Batch, opts TranslateOptions) []string {
+	if opts.MaxColumnsPerBatch <= 0 {
+		return nil
+	}
+	overflowField := opts.columnOverflowField()
+	var warnings []string
+	for i := range batches {
+		columns := make(map[string]struct{}, opts.MaxColumnsPerBatch)
+		folded := map[string]struct{}{}
+		for j := range batches[i].Events {
+			ev := &batches[i].Events[j]
+			newFields := map[string]interface{}{}
+			ev.eachField(func(k string, v interface{}) {
+				if _, ok := columns[k]; !ok {
+					newFields[k] = v
+				}
+			})
+			if len(columns)+len(newFields) <= opts.MaxColumnsPerBatch {
+				for k := range newFields {
+					columns[k] = struct{}{}
+				}
+				continue
+			}
+			// admit new fields in sorted order, so the same ones are kept every time
+			var overflow map[string]interface{}
+			for _, k := range sortedFieldKeys(newFields) {
+				if _, protected := oversizedEventProtectedKeys[k]; protected || len(columns) < opts.MaxColumnsPerBatch {
+					columns[k] = struct{}{}
+					continue
+				}
+				if overflow == nil {
+					overflow = map[string]interface{}{}
+				}
+				overflow[k] = newFields[k]
+			}
+			if overflow == nil {
+				continue
+			}
+			data, err := json.Marshal(overflow)
+			if err != nil {
+				continue
+			}
+			// folded fields may be in shared or typed layers, so flatten the event first
+			fields := ev.Fields()
+			flattened := make(map[string]interface{}, len(fields)-len(overflow)+1)
+			for k, v := range fields {
+				if _, ok := overflow[k]; !ok {
+					flattened[k] = v
+				}
+			}
+			flattened[overflowField] = string(data)
+			*ev = Event{Attributes: flattened, Timestamp: ev.Timestamp, SampleRate: ev.SampleRate}
+			columns[overflowField] = struct{}{}
+			for k := range overflow {
+				folded[k] = struct{}{}
+			}
+		}
+		if len(folded) > 0 {
+			warnings = append(warnings, fmt.Sprintf("folded %d fields beyond the limit of %d columns into %s in dataset %q: %s",
+				len(folded), opts.MaxColumnsPerBatch, overflowField, batches[i].Dataset, sortedKeys(folded)))
+		}
+	}
+	return warnings
+}
+
+func sortedFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,81 @@
+package otlp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestBatchColumnCount(t *testing.T) {
+	batch := Batch{Events: []Event{
+		{Attributes: map[string]interface{}{"a": 1, "b": 2}},
+		{Attributes: map[string]interface{}{"b": 3}, Inherited: map[string]interface{}{"c": 4}},
+	}}
+	assert.Equal(t, 3, batch.ColumnCount())
+	assert.Equal(t, 0, Batch{}.ColumnCount())
+}
+
+func TestFoldOverflowColumns(t *testing.T) {
+	var spans []*trace.Span
+	for i := 0; i < 3; i++ {
+		spans = append(spans, &trace.Span{
+			TraceId:    test.RandomBytes(16),
+			SpanId:     test.RandomBytes(8),
+			Name:       "span",
+			Attributes: []*common.KeyValue{stringAttr(fmt.Sprintf("user.%d", i), "clicked")},
+		})
+	}
+	baseline, err := TranslateTraceRequest(buildTestTraceRequest(nil, spans[0]), testRequestInfo())
+	require.NoError(t, err)
+	// room for the first span's fields and the overflow field
+	limit := baseline.Batches[0].ColumnCount()
+
+	for _, opts := range []TranslateOptions{
+		{MaxColumnsPerBatch: limit},
+		{MaxColumnsPerBatch: limit, SharedResourceAttributes: true, TypedSpanFields: true, TypedAttributeValues: true},
+		{MaxColumnsPerBatch: limit, ColumnOverflowField: "overflow"},
+	} {
+		result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, spans...), testRequestInfo(), opts)
+		require.NoError(t, err)
+		batch := result.Batches[0]
+		overflowField := opts.columnOverflowField()
+
+		assert.Equal(t, limit+1, batch.ColumnCount())
+		first := batch.Events[0].Fields()
+		assert.Equal(t, "clicked", first["user.0"])
+		assert.NotContains(t, first, overflowField)
+		for i := 1; i < 3; i++ {
+			fields := batch.Events[i].Fields()
+			assert.NotContains(t, fields, fmt.Sprintf("user.%d", i))
+			assert.Equal(t, fmt.Sprintf(`{"user.%d":"clicked"}`, i), fields[overflowField])
+			assert.Equal(t, "span", fields["name"])
+			assert.NotEmpty(t, fields["trace.trace_id"])
+		}
+		require.Equal(t, 1, len(result.Warnings))
+		assert.Equal(t, fmt.Sprintf(`folded 2 fields beyond the limit of %d columns into %s in dataset "unknown_service": user.1, user.2`, limit, overflowField), result.Warnings[0])
+	}
+}
+
+func TestFoldOverflowColumnsKeepsProtectedFields(t *testing.T) {
+	batches := []Batch{{Events: []Event{{Attributes: map[string]interface{}{
+		"trace.trace_id": "abc",
+		"trace.span_id":  "def",
+		"b":              2,
+		"a":              1,
+	}}}}}
+	warnings := foldOverflowColumns(batches, TranslateOptions{MaxColumnsPerBatch: 1})
+	require.Equal(t, 1, len(warnings))
+	attrs := batches[0].Events[0].Attributes
+	assert.Equal(t, "abc", attrs["trace.trace_id"])
+	assert.Equal(t, "def", attrs["trace.span_id"])
+	// fields are admitted in sorted order
+	assert.Equal(t, 1, attrs["a"])
+	assert.Equal(t, `{"b":2}`, attrs[defaultColumnOverflowField])
+
+	assert.Nil(t, foldOverflowColumns(batches, TranslateOptions{}))
+}
@@ -1,15 +1,18 @@
 package otlp
 
 import (
-	"bytes"
+	"bufio"
 	"compress/gzip"
 	"context"
+	"fmt"
 	"io"
 	"math"
 	"net/http"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/klauspost/compress/zstd"
@@ -23,23 +26,28 @@ import (
 
 const (
 	apiKeyHeader             = "x-honeycomb-team"
+	authorizationHeader      = "authorization"
 	datasetHeader            = "x-honeycomb-dataset"
+	sampleRateHeader         = "x-honeycomb-samplerate"
 	proxyTokenHeader         = "x-honeycomb-proxy-token"
 	proxyVersionHeader       = "x-basenji-version"
 	userAgentHeader          = "user-agent"
 	contentTypeHeader        = "content-type"
 	contentEncodingHeader    = "content-encoding"
+	contentLengthHeader      = "content-length"
 	gRPCAcceptEncodingHeader = "grpc-accept-encoding"
 	defaultServiceName       = "unknown_service"
 	unknownLogSource         = "unknown_log_source"
 )
 
+// defaultMaxDatasetNameLength is the default cap on sanitized dataset names
+const defaultMaxDatasetNameLength = 255
+
 // fieldSizeMax is the maximum size of a field that will be accepted by honeycomb.
 // The limit is enforced in retriever (in private honeycomb code), in varstring.go.
 const fieldSizeMax = math.MaxUint16
 
 var (
-	legacyApiKeyPattern = regexp.MustCompile("^[0-9a-f]{32}$")
 	// Incoming OpenTelemetry HTTP Content-Types (e.g. "application/protobuf") we support
 	supportedContentTypes = []string{
 		"application/protobuf",
@@ -76,26 +84,92 @@ func GetSupportedContentEncodings() []string {
 // TranslateOTLPRequestResult represents an OTLP request translated into Honeycomb-friendly structure
 // RequestSize is total byte size of the entire OTLP request
 // Batches represent events grouped by their target dataset
+// EventsByTelemetrySDK counts events per telemetry SDK, when enabled with TranslateOptions.IncludeTelemetrySDK
+// DroppedSpans counts spans that were deliberately not translated
+// DroppedLogRecords counts log records that were deliberately not translated
+// InvalidIDSpans counts spans with a missing, all-zero or wrong-length trace or span ID,
+// whether they were dropped or flagged
+// OversizedEvents counts events larger than TranslateOptions.MaxEventSize,
+// whether they were flagged, truncated or dropped
+// SampledOutEvents counts events dropped by TranslateOptions.Sampler
+// Team is the request's team, from RequestInfo.Team; batches sent with an
+// overriding API key carry their own in Batch.Team
+// Warnings describe data that was dropped or altered during translation
 type TranslateOTLPRequestResult struct {
-	RequestSize int
-	Batches     []Batch
+	RequestSize          int
+	Batches              []Batch
+	EventsByTelemetrySDK map[TelemetrySDK]int
+	DroppedSpans         int
+	DroppedLogRecords    int
+	InvalidIDSpans       int
+	OversizedEvents      int
+	SampledOutEvents     int
+	Team                 *Team
+	Warnings             []string
+
+	// events is the backing storage shared by the batches' events
+	events []Event
+}
+
+// TelemetrySDK identifies the OpenTelemetry SDK that produced a resource's telemetry,
+// normalized from the telemetry.sdk.* resource attributes
+type TelemetrySDK struct {
+	Name     string
+	Language string
+	Version  string
 }
 
 // Batch represents Honeycomb events grouped by their target dataset
-// SizeBytes is the total byte size of the OTLP structure that represents this batch
+// SizeBytes is the total byte size of the OTLP structure that represents this batch;
+// the Batch.JSONSizeBytes method gives the size of its events as sent to Honeycomb
+// APIKey is the key to send the batch with when its resource overrode the
+// request's; it's empty to use RequestInfo.ApiKey
+// Team is the team of the key the batch is sent with: the override's, as verified
+// by TranslateOptions.APIKeyResolver, or otherwise RequestInfo.Team
 type Batch struct {
 	Dataset   string
 	SizeBytes int
 	Events    []Event
+	APIKey    string
+	Team      *Team
 }
 
 // Event represents a single Honeycomb event
+// When Values, Inherited or Span is set, their fields are part of the event as well
+// and Attributes holds only the remaining fields; use Get or Fields to read them
+// together. Inherited is shared between events and must not be modified.
 type Event struct {
 	Attributes map[string]interface{}
+	Values     []Attribute
+	Inherited  map[string]interface{}
+	Span       *SpanFields
 	Timestamp  time.Time
 	SampleRate int32
 }
 
+// SizeBytes returns an estimate of the event's serialized size, computed from the
+// length of each attribute key and value. Numbers count as 8 bytes and booleans as 1.
+func (e Event) SizeBytes() int {
+	size := 0
+	for _, attr := range e.Values {
+		size += len(attr.Key) + attr.Value.sizeBytes()
+	}
+	e.eachUntypedField(func(k string, v interface{}) {
+		size += len(k)
+		switch v := v.(type) {
+		case string:
+			size += len(v)
+		case bool:
+			size++
+		case int, int32, int64, uint32, uint64, float64:
+			size += 8
+		default:
+			size += len(fmt.Sprint(v))
+		}
+	})
+	return size
+}
+
 // RequestInfo represents information parsed from either HTTP headers or gRPC metadata
 type RequestInfo struct {
 	ApiKey       string
@@ -107,10 +181,34 @@ type RequestInfo struct {
 	ContentType        string
 	ContentEncoding    string
 	GRPCAcceptEncoding string
+
+	// GRPCEncoding is the compression a gRPC client used for the request's messages
+	// (e.g. "gzip" or "zstd"), empty if uncompressed. gRPC messages are decompressed
+	// before translation, so this is informational.
+	GRPCEncoding string
+
+	// RemoteAddr is the network address of the client, when known
+	RemoteAddr string
+
+	// ContentLength is the size of the request body in bytes, when known. It's used
+	// to size the buffer the body is read into.
+	ContentLength int64
+
+	// ReceivedAt is when the request was received, used as "now" when validating
+	// event timestamps. Translation time is used if zero.
+	ReceivedAt time.Time
+
+	// SampleRate is the default sample rate for events that don't carry their own,
+	// parsed from the x-honeycomb-samplerate header. Zero if absent or invalid.
+	SampleRate int32
+
+	// Team is the API key's team, when a TeamResolver has verified it. It's copied
+	// to the translation result.
+	Team *Team
 }
 
 func (ri RequestInfo) hasLegacyKey() bool {
-	return legacyApiKeyPattern.MatchString(ri.ApiKey)
+	return IsClassicApiKey(ri.ApiKey)
 }
 
 // ValidateTracesHeaders validates required headers/metadata for a trace OTLP request
@@ -141,6 +239,20 @@ func (ri *RequestInfo) ValidateMetricsHeaders() error {
 	return nil // no error, headers passed all the validations
 }
 
+// ValidateProfilesHeaders validates required headers/metadata for a profiles OTLP request
+func (ri *RequestInfo) ValidateProfilesHeaders() error {
+	if len(ri.ApiKey) == 0 {
+		return ErrMissingAPIKeyHeader
+	}
+	if ri.hasLegacyKey() && len(ri.Dataset) == 0 {
+		return ErrMissingDatasetHeader
+	}
+	if !IsContentTypeSupported(ri.ContentType) {
+		return ErrInvalidContentType
+	}
+	return nil // no error, headers passed all the validations
+}
+
 // ValidateLogsHeaders validates required headers/metadata for a logs OTLP request
 func (ri *RequestInfo) ValidateLogsHeaders() error {
 	if len(ri.ApiKey) == 0 {
@@ -158,20 +270,28 @@ func GetRequestInfoFromGrpcMetadata(ctx context.Context) RequestInfo {
 		ContentType: "application/protobuf",
 	}
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		ri.ApiKey = getValueFromMetadata(md, apiKeyHeader)
-		ri.Dataset = getValueFromMetadata(md, datasetHeader)
-		ri.ProxyToken = getValueFromMetadata(md, proxyTokenHeader)
+		ri.ApiKey = getTokenFromMetadata(md, apiKeyHeader)
+		if ri.ApiKey == "" {
+			ri.ApiKey = getBearerToken(getValueFromMetadata(md, authorizationHeader))
+		}
+		ri.Dataset = getTokenFromMetadata(md, datasetHeader)
+		ri.ProxyToken = getTokenFromMetadata(md, proxyTokenHeader)
+		ri.SampleRate = parseSampleRateHeader(getTokenFromMetadata(md, sampleRateHeader))
 		ri.ProxyVersion = getValueFromMetadata(md, proxyVersionHeader)
 		ri.UserAgent = getValueFromMetadata(md, userAgentHeader)
 		ri.ContentEncoding = getValueFromMetadata(md, contentEncodingHeader)
 		ri.GRPCAcceptEncoding = getValueFromMetadata(md, gRPCAcceptEncodingHeader)
 	}
+	ri.GRPCEncoding = getGRPCEncoding(ctx)
+	if team, ok := TeamFromContext(ctx); ok {
+		ri.Team = &team
+	}
 	return ri
 }
 
 // GetRequestInfoFromHttpHeaders parses relevant incoming HTTP headers
 func GetRequestInfoFromHttpHeaders(header http.Header) RequestInfo {
-	return RequestInfo{
+	ri := RequestInfo{
 		ApiKey:             header.Get(apiKeyHeader),
 		Dataset:            header.Get(datasetHeader),
 		ProxyToken:         header.Get(proxyTokenHeader),
@@ -181,33 +301,137 @@ func GetRequestInfoFromHttpHeaders(header http.Header) RequestInfo {
 		ContentEncoding:    header.Get(contentEncodingHeader),
 		GRPCAcceptEncoding: header.Get(gRPCAcceptEncodingHeader),
 	}
+	if ri.ApiKey == "" {
+		ri.ApiKey = getBearerToken(header.Get(authorizationHeader))
+	}
+	ri.SampleRate = parseSampleRateHeader(header.Get(sampleRateHeader))
+	if length, err := strconv.ParseInt(header.Get(contentLengthHeader), 10, 64); err == nil && length > 0 {
+		ri.ContentLength = length
+	}
+	return ri
+}
+
+// parseSampleRateHeader returns the positive integer sample rate in value, or 0
+func parseSampleRateHeader(value string) int32 {
+	i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	if err != nil || i < 1 {
+		return 0
+	}
+	return int32(i)
 }
 
+// now returns the time the request was received, or the current time if unknown
+func (ri RequestInfo) now() time.Time {
+	if !ri.ReceivedAt.IsZero() {
+		return ri.ReceivedAt
+	}
+	return time.Now()
+}
+
+// defaultSampleRate returns the sample rate for events that don't set one
+func (ri RequestInfo) defaultSampleRate() int32 {
+	if ri.SampleRate > 0 {
+		return ri.SampleRate
+	}
+	return defaultSampleRate
+}
+
+// getValueFromMetadata returns the first non-empty value for key. grpc-go lowercases
+// keys when metadata is built with metadata.New, Pairs or Set, but an MD created
+// as a map literal (or by other middleware) can carry keys in any case, so we fall
+// back to a case-insensitive scan if the normalized lookup finds nothing.
 func getValueFromMetadata(md metadata.MD, key string) string {
+	for _, val := range getValuesFromMetadata(md, key) {
+		if val = strings.TrimSpace(val); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// getTokenFromMetadata is like getValueFromMetadata but also splits values that a
+// proxy has joined with commas, returning the first non-empty element. Only use
+// this for single-token values such as API keys; user agents can contain commas.
+func getTokenFromMetadata(md metadata.MD, key string) string {
+	for _, val := range getValuesFromMetadata(md, key) {
+		for _, part := range strings.Split(val, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				return part
+			}
+		}
+	}
+	return ""
+}
+
+func getValuesFromMetadata(md metadata.MD, key string) []string {
 	if vals := md.Get(key); len(vals) > 0 {
-		return vals[0]
+		return vals
+	}
+	var vals []string
+	for k, v := range md {
+		if strings.EqualFold(k, key) {
+			vals = append(vals, v...)
+		}
+	}
+	return vals
+}
+
+// GetRequestInfoFromHTTPRequest parses relevant information from an incoming HTTP request,
+// including its headers and the client's remote address
+func GetRequestInfoFromHTTPRequest(r *http.Request) RequestInfo {
+	ri := GetRequestInfoFromHttpHeaders(r.Header)
+	ri.RemoteAddr = r.RemoteAddr
+	if r.ContentLength > 0 {
+		ri.ContentLength = r.ContentLength
+	}
+	if team, ok := TeamFromContext(r.Context()); ok {
+		ri.Team = &team
+	}
+	return ri
+}
+
+// getBearerToken returns the token from an "Authorization: Bearer <token>" value,
+// which we accept as the API key for SDKs that can only set the Authorization header
+func getBearerToken(value string) string {
+	const prefix = "bearer "
+	if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+		return strings.TrimSpace(value[len(prefix):])
 	}
 	return ""
 }
 
-func addAttributesToMap(attrs map[string]interface{}, attributes []*common.KeyValue) {
+// addAttributesToMap adds attributes to attrs, returning how many were
+// dropped because they had no key or no usable value
+func addAttributesToMap(attrs map[string]interface{}, attributes []*common.KeyValue) (dropped int) {
 	for _, attr := range attributes {
-		// ignore entries if the key is empty or value is nil
-		if attr.Key == "" || attr.Value == nil {
-			continue
-		}
-		if val, truncatedBytes := getValue(attr.Value); val != nil {
-			attrs[attr.Key] = val
-			if truncatedBytes != 0 {
-				// if we trim a field, add telemetry about it; because we trim at 64K and
-				// a whole span can't be more than 100K, this can't happen more than once
-				// for a single span. If we ever change those limits, this will need to
-				// become additive.
-				attrs["meta.truncated_bytes"] = val
-				attrs["meta.truncated_field"] = attr.Key
-			}
+		if !addAttributeToMap(attrs, attr) {
+			dropped++
 		}
 	}
+	return dropped
+}
+
+// addAttributeToMap adds a single attribute to attrs, reporting false if it was
+// dropped because it had no key or no usable value
+func addAttributeToMap(attrs map[string]interface{}, attr *common.KeyValue) bool {
+	// ignore entries if the key is empty or value is nil
+	if attr.Key == "" || attr.Value == nil {
+		return false
+	}
+	val, truncatedBytes := getValue(attr.Value)
+	if val == nil {
+		return false
+	}
+	attrs[attr.Key] = val
+	if truncatedBytes != 0 {
+		// if we trim a field, add telemetry about it; because we trim at 64K and
+		// a whole span can't be more than 100K, this can't happen more than once
+		// for a single span. If we ever change those limits, this will need to
+		// become additive.
+		attrs["meta.truncated_bytes"] = truncatedBytes
+		attrs["meta.truncated_field"] = attr.Key
+	}
+	return true
 }
 
 func getResourceAttributes(resource *resource.Resource) map[string]interface{} {
@@ -218,52 +442,261 @@ func getResourceAttributes(resource *resource.Resource) map[string]interface{} {
 	return attrs
 }
 
-func getScopeAttributes(scope *common.InstrumentationScope) map[string]interface{} {
+// mergeAttributes returns a new map holding resource attributes overlaid with
+// scope attributes, so each event copies one map rather than two
+func mergeAttributes(resourceAttrs, scopeAttrs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(resourceAttrs)+len(scopeAttrs))
+	for k, v := range resourceAttrs {
+		merged[k] = v
+	}
+	for k, v := range scopeAttrs {
+		merged[k] = v
+	}
+	return merged
+}
+
+func getScopeAttributes(scope *common.InstrumentationScope, opts TranslateOptions) map[string]interface{} {
 	attrs := map[string]interface{}{}
 	if scope != nil {
-		if scope.Name != "" {
-			attrs["library.name"] = scope.Name
-		}
-		if scope.Version != "" {
-			attrs["library.version"] = scope.Version
-		}
+		addScopeFieldAttributes(attrs, scope.Name, "name", opts.ScopeFieldFormat)
+		addScopeFieldAttributes(attrs, scope.Version, "version", opts.ScopeFieldFormat)
 		addAttributesToMap(attrs, scope.Attributes)
 	}
 	return attrs
 }
 
-func getDataset(ri RequestInfo, attrs map[string]interface{}) string {
+// addScopeFieldAttributes adds a scope's name or version to attrs, if it has one,
+// under the field names format selects
+func addScopeFieldAttributes(attrs map[string]interface{}, value string, field string, format ScopeFieldFormat) {
+	if value == "" {
+		return
+	}
+	if format != ScopeAsOTelScopeFields {
+		attrs["library."+field] = value
+	}
+	if format != ScopeAsLibraryFields {
+		attrs["otel.scope."+field] = value
+	}
+}
+
+// getTelemetrySDK returns the normalized telemetry SDK described by the
+// resource attributes, and false if none of the telemetry.sdk.* attributes are set
+func getTelemetrySDK(attrs map[string]interface{}) (TelemetrySDK, bool) {
+	name, _ := attrs["telemetry.sdk.name"].(string)
+	language, _ := attrs["telemetry.sdk.language"].(string)
+	version, _ := attrs["telemetry.sdk.version"].(string)
+	sdk := TelemetrySDK{
+		Name:     strings.ToLower(strings.TrimSpace(name)),
+		Language: strings.ToLower(strings.TrimSpace(language)),
+		Version:  strings.TrimPrefix(strings.TrimSpace(version), "v"),
+	}
+	return sdk, sdk != TelemetrySDK{}
+}
+
+func addTelemetrySDKAttributes(attrs map[string]interface{}, sdk TelemetrySDK) {
+	if sdk.Name != "" {
+		attrs["meta.telemetry_sdk.name"] = sdk.Name
+	}
+	if sdk.Language != "" {
+		attrs["meta.telemetry_sdk.language"] = sdk.Language
+	}
+	if sdk.Version != "" {
+		attrs["meta.telemetry_sdk.version"] = sdk.Version
+	}
+}
+
+func getDataset(ri RequestInfo, attrs map[string]interface{}, opts TranslateOptions) string {
+	if opts.DatasetResolver != nil {
+		return opts.DatasetResolver.ResolveDataset(ri, attrs)
+	}
 	var dataset string
 	if ri.hasLegacyKey() {
 		dataset = ri.Dataset
+	} else if serviceName, ok := getServiceNameForDataset(attrs, opts); ok {
+		dataset = serviceName
+	} else if opts.RejectUnknownService {
+		dataset = ""
+	} else if opts.UnknownServiceDataset != "" {
+		dataset = opts.UnknownServiceDataset
 	} else {
-		serviceName, ok := attrs["service.name"].(string)
-		if !ok ||
-			strings.TrimSpace(serviceName) == "" ||
-			strings.HasPrefix(serviceName, "unknown_service") {
-			dataset = defaultServiceName
-		} else {
-			dataset = strings.TrimSpace(serviceName)
-		}
+		dataset = defaultServiceName
 	}
 	return dataset
 }
 
-func getLogsDataset(ri RequestInfo, attrs map[string]interface{}) string {
+func getLogsDataset(ri RequestInfo, attrs map[string]interface{}, opts TranslateOptions) string {
+	if opts.DatasetResolver != nil {
+		return opts.DatasetResolver.ResolveDataset(ri, attrs)
+	}
+	// like traces, legacy keys send to their dataset header and others derive the
+	// dataset from service.name, but for logs the header wins for either
 	var dataset string
+	if strings.TrimSpace(ri.Dataset) != "" {
+		dataset = ri.Dataset
+	} else if ri.hasLegacyKey() {
+		dataset = opts.unknownLogSourceDataset()
+	} else if serviceName, ok := getServiceNameForDataset(attrs, opts); ok {
+		dataset = serviceName
+	} else {
+		dataset = opts.unknownLogSourceDataset()
+	}
+	return dataset
+}
+
+// isPreservedUnknownService reports whether an SDK default service name such as
+// "unknown_service:python" should be kept as the dataset, per the options
+func isPreservedUnknownService(serviceName string, opts TranslateOptions) bool {
+	if !opts.PreserveUnknownServiceProcess {
+		return false
+	}
+	process := strings.TrimPrefix(serviceName, defaultServiceName+":")
+	return process != serviceName && strings.TrimSpace(process) != ""
+}
+
+// routedBatches collects events whose dataset was overridden below the resource
+// level, keeping one batch per dataset in the order the datasets were first seen
+type routedBatches struct {
+	batches []Batch
+	index   map[string]int
+}
+
+func (r *routedBatches) add(dataset string, sizeBytes int, events []Event) {
+	if r.index == nil {
+		r.index = map[string]int{}
+	}
+	i, ok := r.index[dataset]
+	if !ok {
+		i = len(r.batches)
+		r.index[dataset] = i
+		r.batches = append(r.batches, Batch{Dataset: dataset})
+	}
+	r.batches[i].SizeBytes += sizeBytes
+	r.batches[i].Events = append(r.batches[i].Events, events...)
+}
+
+func (r *routedBatches) sizeBytes() int {
+	total := 0
+	for _, b := range r.batches {
+		total += b.SizeBytes
+	}
+	return total
+}
+
+func (r *routedBatches) eventCount() int {
+	total := 0
+	for _, b := range r.batches {
+		total += len(b.Events)
+	}
+	return total
+}
+
+// getServiceNameForDataset returns the trimmed service.name, prefixed with the
+// service.namespace if configured, or false if the service is missing or unknown
+func getServiceNameForDataset(attrs map[string]interface{}, opts TranslateOptions) (string, bool) {
 	serviceName, ok := attrs["service.name"].(string)
-	if !ok || strings.TrimSpace(serviceName) == "" || strings.HasPrefix(serviceName, "unknown_service") {
-		if strings.TrimSpace(ri.Dataset) == "" {
-			dataset = unknownLogSource
-		} else {
-			dataset = ri.Dataset
+	if !ok || strings.TrimSpace(serviceName) == "" {
+		return "", false
+	}
+	if strings.HasPrefix(serviceName, defaultServiceName) && !isPreservedUnknownService(serviceName, opts) {
+		return "", false
+	}
+	serviceName = strings.TrimSpace(serviceName)
+	if opts.ServiceNamespaceSeparator != "" {
+		if namespace, ok := attrs["service.namespace"].(string); ok && strings.TrimSpace(namespace) != "" {
+			serviceName = strings.TrimSpace(namespace) + opts.ServiceNamespaceSeparator + serviceName
+		}
+	}
+	return serviceName, true
+}
+
+// sanitizeBatchDatasets applies dataset name sanitization to each batch, if enabled
+func sanitizeBatchDatasets(batches []Batch, opts TranslateOptions) {
+	if !opts.SanitizeDatasetNames {
+		return
+	}
+	for i := range batches {
+		original := batches[i].Dataset
+		dataset := sentDatasetName(original, opts)
+		if dataset == original {
+			continue
+		}
+		batches[i].Dataset = dataset
+		for _, ev := range batches[i].Events {
+			ev.Attributes["meta.original_dataset"] = original
 		}
-	} else {
-		dataset = strings.TrimSpace(serviceName)
+	}
+}
+
+// sentDatasetName returns the name a batch routed to dataset is sent with, which
+// differs when SanitizeDatasetNames is set
+func sentDatasetName(dataset string, opts TranslateOptions) string {
+	if !opts.SanitizeDatasetNames {
+		return dataset
+	}
+	if dataset = sanitizeDatasetName(dataset, opts.MaxDatasetNameLength); dataset == "" {
+		return defaultServiceName
 	}
 	return dataset
 }
 
+func sanitizeDatasetName(dataset string, maxLength int) string {
+	if maxLength <= 0 {
+		maxLength = defaultMaxDatasetNameLength
+	}
+	dataset = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '-'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(dataset))
+	return strings.TrimSpace(truncateString(dataset, maxLength))
+}
+
+// truncateString caps s at maxLength bytes without splitting a multi-byte character
+func truncateString(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	// back up to the start of a rune so we don't split a multi-byte character
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// clampEventTimestamps moves event timestamps further than tolerance from now to
+// the nearest edge of that window, keeping the original timestamp in
+// meta.original_timestamp. It returns the number of events clamped.
+func clampEventTimestamps(batches []Batch, now time.Time, tolerance time.Duration) int {
+	if tolerance <= 0 {
+		return 0
+	}
+	earliest, latest := now.Add(-tolerance), now.Add(tolerance)
+	clamped := 0
+	for _, batch := range batches {
+		for i := range batch.Events {
+			ev := &batch.Events[i]
+			var ts time.Time
+			switch {
+			case ev.Timestamp.Before(earliest):
+				ts = earliest
+			case ev.Timestamp.After(latest):
+				ts = latest
+			default:
+				continue
+			}
+			ev.Attributes["meta.original_timestamp"] = ev.Timestamp.Format(time.RFC3339Nano)
+			ev.Timestamp = ts.UTC()
+			clamped++
+		}
+	}
+	return clamped
+}
+
 // limitedWriter is a writer that will stop writing after reaching its max,
 // but continue to lie to the caller that it was successful.
 // It's a wrapper around strings.Builder for efficiency.
@@ -329,15 +762,20 @@ func getMarshallableValue(value *common.AnyValue) interface{} {
 // This function returns a value that can be handled by Honeycomb -- it must be one of:
 // string, int, bool, float. All other values are converted to strings containing JSON.
 func getValue(value *common.AnyValue) (result interface{}, truncatedBytes int) {
-	switch value.Value.(type) {
+	return getLimitedValue(value, fieldSizeMax)
+}
+
+// getLimitedValue is like getValue but caps JSON-encoded values at maxSize bytes
+func getLimitedValue(value *common.AnyValue, maxSize int) (result interface{}, truncatedBytes int) {
+	switch v := value.Value.(type) {
 	case *common.AnyValue_StringValue:
-		return value.GetStringValue(), 0
+		return v.StringValue, 0
 	case *common.AnyValue_BoolValue:
-		return value.GetBoolValue(), 0
+		return v.BoolValue, 0
 	case *common.AnyValue_DoubleValue:
-		return value.GetDoubleValue(), 0
+		return v.DoubleValue, 0
 	case *common.AnyValue_IntValue:
-		return value.GetIntValue(), 0
+		return v.IntValue, 0
 	// These types are all be marshalled to a string after conversion to Honeycomb-safe values.
 	// We use our limitedWriter to ensure that the string can't be bigger than the allowable,
 	// and it also minimizes allocations.
@@ -345,7 +783,7 @@ func getValue(value *common.AnyValue) (result interface{}, truncatedBytes int) {
 	// in streaming. This is correct but sometimes surprising and the tests need to expect it.
 	case *common.AnyValue_ArrayValue, *common.AnyValue_KvlistValue, *common.AnyValue_BytesValue:
 		arr := getMarshallableValue(value)
-		w := newLimitedWriter(fieldSizeMax)
+		w := newLimitedWriter(maxSize)
 		enc := json.NewEncoder(w)
 		err := enc.Encode(arr)
 		if err == nil {
@@ -355,39 +793,63 @@ func getValue(value *common.AnyValue) (result interface{}, truncatedBytes int) {
 	return nil, 0
 }
 
-func parseOtlpRequestBody(body io.ReadCloser, contentType string, contentEncoding string, request protoreflect.ProtoMessage) error {
-	defer body.Close()
-	bodyBytes, err := io.ReadAll(body)
-	if err != nil {
-		return err
+// parseOtlpRequestBody decodes body into request. If opts.MaxDecompressedBodySize is
+// positive, bodies that decompress to more than that many bytes fail with ErrRequestTooLarge.
+// If opts.SniffContentType is set, the encoding and content type are detected from the
+// body itself rather than taken from the headers. body is never closed.
+func parseOtlpRequestBody(body io.Reader, ri RequestInfo, request protoreflect.ProtoMessage, opts TranslateOptions) error {
+	contentType, contentEncoding := ri.ContentType, ri.ContentEncoding
+	maxSize := opts.MaxDecompressedBodySize
+
+	var reader io.Reader = body
+	if opts.SniffContentType {
+		buffered := bufio.NewReader(body)
+		contentEncoding = sniffContentEncoding(buffered)
+		reader = buffered
+	}
+	if opts.SkipDecompression {
+		contentEncoding = ""
 	}
-	bodyReader := bytes.NewReader(bodyBytes)
-
-	var reader io.Reader
 	switch contentEncoding {
 	case "gzip":
-		gzipReader, err := gzip.NewReader(bodyReader)
+		gzipReader, err := gzip.NewReader(reader)
 		if err != nil {
 			return err
 		}
 		defer gzipReader.Close()
 		reader = gzipReader
 	case "zstd":
-		zstdReader, err := zstd.NewReader(bodyReader)
+		zstdReader, err := zstd.NewReader(reader)
 		if err != nil {
 			return err
 		}
 		defer zstdReader.Close()
 		reader = zstdReader
 	default:
-		reader = bodyReader
+		if contentEncoding != "" && contentEncoding != "identity" {
+			opts.logger().Warnf("unknown content encoding %q, reading body as uncompressed", contentEncoding)
+		}
+	}
+	if maxSize > 0 {
+		// read one byte past the limit so we can tell when it's exceeded
+		reader = io.LimitReader(reader, maxSize+1)
 	}
 
-	bytes, err := io.ReadAll(reader)
-	if err != nil {
+	// decoded messages don't alias the buffer, so it can go back to the pool once we're done
+	buf := getBodyBuffer(ri.ContentLength, maxSize)
+	defer putBodyBuffer(buf)
+	if _, err := buf.ReadFrom(reader); err != nil {
 		return err
 	}
+	bytes := buf.Bytes()
+	var err error
+	if maxSize > 0 && int64(len(bytes)) > maxSize {
+		return ErrRequestTooLarge
+	}
 
+	if opts.SniffContentType {
+		contentType = sniffContentType(bytes)
+	}
 	switch contentType {
 	case "application/protobuf", "application/x-protobuf":
 		err = proto.Unmarshal(bytes, request)
@@ -3,6 +3,7 @@ package otlp
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
@@ -46,6 +47,85 @@ func TestParseHttpHeadersIntoRequestInfo(t *testing.T) {
 	assert.Equal(t, "test-content-type", ri.ContentType)
 }
 
+func TestParseHttpRequestIntoRequestInfo(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/traces", strings.NewReader("body"))
+	r.RemoteAddr = "10.0.0.1:4318"
+	r.Header.Set(apiKeyHeader, "test-api-key")
+	r.Header.Set(datasetHeader, "test-dataset")
+	r.Header.Set(userAgentHeader, "test-user-agent")
+	r.Header.Set(contentTypeHeader, "application/json")
+	r.Header.Set(contentEncodingHeader, "gzip")
+
+	ri := GetRequestInfoFromHTTPRequest(r)
+	assert.Equal(t, "test-api-key", ri.ApiKey)
+	assert.Equal(t, "test-dataset", ri.Dataset)
+	assert.Equal(t, "test-user-agent", ri.UserAgent)
+	assert.Equal(t, "application/json", ri.ContentType)
+	assert.Equal(t, "gzip", ri.ContentEncoding)
+	assert.Equal(t, "10.0.0.1:4318", ri.RemoteAddr)
+	assert.Equal(t, int64(4), ri.ContentLength)
+}
+
+func TestSampleRateHeaderIsParsed(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected int32
+	}{
+		{value: "", expected: 0},
+		{value: "10", expected: 10},
+		{value: " 10 ", expected: 10},
+		{value: "0", expected: 0},
+		{value: "-5", expected: 0},
+		{value: "1.5", expected: 0},
+		{value: "lots", expected: 0},
+		{value: "99999999999", expected: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(sampleRateHeader, tc.value)
+			assert.Equal(t, tc.expected, GetRequestInfoFromHttpHeaders(header).SampleRate)
+
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(sampleRateHeader, tc.value))
+			assert.Equal(t, tc.expected, GetRequestInfoFromGrpcMetadata(ctx).SampleRate)
+		})
+	}
+}
+
+func TestApiKeyFallsBackToBearerToken(t *testing.T) {
+	testCases := []struct {
+		name          string
+		apiKey        string
+		authorization string
+		expected      string
+	}{
+		{name: "team header only", apiKey: "team-key", authorization: "", expected: "team-key"},
+		{name: "team header wins over bearer", apiKey: "team-key", authorization: "Bearer bearer-key", expected: "team-key"},
+		{name: "bearer only", apiKey: "", authorization: "Bearer bearer-key", expected: "bearer-key"},
+		{name: "bearer is case-insensitive", apiKey: "", authorization: "bEaReR bearer-key", expected: "bearer-key"},
+		{name: "non-bearer scheme ignored", apiKey: "", authorization: "Basic dXNlcjpwYXNz", expected: ""},
+		{name: "empty bearer ignored", apiKey: "", authorization: "Bearer ", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			md := metadata.MD{}
+			if tc.apiKey != "" {
+				header.Set(apiKeyHeader, tc.apiKey)
+				md.Set(apiKeyHeader, tc.apiKey)
+			}
+			if tc.authorization != "" {
+				header.Set(authorizationHeader, tc.authorization)
+				md.Set(authorizationHeader, tc.authorization)
+			}
+			assert.Equal(t, tc.expected, GetRequestInfoFromHttpHeaders(header).ApiKey)
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			assert.Equal(t, tc.expected, GetRequestInfoFromGrpcMetadata(ctx).ApiKey)
+		})
+	}
+}
+
 func TestAddAttributesToMap(t *testing.T) {
 	testCases := []struct {
 		key       string
@@ -128,8 +208,10 @@ func TestValidateTracesHeaders(t *testing.T) {
 		{name: "no key, dataset present", apikey: "", dataset: "dataset", contentType: "", err: ErrMissingAPIKeyHeader},
 		{name: "classic/no dataset", apikey: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", dataset: "", contentType: "", err: ErrMissingDatasetHeader},
 		{name: "classic/dataset present", apikey: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", dataset: "dataset", contentType: "application/protobuf", err: nil},
+		{name: "classic ingest/no dataset", apikey: "hcaic_1234567890123456789012345678901234567890123456789012345678", dataset: "", contentType: "", err: ErrMissingDatasetHeader},
 		{name: "E&S/no dataset", apikey: "abc123DEF456ghi789jklm", dataset: "", contentType: "application/protobuf", err: nil},
 		{name: "E&S/dataset present", apikey: "abc123DEF456ghi789jklm", dataset: "dataset", contentType: "application/protobuf", err: nil},
+		{name: "E&S ingest/no dataset", apikey: "hcaik_1234567890123456789012345678901234567890123456789012345678", dataset: "", contentType: "application/protobuf", err: nil},
 		{name: "content-type/(missing)", apikey: "apikey", dataset: "dataset", contentType: "", err: ErrInvalidContentType},
 		{name: "content-type/javascript", apikey: "apikey", dataset: "dataset", contentType: "application/javascript", err: ErrInvalidContentType},
 		{name: "content-type/xml", apikey: "apikey", dataset: "dataset", contentType: "application/xml", err: ErrInvalidContentType},
@@ -280,6 +362,48 @@ func TestGetRequestInfoFromGrpcMetadataIsCaseInsensitive(t *testing.T) {
 	}
 }
 
+func TestGetRequestInfoFromGrpcMetadataWithUnnormalizedKeys(t *testing.T) {
+	// a metadata.MD built as a map literal skips grpc-go's key lowercasing
+	md := metadata.MD{
+		"X-Honeycomb-Team":    {"test-apikey"},
+		"X-HONEYCOMB-DATASET": {"test-dataset"},
+		"User-Agent":          {"test-user-agent"},
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ri := GetRequestInfoFromGrpcMetadata(ctx)
+	assert.Equal(t, "test-apikey", ri.ApiKey)
+	assert.Equal(t, "test-dataset", ri.Dataset)
+	assert.Equal(t, "test-user-agent", ri.UserAgent)
+}
+
+func TestGetRequestInfoFromGrpcMetadataWithMultipleValues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []string
+		expected string
+	}{
+		{name: "single value", values: []string{"test-apikey"}, expected: "test-apikey"},
+		{name: "first value wins", values: []string{"test-apikey", "other-apikey"}, expected: "test-apikey"},
+		{name: "empty values skipped", values: []string{"", "  ", "test-apikey"}, expected: "test-apikey"},
+		{name: "joined values", values: []string{"test-apikey, other-apikey"}, expected: "test-apikey"},
+		{name: "joined values with empty first element", values: []string{" ,test-apikey"}, expected: "test-apikey"},
+		{name: "whitespace trimmed", values: []string{" test-apikey\t"}, expected: "test-apikey"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			md := metadata.Pairs()
+			md.Append(apiKeyHeader, tc.values...)
+			md.Append(userAgentHeader, "ua/1.0 (linux, amd64)")
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			ri := GetRequestInfoFromGrpcMetadata(ctx)
+			assert.Equal(t, tc.expected, ri.ApiKey)
+			// user agents are not split on commas
+			assert.Equal(t, "ua/1.0 (linux, amd64)", ri.UserAgent)
+		})
+	}
+}
+
 func TestGetRequestInfoFromHttpHeadersIsCaseInsensitive(t *testing.T) {
 	const (
 		apiKeyValue     = "test-apikey"
@@ -394,6 +518,39 @@ func Test_getValue(t *testing.T) {
 	}
 }
 
+func TestSanitizeDatasetName(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dataset   string
+		maxLength int
+		expected  string
+	}{
+		{name: "unchanged", dataset: "my-service", expected: "my-service"},
+		{name: "trimmed", dataset: " my-service\t", expected: "my-service"},
+		{name: "slashes replaced", dataset: "shop/checkout\\v2", expected: "shop-checkout-v2"},
+		{name: "control chars removed", dataset: "my\x00ser\nvice", expected: "myservice"},
+		{name: "capped", dataset: "abcdefghij", maxLength: 4, expected: "abcd"},
+		{name: "capped at rune boundary", dataset: "abcé", maxLength: 4, expected: "abc"},
+		{name: "default cap", dataset: strings.Repeat("a", 300), expected: strings.Repeat("a", 255)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sanitizeDatasetName(tc.dataset, tc.maxLength))
+		})
+	}
+}
+
+func TestEventSizeBytes(t *testing.T) {
+	assert.Equal(t, 0, Event{}.SizeBytes())
+	event := Event{Attributes: map[string]interface{}{
+		"name":        "span",     // 4 + 4
+		"error":       true,       // 5 + 1
+		"duration_ms": 1.5,        // 11 + 8
+		"http.status": int64(200), // 11 + 8
+	}}
+	assert.Equal(t, 52, event.SizeBytes())
+}
+
 func Test_limitedWriter(t *testing.T) {
 	tests := []struct {
 		name      string
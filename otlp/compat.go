@@ -0,0 +1,102 @@
+package otlp
+
+import (
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Field numbers from OTLP releases before v0.19 that have since been removed.
+// Senders built against those releases still use them, and the current
+// generated code keeps them as unknown fields.
+const (
+	// ResourceSpans.instrumentation_library_spans and ResourceLogs.instrumentation_library_logs,
+	// which have the same wire format as ScopeSpans and ScopeLogs
+	legacyInstrumentationLibraryField = protowire.Number(1000)
+	// Status.deprecated_code, where any non-zero value was an error
+	legacyStatusCodeField = protowire.Number(1)
+)
+
+// upgradeLegacyTraceRequest moves spans sent as instrumentation_library_spans
+// into ScopeSpans, so requests from old SDKs translate like current ones
+func upgradeLegacyTraceRequest(request *collectorTrace.ExportTraceServiceRequest) {
+	for _, resourceSpan := range request.ResourceSpans {
+		for _, b := range takeLegacyFields(resourceSpan.ProtoReflect(), legacyInstrumentationLibraryField) {
+			scopeSpan := &trace.ScopeSpans{}
+			if err := proto.Unmarshal(b, scopeSpan); err == nil {
+				resourceSpan.ScopeSpans = append(resourceSpan.ScopeSpans, scopeSpan)
+			}
+		}
+	}
+}
+
+// upgradeLegacyLogsRequest moves logs sent as instrumentation_library_logs
+// into ScopeLogs, so requests from old SDKs translate like current ones
+func upgradeLegacyLogsRequest(request *collectorLogs.ExportLogsServiceRequest) {
+	for _, resourceLog := range request.ResourceLogs {
+		for _, b := range takeLegacyFields(resourceLog.ProtoReflect(), legacyInstrumentationLibraryField) {
+			scopeLog := &logs.ScopeLogs{}
+			if err := proto.Unmarshal(b, scopeLog); err == nil {
+				resourceLog.ScopeLogs = append(resourceLog.ScopeLogs, scopeLog)
+			}
+		}
+	}
+}
+
+// takeLegacyFields removes the length-delimited unknown fields with the given
+// number from m and returns their contents
+func takeLegacyFields(m protoreflect.Message, num protowire.Number) [][]byte {
+	unknown := m.GetUnknown()
+	if len(unknown) == 0 {
+		return nil
+	}
+	var values [][]byte
+	var rest protoreflect.RawFields
+	for len(unknown) > 0 {
+		n, typ, tagLen := protowire.ConsumeTag(unknown)
+		if tagLen < 0 {
+			return values
+		}
+		fieldLen := protowire.ConsumeFieldValue(n, typ, unknown[tagLen:])
+		if fieldLen < 0 {
+			return values
+		}
+		if n == num && typ == protowire.BytesType {
+			value, _ := protowire.ConsumeBytes(unknown[tagLen:])
+			values = append(values, value)
+		} else {
+			rest = append(rest, unknown[:tagLen+fieldLen]...)
+		}
+		unknown = unknown[tagLen+fieldLen:]
+	}
+	if len(values) > 0 {
+		m.SetUnknown(rest)
+	}
+	return values
+}
+
+// legacyStatusIsError reports whether an UNSET status carries an error in the
+// deprecated_code field used by OTLP releases before v0.11
+func legacyStatusIsError(status *trace.Status) bool {
+	unknown := status.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		n, typ, tagLen := protowire.ConsumeTag(unknown)
+		if tagLen < 0 {
+			return false
+		}
+		if n == legacyStatusCodeField && typ == protowire.VarintType {
+			code, _ := protowire.ConsumeVarint(unknown[tagLen:])
+			return code != 0
+		}
+		fieldLen := protowire.ConsumeFieldValue(n, typ, unknown[tagLen:])
+		if fieldLen < 0 {
+			return false
+		}
+		unknown = unknown[tagLen+fieldLen:]
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// appendLegacyField encodes m as a length-delimited field with the given number
+func appendLegacyField(t *testing.T, b []byte, num protowire.Number, m proto.Message) []byte {
+	encoded, err := proto.Marshal(m)
+	require.NoError(t, err)
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, encoded)
+}
+
+func TestLegacyInstrumentationLibrarySpans(t *testing.T) {
+	resourceBytes, err := proto.Marshal(&trace.ResourceSpans{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+	})
+	require.NoError(t, err)
+	resourceBytes = appendLegacyField(t, resourceBytes, legacyInstrumentationLibraryField, &trace.ScopeSpans{
+		Scope: &common.InstrumentationScope{Name: "my-library"},
+		Spans: []*trace.Span{{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Name:    "legacy_span",
+		}},
+	})
+	resourceSpans := &trace.ResourceSpans{}
+	require.NoError(t, proto.Unmarshal(resourceBytes, resourceSpans))
+	require.Empty(t, resourceSpans.ScopeSpans)
+
+	req := &collectortrace.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{resourceSpans}}
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	events := result.Batches[0].Events
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, "legacy_span", events[0].Attributes["name"])
+	assert.Equal(t, "my-library", events[0].Attributes["library.name"])
+	assert.Empty(t, resourceSpans.ProtoReflect().GetUnknown())
+}
+
+func TestLegacyInstrumentationLibraryLogs(t *testing.T) {
+	var resourceBytes []byte
+	resourceBytes = appendLegacyField(t, resourceBytes, legacyInstrumentationLibraryField, &logs.ScopeLogs{
+		LogRecords: []*logs.LogRecord{{
+			TimeUnixNano: uint64(time.Now().UnixNano()),
+			SeverityText: "info",
+		}},
+	})
+	resourceLogs := &logs.ResourceLogs{}
+	require.NoError(t, proto.Unmarshal(resourceBytes, resourceLogs))
+
+	req := &collectorlogs.ExportLogsServiceRequest{ResourceLogs: []*logs.ResourceLogs{resourceLogs}}
+	result, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches[0].Events))
+	assert.Equal(t, "info", result.Batches[0].Events[0].Attributes["severity_text"])
+}
+
+func TestLegacyStatusCode(t *testing.T) {
+	legacyStatus := func(code uint64) *trace.Status {
+		b := protowire.AppendTag(nil, legacyStatusCodeField, protowire.VarintType)
+		b = protowire.AppendVarint(b, code)
+		status := &trace.Status{}
+		require.NoError(t, proto.Unmarshal(b, status))
+		return status
+	}
+
+	code, isError := getSpanStatusCode(legacyStatus(2))
+	assert.Equal(t, int(trace.Status_STATUS_CODE_ERROR), code)
+	assert.True(t, isError)
+
+	// deprecated OK
+	code, isError = getSpanStatusCode(legacyStatus(0))
+	assert.Equal(t, int(trace.Status_STATUS_CODE_UNSET), code)
+	assert.False(t, isError)
+
+	// the current code field takes precedence
+	status := legacyStatus(2)
+	status.Code = trace.Status_STATUS_CODE_OK
+	code, isError = getSpanStatusCode(status)
+	assert.Equal(t, int(trace.Status_STATUS_CODE_OK), code)
+	assert.False(t, isError)
+}
@@ -0,0 +1,160 @@
+package otlp
+
+import (
+	"strings"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// Attributes in the honeycomb. namespace control how telemetry is translated
+// rather than being sent as fields, and are removed from the events they apply to.
+// TranslateOptions.DisableControlAttributes turns them into ordinary fields.
+const (
+	// datasetOverrideKey sends a resource, scope or span's events to the named dataset
+	datasetOverrideKey = "honeycomb.dataset"
+	// tableOverrideKey is the older name for datasetOverrideKey, which wins if both are set
+	tableOverrideKey = "honeycomb.table"
+	// sampleRateOverrideKey sets an event's sample rate, taking precedence over
+	// TranslateOptions.SampleRateKeys and the request's default
+	sampleRateOverrideKey = "honeycomb.sample_rate"
+)
+
+// popDatasetOverride removes the honeycomb.dataset and honeycomb.table attributes
+// from attrs, returning the dataset they name (honeycomb.dataset wins if both
+// are set) and whether a non-empty override was found
+func popDatasetOverride(attrs map[string]interface{}, opts TranslateOptions) (string, bool) {
+	if opts.DisableControlAttributes {
+		return "", false
+	}
+	var dataset string
+	for _, key := range []string{tableOverrideKey, datasetOverrideKey} {
+		if val, ok := attrs[key]; ok {
+			if s, ok := val.(string); ok && strings.TrimSpace(s) != "" {
+				dataset = strings.TrimSpace(s)
+			}
+			delete(attrs, key)
+		}
+	}
+	return dataset, dataset != ""
+}
+
+// attributeDatasetOverride is like popDatasetOverride but reads the override
+// from raw attributes without building a map
+func attributeDatasetOverride(attributes []*common.KeyValue, opts TranslateOptions) (string, bool) {
+	if opts.DisableControlAttributes {
+		return "", false
+	}
+	var table, dataset string
+	for _, attr := range attributes {
+		if attr.Key != tableOverrideKey && attr.Key != datasetOverrideKey {
+			continue
+		}
+		s := strings.TrimSpace(attr.Value.GetStringValue())
+		if s == "" {
+			continue
+		}
+		if attr.Key == datasetOverrideKey {
+			dataset = s
+		} else {
+			table = s
+		}
+	}
+	if dataset == "" {
+		dataset = table
+	}
+	return dataset, dataset != ""
+}
+
+// resourceRequestInfo removes a resource's API key override from its attributes,
+// returning ri with the key applied and the overriding key, empty if there's none.
+// Overrides are only read when TranslateOptions.APIKeyAttribute is set. The key
+// isn't verified, so translation uses verifiedResourceRequestInfo instead.
+func resourceRequestInfo(ri RequestInfo, attrs map[string]interface{}, opts TranslateOptions) (RequestInfo, string) {
+	key := opts.APIKeyAttribute
+	if opts.DisableControlAttributes || key == "" {
+		return ri, ""
+	}
+	val, ok := attrs[key]
+	if !ok {
+		return ri, ""
+	}
+	delete(attrs, key)
+	apiKey, _ := val.(string)
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return ri, ""
+	}
+	ri.ApiKey = apiKey
+	return ri, apiKey
+}
+
+// verifiedResourceRequestInfo is like resourceRequestInfo, but with an APIKeyResolver
+// an overriding key is only applied if verified, from verifyAPIKeyOverrides, holds
+// a team for it; that team replaces ri.Team. Without one, overrides are applied
+// unverified and their team is unknown. It returns false if the resolver rejected
+// the key.
+func verifiedResourceRequestInfo(ri RequestInfo, attrs map[string]interface{}, opts TranslateOptions, verified map[string]*Team) (RequestInfo, string, bool) {
+	overridden, apiKey := resourceRequestInfo(ri, attrs, opts)
+	if apiKey == "" {
+		return ri, "", true
+	}
+	if opts.APIKeyResolver == nil {
+		overridden.Team = nil
+		return overridden, apiKey, true
+	}
+	team := verified[apiKey]
+	if team == nil {
+		return ri, apiKey, false
+	}
+	overridden.Team = team
+	return overridden, apiKey, true
+}
+
+// verifyAPIKeyOverrides resolves each distinct API key override among a request's
+// n resources with opts.APIKeyResolver, returning the team of each key it accepts.
+// Keys it rejects are left out; failures to check a key at all, such as a
+// RetryableError, are returned so the whole request can be retried.
+func verifyAPIKeyOverrides(n int, resourceAt func(i int) *resource.Resource, opts TranslateOptions) (map[string]*Team, error) {
+	if opts.APIKeyAttribute == "" || opts.APIKeyResolver == nil || opts.DisableControlAttributes {
+		return nil, nil
+	}
+	var verified map[string]*Team
+	checked := map[string]struct{}{}
+	for i := 0; i < n; i++ {
+		for _, attr := range resourceAt(i).GetAttributes() {
+			if attr.Key != opts.APIKeyAttribute {
+				continue
+			}
+			apiKey := strings.TrimSpace(attr.Value.GetStringValue())
+			if _, ok := checked[apiKey]; ok || apiKey == "" {
+				continue
+			}
+			checked[apiKey] = struct{}{}
+			team, err := opts.APIKeyResolver.ResolveTeam(opts.requestContext(), apiKey)
+			if err != nil {
+				if !isCacheableTeamError(err) {
+					return nil, err
+				}
+				continue
+			}
+			if verified == nil {
+				verified = map[string]*Team{}
+			}
+			verified[apiKey] = &team
+		}
+	}
+	return verified, nil
+}
+
+// popSampleRateOverride removes honeycomb.sample_rate from attrs, returning the
+// sample rate it sets, or fallback if it isn't present
+func popSampleRateOverride(attrs map[string]interface{}, opts TranslateOptions, fallback int32) (int32, bool) {
+	if opts.DisableControlAttributes {
+		return fallback, false
+	}
+	if _, ok := attrs[sampleRateOverrideKey]; !ok {
+		return fallback, false
+	}
+	return popSampleRate(attrs, sampleRateOverrideKey)
+}
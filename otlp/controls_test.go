@@ -0,0 +1,86 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSampleRateControlAttribute(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "my-service")},
+		&trace.Span{
+			TraceId:    test.RandomBytes(16),
+			SpanId:     test.RandomBytes(8),
+			Attributes: []*common.KeyValue{intAttr("honeycomb.sample_rate", 20), intAttr("sampleRate", 5)},
+			Events:     []*trace.Span_Event{{Name: "event"}},
+		},
+	)
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 2, len(events))
+	for _, event := range events {
+		assert.Equal(t, int32(20), event.SampleRate)
+		assert.NotContains(t, event.Attributes, "honeycomb.sample_rate")
+	}
+
+	// resource attributes set the sample rate for all their spans, even when shared
+	req = buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "my-service"), stringAttr("honeycomb.sample_rate", "10")},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+	)
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{SharedResourceAttributes: true, TypedAttributeValues: true})
+	require.NoError(t, err)
+	event := result.Batches[0].Events[0]
+	assert.Equal(t, int32(10), event.SampleRate)
+	_, ok := event.Get("honeycomb.sample_rate")
+	assert.False(t, ok)
+}
+
+func TestSampleRateControlAttributeLogs(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	log := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	log.Attributes = append(log.Attributes, intAttr("honeycomb.sample_rate", 4))
+	ri := testRequestInfo()
+	ri.SampleRate = 2
+
+	result, err := TranslateLogsRequest(req, ri)
+	require.NoError(t, err)
+	event := result.Batches[0].Events[0]
+	assert.Equal(t, int32(4), event.SampleRate)
+	assert.NotContains(t, event.Attributes, "honeycomb.sample_rate")
+}
+
+func TestDisableControlAttributes(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "my-service"), stringAttr("honeycomb.api_key", "tenant-key")},
+		&trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Attributes: []*common.KeyValue{
+				stringAttr("honeycomb.dataset", "audit"),
+				intAttr("honeycomb.sample_rate", 20),
+			},
+		},
+	)
+	opts := TranslateOptions{DisableControlAttributes: true, APIKeyAttribute: "honeycomb.api_key"}
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	batch := result.Batches[0]
+	assert.Equal(t, "my-service", batch.Dataset)
+	assert.Equal(t, "", batch.APIKey)
+	event := batch.Events[0]
+	assert.Equal(t, int32(1), event.SampleRate)
+	assert.Equal(t, "audit", event.Attributes["honeycomb.dataset"])
+	assert.Equal(t, int64(20), event.Attributes["honeycomb.sample_rate"])
+	assert.Equal(t, "tenant-key", event.Attributes["honeycomb.api_key"])
+
+	assert.Equal(t, []string{"my-service"}, PeekDatasets(req, testRequestInfo(), opts))
+}
@@ -0,0 +1,82 @@
+package otlp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsAllowedHeaders are the request headers browser OTLP and gRPC-Web exporters send
+var corsAllowedHeaders = []string{
+	"Content-Type", "Content-Encoding", "Authorization", "User-Agent",
+	"X-Honeycomb-Team", "X-Honeycomb-Dataset", "X-Honeycomb-Samplerate",
+	"X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Grpc-Encoding", "Grpc-Accept-Encoding",
+}
+
+// corsExposedHeaders are the response headers gRPC-Web clients need to read
+const corsExposedHeaders = "Grpc-Status, Grpc-Message"
+
+// CORSOptions configures the CORS handling of an HTTPHandler, so browser SDKs can
+// send telemetry directly
+type CORSOptions struct {
+	// AllowedOrigins are the origins allowed to send requests, e.g. "https://example.com".
+	// "*" allows any origin.
+	AllowedOrigins []string
+
+	// AllowedHeaders are request headers to allow in addition to the OTLP, gRPC-Web
+	// and Honeycomb headers, which are always allowed
+	AllowedHeaders []string
+
+	// MaxAge is how long browsers may cache a preflight response. Browsers use their
+	// own default when zero.
+	MaxAge time.Duration
+}
+
+func (c *CORSOptions) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCORSPreflight reports whether r is a browser's CORS preflight request, which
+// never carries credentials such as an API key
+func isCORSPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// handle adds CORS headers for requests from allowed origins, and reports whether
+// it has fully handled the request, which it does for preflight requests
+func (c *CORSOptions) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+	preflight := isCORSPreflight(r)
+	if !c.allowsOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		// the browser blocks the response without CORS headers
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if !preflight {
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	headers := append(append([]string{}, corsAllowedHeaders...), c.AllowedHeaders...)
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
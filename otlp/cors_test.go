@@ -0,0 +1,57 @@
+package otlp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	handler := NewHTTPHandler(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		return nil
+	})
+	handler.CORS = &CORSOptions{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedHeaders: []string{"X-Custom"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, TracesPath, nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := preflight("https://example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Honeycomb-Team")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Custom")
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	w = preflight("https://evil.example")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// actual requests get the allow origin header, even when they fail
+	r := httptest.NewRequest(http.MethodPost, TracesPath, nil)
+	r.Header.Set("Origin", "https://example.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	// any origin
+	handler.CORS.AllowedOrigins = []string{"*"}
+	w = preflight("https://other.example")
+	assert.Equal(t, "https://other.example", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -1,6 +1,8 @@
 package otlp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -16,10 +18,16 @@ type OTLPError struct {
 }
 
 var (
-	ErrInvalidContentType   = OTLPError{"unsupported content-type, valid types are: " + strings.Join(GetSupportedContentTypes(), ", "), http.StatusUnsupportedMediaType, codes.Unimplemented}
-	ErrFailedParseBody      = OTLPError{"failed to parse OTLP request body", http.StatusBadRequest, codes.Internal}
-	ErrMissingAPIKeyHeader  = OTLPError{"missing 'x-honeycomb-team' header", http.StatusUnauthorized, codes.Unauthenticated}
-	ErrMissingDatasetHeader = OTLPError{"missing 'x-honeycomb-dataset' header", http.StatusUnauthorized, codes.Unauthenticated}
+	ErrInvalidContentType     = OTLPError{"unsupported content-type, valid types are: " + strings.Join(GetSupportedContentTypes(), ", "), http.StatusUnsupportedMediaType, codes.Unimplemented}
+	ErrFailedParseBody        = OTLPError{"failed to parse OTLP request body", http.StatusBadRequest, codes.InvalidArgument}
+	ErrMissingAPIKeyHeader    = OTLPError{"missing 'x-honeycomb-team' header", http.StatusUnauthorized, codes.Unauthenticated}
+	ErrMissingDatasetHeader   = OTLPError{"missing 'x-honeycomb-dataset' header", http.StatusUnauthorized, codes.Unauthenticated}
+	ErrInvalidAPIKey          = OTLPError{"invalid API key", http.StatusUnauthorized, codes.Unauthenticated}
+	ErrAttributeDepthExceeded = OTLPError{"attribute values are nested too deeply", http.StatusBadRequest, codes.InvalidArgument}
+	ErrTooManySpanEvents      = OTLPError{"span has too many span events", http.StatusBadRequest, codes.InvalidArgument}
+	ErrTooManySpans           = OTLPError{"request has too many spans", http.StatusBadRequest, codes.InvalidArgument}
+	ErrRequestTooLarge        = OTLPError{"request body is too large", http.StatusRequestEntityTooLarge, codes.ResourceExhausted}
+	ErrMetricsNotSupported    = OTLPError{"metrics are not supported", http.StatusNotImplemented, codes.Unimplemented}
 )
 
 func (e OTLPError) Error() string {
@@ -30,9 +38,54 @@ func AsJson(e error) string {
 	return fmt.Sprintf(`{"message":"%s"}`, e.Error())
 }
 
+// AsGRPCError converts an error into a gRPC status error. OTLPErrors use their own
+// code, gRPC status errors are passed through, and context cancellation and deadlines
+// map to Canceled and DeadlineExceeded so clients know they can retry. Anything
+// else is Internal, without leaking its message.
 func AsGRPCError(e error) error {
-	if otlpErr, ok := e.(OTLPError); ok {
+	var retryable RetryableError
+	if errors.As(e, &retryable) {
+		return retryable.grpcStatus().Err()
+	}
+	var otlpErr OTLPError
+	if errors.As(e, &otlpErr) {
 		return status.Error(otlpErr.GRPCStatusCode, otlpErr.Message)
 	}
+	if s, ok := status.FromError(e); ok {
+		return s.Err()
+	}
+	switch {
+	case errors.Is(e, context.Canceled):
+		return status.Error(codes.Canceled, e.Error())
+	case errors.Is(e, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, e.Error())
+	}
 	return status.Error(codes.Internal, "")
 }
+
+// httpStatusCode returns the HTTP status code the OTLP spec gives for an error
+func httpStatusCode(e error) int {
+	var retryable RetryableError
+	if errors.As(e, &retryable) {
+		return retryable.HTTPStatusCode
+	}
+	var otlpErr OTLPError
+	if errors.As(e, &otlpErr) {
+		return otlpErr.HTTPStatusCode
+	}
+	switch status.Code(AsGRPCError(e)) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable, codes.Canceled:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -1,11 +1,15 @@
 package otlp
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestErrorsReturnJson(t *testing.T) {
@@ -22,3 +26,45 @@ func TestNonOTLPErrorReturnsStandardError(t *testing.T) {
 	err := errors.New("base-error")
 	assert.Equal(t, "rpc error: code = Internal desc = ", AsGRPCError(err).Error())
 }
+
+func TestAsGRPCErrorMapping(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected codes.Code
+	}{
+		{ErrFailedParseBody, codes.InvalidArgument},
+		{ErrMissingAPIKeyHeader, codes.Unauthenticated},
+		{ErrRequestTooLarge, codes.ResourceExhausted},
+		{fmt.Errorf("translating: %w", ErrTooManySpans), codes.InvalidArgument},
+		{status.Error(codes.Unavailable, "sink unavailable"), codes.Unavailable},
+		{context.Canceled, codes.Canceled},
+		{fmt.Errorf("sending: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{errors.New("base-error"), codes.Internal},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, status.Code(AsGRPCError(tc.err)))
+		})
+	}
+}
+
+func TestHTTPStatusCode(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{ErrInvalidContentType, http.StatusUnsupportedMediaType},
+		{ErrFailedParseBody, http.StatusBadRequest},
+		{ErrInvalidAPIKey, http.StatusUnauthorized},
+		{ErrRequestTooLarge, http.StatusRequestEntityTooLarge},
+		{status.Error(codes.Unavailable, "sink unavailable"), http.StatusServiceUnavailable},
+		{status.Error(codes.ResourceExhausted, "slow down"), http.StatusTooManyRequests},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{errors.New("base-error"), http.StatusInternalServerError},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, httpStatusCode(tc.err))
+		})
+	}
+}
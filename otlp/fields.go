@@ -0,0 +1,277 @@
+package otlp
+
+import common "go.opentelemetry.io/proto/otlp/common/v1"
+
+// SpanFields holds the fixed fields of a translated span as typed values, so they
+// don't have to be boxed and hashed into the event's attribute map.
+// Events carry SpanFields when TranslateOptions.TypedSpanFields is set.
+type SpanFields struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string // omitted from the event's fields when empty
+	Name       string
+	Kind       string // emitted as type and span.kind, unless TranslateOptions.SpanKindFormat picks one
+	DurationMs float64
+	NumLinks   int
+	NumEvents  int
+	Error      bool // omitted from the event's fields when false
+
+	// signalTypeField replaces meta.signal_type, which is omitted if
+	// omitSignalType is set; see TranslateOptions.MetaFieldPrefix
+	signalTypeField string
+	omitSignalType  bool
+	kindFormat      SpanKindFormat
+}
+
+// typedSpanFieldCount is the most fields SpanFields adds to an event
+const typedSpanFieldCount = 11
+
+// each calls fn with the key and value of each field the span contributes to its event
+func (s *SpanFields) each(fn func(key string, value interface{})) {
+	fn("trace.trace_id", s.TraceID)
+	fn("trace.span_id", s.SpanID)
+	if s.ParentID != "" {
+		fn("trace.parent_id", s.ParentID)
+	}
+	if s.kindFormat != SpanKindAsSpanKindOnly {
+		fn("type", s.Kind)
+	}
+	if s.kindFormat != SpanKindAsTypeOnly {
+		fn("span.kind", s.Kind)
+	}
+	fn("name", s.Name)
+	fn("duration_ms", s.DurationMs)
+	fn("span.num_links", s.NumLinks)
+	fn("span.num_events", s.NumEvents)
+	switch {
+	case s.omitSignalType:
+	case s.signalTypeField != "":
+		fn(s.signalTypeField, "trace")
+	default:
+		fn("meta.signal_type", "trace")
+	}
+	if s.Error {
+		fn("error", true)
+	}
+}
+
+// Get returns the value of one of the event's fields. Values take precedence over
+// Attributes, then Inherited attributes and then typed span fields, matching the
+// order fields were written in before typed fields and shared attributes existed.
+// Typed Values are returned as the Go type Attributes would have held.
+func (e Event) Get(key string) (interface{}, bool) {
+	if v, ok := lookupTypedValue(e.Values, key); ok {
+		return v.Interface(), true
+	}
+	if v, ok := e.Attributes[key]; ok {
+		return v, true
+	}
+	if v, ok := e.Inherited[key]; ok {
+		return v, true
+	}
+	if e.Span == nil {
+		return nil, false
+	}
+	var value interface{}
+	var found bool
+	e.Span.each(func(k string, v interface{}) {
+		if k == key {
+			value, found = v, true
+		}
+	})
+	return value, found
+}
+
+// value returns one of the event's fields as a Value
+func (e Event) value(key string) (Value, bool) {
+	if v, ok := lookupTypedValue(e.Values, key); ok {
+		return v, true
+	}
+	v, ok := e.Get(key)
+	if !ok {
+		return Value{}, false
+	}
+	return ValueOf(v), true
+}
+
+// GetString returns the field key if it's a string
+func (e Event) GetString(key string) (string, bool) {
+	v, ok := e.value(key)
+	if !ok || v.Kind() != ValueKindString {
+		return "", false
+	}
+	return v.AsString(), true
+}
+
+// GetInt64 returns the field key if it's an integer of any size that fits in an int64
+func (e Event) GetInt64(key string) (int64, bool) {
+	v, ok := e.value(key)
+	if !ok || v.Kind() != ValueKindInt64 {
+		return 0, false
+	}
+	return v.AsInt64(), true
+}
+
+// GetFloat64 returns the field key if it's a number, converting integers to float64
+func (e Event) GetFloat64(key string) (float64, bool) {
+	v, ok := e.value(key)
+	if !ok {
+		return 0, false
+	}
+	switch v.Kind() {
+	case ValueKindFloat64:
+		return v.AsFloat64(), true
+	case ValueKindInt64:
+		return float64(v.AsInt64()), true
+	}
+	return 0, false
+}
+
+// GetBool returns the field key if it's a bool
+func (e Event) GetBool(key string) (bool, bool) {
+	v, ok := e.value(key)
+	if !ok || v.Kind() != ValueKindBool {
+		return false, false
+	}
+	return v.AsBool(), true
+}
+
+// SetAttr sets the field key to value, replacing it in whichever layer holds it.
+// The value is stored in Attributes, which shadows Inherited attributes and typed
+// span fields of the same name without modifying them.
+func (e *Event) SetAttr(key string, value interface{}) {
+	if e.Attributes == nil {
+		e.Attributes = map[string]interface{}{}
+	}
+	e.Attributes[key] = value
+	deleteTypedValue(&e.Values, key)
+}
+
+// Fields returns all of the event's fields as a single map. For events with only
+// Attributes this is the Attributes map itself; otherwise it's a new map combining
+// every layer.
+func (e Event) Fields() map[string]interface{} {
+	if e.Span == nil && e.Inherited == nil && len(e.Values) == 0 {
+		return e.Attributes
+	}
+	fields := make(map[string]interface{}, typedSpanFieldCount+len(e.Inherited)+len(e.Attributes)+len(e.Values))
+	if e.Span != nil {
+		e.Span.each(func(k string, v interface{}) {
+			fields[k] = v
+		})
+	}
+	for k, v := range e.Inherited {
+		fields[k] = v
+	}
+	for k, v := range e.Attributes {
+		fields[k] = v
+	}
+	for _, attr := range e.Values {
+		fields[attr.Key] = attr.Value.Interface()
+	}
+	return fields
+}
+
+// NumFields returns the number of fields Fields would return, without building the map
+func (e Event) NumFields() int {
+	n := len(e.Values)
+	e.eachUntypedField(func(string, interface{}) { n++ })
+	return n
+}
+
+// eachField calls fn with every one of the event's fields, in no particular order
+func (e Event) eachField(fn func(key string, value interface{})) {
+	for _, attr := range e.Values {
+		fn(attr.Key, attr.Value.Interface())
+	}
+	e.eachUntypedField(fn)
+}
+
+// eachUntypedField calls fn with the event's fields other than its typed Values
+func (e Event) eachUntypedField(fn func(key string, value interface{})) {
+	for k, v := range e.Attributes {
+		if _, shadowed := lookupTypedValue(e.Values, k); !shadowed {
+			fn(k, v)
+		}
+	}
+	for k, v := range e.Inherited {
+		if !e.shadows(k, false) {
+			fn(k, v)
+		}
+	}
+	if e.Span != nil {
+		e.Span.each(func(k string, v interface{}) {
+			if !e.shadows(k, true) {
+				fn(k, v)
+			}
+		})
+	}
+}
+
+// shadows reports whether a higher layer than Inherited, or than the typed span
+// fields if includeInherited is set, holds key
+func (e Event) shadows(key string, includeInherited bool) bool {
+	if _, ok := lookupTypedValue(e.Values, key); ok {
+		return true
+	}
+	if _, ok := e.Attributes[key]; ok {
+		return true
+	}
+	if !includeInherited {
+		return false
+	}
+	_, ok := e.Inherited[key]
+	return ok
+}
+
+// unshareableKeys are attributes that translation reads from or removes from each
+// event's merged attributes. Resource and scope attributes containing any of them
+// are copied into every event even when SharedResourceAttributes is set.
+var unshareableKeys = []string{tableOverrideKey, datasetOverrideKey, sampleRateOverrideKey, "error"}
+
+// canShareAttributes reports whether inherited resource and scope attributes can
+// be shared between events rather than copied into each of them
+func canShareAttributes(inherited map[string]interface{}, opts TranslateOptions) bool {
+	if !opts.SharedResourceAttributes {
+		return false
+	}
+	for _, keys := range [][]string{unshareableKeys, opts.sampleRateKeys(), httpStatusCodeKeys, exceptionAttributeKeys} {
+		for _, key := range keys {
+			if _, ok := inherited[key]; ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inheritAttributes adds resource and scope attributes to an event's attributes.
+// Unless shared, they're copied into attrs and nil is returned. Otherwise the
+// event's own fields that they override are removed from attrs, and inherited is
+// returned for use as the event's Inherited layer.
+func inheritAttributes(attrs, inherited map[string]interface{}, shared bool) map[string]interface{} {
+	if !shared {
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		return nil
+	}
+	for k := range attrs {
+		if _, ok := inherited[k]; ok {
+			delete(attrs, k)
+		}
+	}
+	return inherited
+}
+
+// eventMapSize returns the capacity needed for an event's Attributes map, given how
+// many fields we add ourselves, leaving out the layers that won't be copied into it
+func eventMapSize(fields int, inherited map[string]interface{}, shareInherited bool, attributes []*common.KeyValue, opts TranslateOptions) int {
+	if !shareInherited {
+		fields += len(inherited)
+	}
+	if !opts.TypedAttributeValues {
+		fields += len(attributes)
+	}
+	return fields
+}
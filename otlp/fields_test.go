@@ -0,0 +1,255 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestTypedSpanFields(t *testing.T) {
+	newRequest := func() []*trace.Span {
+		return []*trace.Span{
+			{
+				TraceId:           test.RandomBytes(16),
+				SpanId:            test.RandomBytes(8),
+				ParentSpanId:      test.RandomBytes(8),
+				Name:              "child",
+				Kind:              trace.Span_SPAN_KIND_SERVER,
+				StartTimeUnixNano: 1000,
+				EndTimeUnixNano:   3000000,
+				Status:            &trace.Status{Code: trace.Status_STATUS_CODE_ERROR},
+				Attributes:        []*common.KeyValue{stringAttr("http.route", "/users/:id")},
+				Events:            []*trace.Span_Event{{Name: "event"}},
+			},
+			{
+				TraceId:    test.RandomBytes(16),
+				SpanId:     test.RandomBytes(8),
+				Name:       "root",
+				Attributes: []*common.KeyValue{stringAttr("name", "renamed")},
+			},
+		}
+	}
+	spans := newRequest()
+	resourceAttrs := []*common.KeyValue{stringAttr("service.name", "my-service")}
+	expected, err := TranslateTraceRequest(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo())
+	require.NoError(t, err)
+	result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo(), TranslateOptions{TypedSpanFields: true})
+	require.NoError(t, err)
+
+	events := result.Batches[0].Events
+	expectedEvents := expected.Batches[0].Events
+	require.Equal(t, len(expectedEvents), len(events))
+	for i := range events {
+		assert.Equal(t, expectedEvents[i].Attributes, events[i].Fields())
+		assert.Equal(t, len(expectedEvents[i].Attributes), events[i].NumFields())
+		assert.Equal(t, expectedEvents[i].SizeBytes(), events[i].SizeBytes())
+	}
+
+	child := events[0]
+	require.NotNil(t, child.Span)
+	assert.Equal(t, "child", child.Span.Name)
+	assert.Equal(t, "server", child.Span.Kind)
+	assert.True(t, child.Span.Error)
+	assert.NotContains(t, child.Attributes, "name")
+	assert.NotContains(t, child.Attributes, "trace.trace_id")
+	value, ok := child.Get("duration_ms")
+	assert.True(t, ok)
+	assert.Equal(t, 2.999, value)
+	value, ok = child.Get("http.route")
+	assert.True(t, ok)
+	assert.Equal(t, "/users/:id", value)
+	_, ok = child.Get("missing")
+	assert.False(t, ok)
+
+	// span events don't carry typed fields
+	assert.Nil(t, events[1].Span)
+
+	// attributes shadow typed fields of the same name
+	root := events[2]
+	value, _ = root.Get("name")
+	assert.Equal(t, "renamed", value)
+	assert.Equal(t, "root", root.Span.Name)
+	_, ok = root.Get("trace.parent_id")
+	assert.False(t, ok)
+}
+
+func TestTypedSpanFieldsEncoding(t *testing.T) {
+	spans := []*trace.Span{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "span"}}
+	expected, err := TranslateTraceRequest(buildTestTraceRequest(nil, spans...), testRequestInfo())
+	require.NoError(t, err)
+	result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, spans...), testRequestInfo(), TranslateOptions{TypedSpanFields: true})
+	require.NoError(t, err)
+
+	expectedJSON, err := json.Marshal(expected.Batches[0].Events[0])
+	require.NoError(t, err)
+	actualJSON, err := json.Marshal(result.Batches[0].Events[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJSON), string(actualJSON))
+
+	expectedMsgpack, err := expected.Batches[0].MarshalMsgpack()
+	require.NoError(t, err)
+	actualMsgpack, err := result.Batches[0].MarshalMsgpack()
+	require.NoError(t, err)
+	assert.Equal(t, len(expectedMsgpack), len(actualMsgpack))
+}
+
+func TestSharedResourceAttributes(t *testing.T) {
+	spans := []*trace.Span{{
+		TraceId:    test.RandomBytes(16),
+		SpanId:     test.RandomBytes(8),
+		Name:       "span",
+		Attributes: []*common.KeyValue{stringAttr("host.name", "span-host"), stringAttr("http.route", "/")},
+		Events:     []*trace.Span_Event{{Name: "event", Attributes: []*common.KeyValue{stringAttr("key", "value")}}},
+		Links:      []*trace.Span_Link{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}},
+	}}
+	resourceAttrs := []*common.KeyValue{
+		stringAttr("service.name", "my-service"),
+		stringAttr("host.name", "resource-host"),
+		stringAttr("name", "resource-name"),
+	}
+
+	for _, opts := range []TranslateOptions{
+		{},
+		{TypedSpanFields: true},
+		{AttributePrecedence: ResourceAttributesFirst},
+	} {
+		expected, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo(), opts)
+		require.NoError(t, err)
+		opts.SharedResourceAttributes = true
+		result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo(), opts)
+		require.NoError(t, err)
+
+		events := result.Batches[0].Events
+		expectedEvents := expected.Batches[0].Events
+		require.Equal(t, 3, len(events))
+		for i := range events {
+			require.NotNil(t, events[i].Inherited)
+			assert.Equal(t, expectedEvents[i].Fields(), events[i].Fields())
+			assert.Equal(t, expectedEvents[i].NumFields(), events[i].NumFields())
+			assert.Equal(t, expectedEvents[i].SizeBytes(), events[i].SizeBytes())
+			assert.NotContains(t, events[i].Attributes, "service.name")
+		}
+		// every event shares the same inherited attributes
+		events[0].Inherited["shared"] = true
+		assert.Equal(t, true, events[2].Inherited["shared"])
+	}
+}
+
+func TestSharedResourceAttributesLogs(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	expected, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	req = buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{SharedResourceAttributes: true})
+	require.NoError(t, err)
+
+	event := result.Batches[0].Events[0]
+	require.NotNil(t, event.Inherited)
+	assert.Equal(t, "my-service", event.Inherited["service.name"])
+	assert.Equal(t, len(expected.Batches[0].Events[0].Attributes), event.NumFields())
+}
+
+func TestSharedResourceAttributesFallsBackToCopying(t *testing.T) {
+	spans := []*trace.Span{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "span"}}
+	// the sample rate has to be removed from each event, so it can't be shared
+	resourceAttrs := []*common.KeyValue{stringAttr("service.name", "my-service"), intAttr("sampleRate", 10)}
+	result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo(), TranslateOptions{SharedResourceAttributes: true})
+	require.NoError(t, err)
+
+	event := result.Batches[0].Events[0]
+	assert.Nil(t, event.Inherited)
+	assert.Equal(t, "my-service", event.Attributes["service.name"])
+	assert.NotContains(t, event.Attributes, "sampleRate")
+	assert.Equal(t, int32(10), event.SampleRate)
+}
+
+func TestEventTypedAccessors(t *testing.T) {
+	event := Event{
+		Attributes: map[string]interface{}{
+			"name":     "span",
+			"count":    3,
+			"big":      uint64(1 << 63),
+			"duration": 1.5,
+			"error":    true,
+		},
+		Values:    []Attribute{{Key: "route", Value: StringValue("/")}},
+		Inherited: map[string]interface{}{"service.name": "my-service"},
+		Span:      &SpanFields{TraceID: "abc", NumLinks: 2},
+	}
+
+	s, ok := event.GetString("name")
+	assert.True(t, ok)
+	assert.Equal(t, "span", s)
+	s, ok = event.GetString("route")
+	assert.True(t, ok)
+	assert.Equal(t, "/", s)
+	s, ok = event.GetString("service.name")
+	assert.True(t, ok)
+	assert.Equal(t, "my-service", s)
+	s, ok = event.GetString("trace.trace_id")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", s)
+	_, ok = event.GetString("count")
+	assert.False(t, ok)
+	_, ok = event.GetString("missing")
+	assert.False(t, ok)
+
+	n, ok := event.GetInt64("count")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), n)
+	n, ok = event.GetInt64("span.num_links")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), n)
+	_, ok = event.GetInt64("big")
+	assert.False(t, ok)
+	_, ok = event.GetInt64("duration")
+	assert.False(t, ok)
+
+	f, ok := event.GetFloat64("duration")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+	f, ok = event.GetFloat64("count")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, f)
+	_, ok = event.GetFloat64("name")
+	assert.False(t, ok)
+
+	b, ok := event.GetBool("error")
+	assert.True(t, ok)
+	assert.True(t, b)
+	_, ok = event.GetBool("name")
+	assert.False(t, ok)
+}
+
+func TestEventSetAttr(t *testing.T) {
+	inherited := map[string]interface{}{"service.name": "my-service"}
+	event := Event{
+		Values:    []Attribute{{Key: "route", Value: StringValue("/")}},
+		Inherited: inherited,
+		Span:      &SpanFields{Name: "span"},
+	}
+	event.SetAttr("route", "/users")
+	event.SetAttr("service.name", "other-service")
+	event.SetAttr("name", "renamed")
+	event.SetAttr("new", int64(1))
+
+	assert.Empty(t, event.Values)
+	assert.Equal(t, "my-service", inherited["service.name"])
+	assert.Equal(t, "span", event.Span.Name)
+	for key, expected := range map[string]interface{}{
+		"route":        "/users",
+		"service.name": "other-service",
+		"name":         "renamed",
+		"new":          int64(1),
+	} {
+		value, ok := event.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, expected, value)
+	}
+	assert.Equal(t, len(event.Fields()), event.NumFields())
+}
@@ -0,0 +1,106 @@
+package otlp
+
+import (
+	"context"
+
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// ResultHandler receives the translated contents of an OTLP export request
+// along with the RequestInfo it was translated with. Returning an error fails
+// the export; OTLPErrors are mapped to their gRPC status code.
+type ResultHandler func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error
+
+// TraceServer implements the OTLP gRPC TraceService by translating each export
+// request and passing the result to its ResultHandler.
+type TraceServer struct {
+	collectorTrace.UnimplementedTraceServiceServer
+	handler ResultHandler
+
+	// Options are applied when translating each request
+	Options TranslateOptions
+
+	// Limiter, if set, bounds how many requests are handled at once
+	Limiter *ConcurrencyLimiter
+
+	// MemoryBudget, if set, bounds the bytes of translated events held by
+	// requests until their handler returns
+	MemoryBudget *MemoryBudget
+}
+
+// NewTraceServer returns a TraceServer that sends translated requests to handler
+func NewTraceServer(handler ResultHandler) *TraceServer {
+	return &TraceServer{handler: handler}
+}
+
+// Export translates an OTLP/gRPC trace request and hands the result to the server's handler
+func (s *TraceServer) Export(ctx context.Context, req *collectorTrace.ExportTraceServiceRequest) (*collectorTrace.ExportTraceServiceResponse, error) {
+	release, err := s.Limiter.acquire(ctx)
+	if err != nil {
+		return nil, AsGRPCError(err)
+	}
+	defer release()
+	reservation, err := reserveMemory(ctx, s.MemoryBudget, int64(proto.Size(req)))
+	if err != nil {
+		return nil, AsGRPCError(err)
+	}
+	defer reservation.release()
+	ri := GetRequestInfoFromGrpcMetadata(ctx)
+	result, err := TranslateTraceRequestWithOptions(req, ri, s.Options.withRequestContext(ctx))
+	if err != nil {
+		return nil, AsGRPCError(err)
+	}
+	reservation.resize(result)
+	if err := s.handler(ctx, ri, result); err != nil {
+		return nil, AsGRPCError(err)
+	}
+	return NewTraceResponse(result), nil
+}
+
+// LogsServer implements the OTLP gRPC LogsService by translating each export
+// request and passing the result to its ResultHandler.
+type LogsServer struct {
+	collectorLogs.UnimplementedLogsServiceServer
+	handler ResultHandler
+
+	// Options are applied when translating each request
+	Options TranslateOptions
+
+	// Limiter, if set, bounds how many requests are handled at once
+	Limiter *ConcurrencyLimiter
+
+	// MemoryBudget, if set, bounds the bytes of translated events held by
+	// requests until their handler returns
+	MemoryBudget *MemoryBudget
+}
+
+// NewLogsServer returns a LogsServer that sends translated requests to handler
+func NewLogsServer(handler ResultHandler) *LogsServer {
+	return &LogsServer{handler: handler}
+}
+
+// Export translates an OTLP/gRPC logs request and hands the result to the server's handler
+func (s *LogsServer) Export(ctx context.Context, req *collectorLogs.ExportLogsServiceRequest) (*collectorLogs.ExportLogsServiceResponse, error) {
+	release, err := s.Limiter.acquire(ctx)
+	if err != nil {
+		return nil, AsGRPCError(err)
+	}
+	defer release()
+	reservation, err := reserveMemory(ctx, s.MemoryBudget, int64(proto.Size(req)))
+	if err != nil {
+		return nil, AsGRPCError(err)
+	}
+	defer reservation.release()
+	ri := GetRequestInfoFromGrpcMetadata(ctx)
+	result, err := TranslateLogsRequestWithOptions(req, ri, s.Options.withRequestContext(ctx))
+	if err != nil {
+		return nil, AsGRPCError(err)
+	}
+	reservation.resize(result)
+	if err := s.handler(ctx, ri, result); err != nil {
+		return nil, AsGRPCError(err)
+	}
+	return NewLogsResponse(result), nil
+}
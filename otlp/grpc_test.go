@@ -0,0 +1,123 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestTraceServerExport(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		apiKeyHeader: "abc123DEF456ghi789jklm",
+	}))
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{
+			ScopeSpans: []*trace.ScopeSpans{{
+				Spans: []*trace.Span{{
+					TraceId: test.RandomBytes(16),
+					SpanId:  test.RandomBytes(8),
+					Name:    "test_span",
+				}},
+			}},
+		}},
+	}
+
+	var got *TranslateOTLPRequestResult
+	server := NewTraceServer(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		assert.Equal(t, "abc123DEF456ghi789jklm", ri.ApiKey)
+		got = result
+		return nil
+	})
+	resp, err := server.Export(ctx, req)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, 1, len(got.Batches))
+	assert.Equal(t, "test_span", got.Batches[0].Events[0].Attributes["name"])
+}
+
+func TestTraceServerExportMissingAPIKey(t *testing.T) {
+	server := NewTraceServer(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		t.Fatal("handler should not be called")
+		return nil
+	})
+	_, err := server.Export(context.Background(), &collectortrace.ExportTraceServiceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestLogsServerExport(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		apiKeyHeader: "abc123DEF456ghi789jklm",
+	}))
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+
+	var got *TranslateOTLPRequestResult
+	server := NewLogsServer(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		got = result
+		return nil
+	})
+	resp, err := server.Export(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, &collectorlogs.ExportLogsServiceResponse{}, resp)
+	assert.Equal(t, 1, len(got.Batches))
+	assert.Equal(t, "my-service", got.Batches[0].Dataset)
+}
+
+func TestLogsServerExportHandlerError(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		apiKeyHeader: "abc123DEF456ghi789jklm",
+	}))
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+
+	server := NewLogsServer(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		return errors.New("sink unavailable")
+	})
+	_, err := server.Export(ctx, req)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestTraceServerCompression(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	var encodings []string
+	grpcServer := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(grpcServer, NewTraceServer(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		encodings = append(encodings, ri.GRPCEncoding)
+		assert.Equal(t, "compressed_span", result.Batches[0].Events[0].Attributes["name"])
+		return nil
+	}))
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := collectortrace.NewTraceServiceClient(conn)
+
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "compressed_span"})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), apiKeyHeader, "abc123DEF456ghi789jklm")
+	for _, compressor := range []string{"", "gzip", "zstd"} {
+		var opts []grpc.CallOption
+		if compressor != "" {
+			opts = append(opts, grpc.UseCompressor(compressor))
+		}
+		_, err := client.Export(ctx, req, opts...)
+		require.NoError(t, err, compressor)
+	}
+	assert.Equal(t, []string{"", "gzip", "zstd"}, encodings)
+}
@@ -0,0 +1,171 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// gRPC service paths, which gRPC-Web clients post to
+const (
+	grpcTraceServicePath = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	grpcLogsServicePath  = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// gRPC-Web frame flags
+	grpcWebCompressedFlag = 0x01
+	grpcWebTrailerFlag    = 0x80
+
+	grpcEncodingHeader = "grpc-encoding"
+
+	// defaultMaxGRPCWebMessageSize caps gRPC-Web frames when MaxDecompressedBodySize
+	// isn't set, since the frame header's length can claim up to 4GiB
+	defaultMaxGRPCWebMessageSize = 64 << 20
+)
+
+func isGRPCWebRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get(contentTypeHeader), grpcWebContentType)
+}
+
+func isGRPCWebTextRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get(contentTypeHeader), grpcWebTextContentType)
+}
+
+// serveGRPCWeb handles a gRPC-Web request, whose body is a single length-prefixed
+// message, base64 encoded for the text variant. Errors are reported in the trailers
+// with a 200 status, as gRPC-Web clients expect.
+func (h *HTTPHandler) serveGRPCWeb(w http.ResponseWriter, r *http.Request, ri RequestInfo, signal *httpSignal, handler ResultHandler) {
+	var body io.Reader = r.Body
+	if isGRPCWebTextRequest(r) {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	// the message has been decoded here, so validate it as protobuf
+	ri.ContentType = "application/protobuf"
+	ri.ContentEncoding = ""
+	ri.GRPCEncoding = r.Header.Get(grpcEncodingHeader)
+
+	var response proto.Message
+	request, err := readGRPCWebMessage(body, ri.GRPCEncoding, signal.newRequest(), h.Options.MaxDecompressedBodySize)
+	if err == nil {
+		var result *TranslateOTLPRequestResult
+		result, err = signal.translateRequest(request, ri, h.Options.withRequestContext(r.Context()))
+		if err == nil {
+			err = handler(r.Context(), ri, result)
+		}
+		if err == nil {
+			response = signal.response(result)
+		}
+	}
+	writeGRPCWebResponse(w, r, response, err)
+}
+
+// writeGRPCWebResponse writes response, if not nil, followed by trailers with the
+// status for err
+func writeGRPCWebResponse(w http.ResponseWriter, r *http.Request, response proto.Message, err error) {
+	var frames []byte
+	if response != nil {
+		message, merr := proto.Marshal(response)
+		if merr != nil {
+			err = merr
+		} else {
+			frames = appendGRPCWebFrame(frames, 0, message)
+		}
+	}
+	s := status.Convert(AsGRPCError(err))
+	if err == nil {
+		s = status.New(codes.OK, "")
+	}
+	trailers := fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", s.Code(), encodeGRPCMessage(s.Message()))
+	frames = appendGRPCWebFrame(frames, grpcWebTrailerFlag, []byte(trailers))
+
+	if isGRPCWebTextRequest(r) {
+		w.Header().Set("Content-Type", grpcWebTextContentType+"+proto")
+		w.WriteHeader(http.StatusOK)
+		encoder := base64.NewEncoder(base64.StdEncoding, w)
+		encoder.Write(frames)
+		encoder.Close()
+		return
+	}
+	w.Header().Set("Content-Type", grpcWebContentType+"+proto")
+	w.WriteHeader(http.StatusOK)
+	w.Write(frames)
+}
+
+// readGRPCWebMessage reads the single data frame of a gRPC-Web request into m
+func readGRPCWebMessage(body io.Reader, grpcEncoding string, m proto.Message, maxSize int64) (proto.Message, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		return nil, ErrFailedParseBody
+	}
+	length := int64(binary.BigEndian.Uint32(header[1:]))
+	frameLimit := maxSize
+	if frameLimit <= 0 {
+		frameLimit = defaultMaxGRPCWebMessageSize
+	}
+	if length > frameLimit {
+		return nil, ErrRequestTooLarge
+	}
+	// the header's length is only a claim, so let the buffer grow with what's actually sent
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(body, length)); err != nil || int64(buf.Len()) != length {
+		return nil, ErrFailedParseBody
+	}
+	message := buf.Bytes()
+	if header[0]&grpcWebCompressedFlag != 0 {
+		compressor := encoding.GetCompressor(grpcEncoding)
+		if compressor == nil {
+			return nil, status.Errorf(codes.Unimplemented, "unsupported grpc-encoding %q", grpcEncoding)
+		}
+		reader, err := compressor.Decompress(bytes.NewReader(message))
+		if err != nil {
+			return nil, ErrFailedParseBody
+		}
+		if maxSize > 0 {
+			reader = io.LimitReader(reader, maxSize+1)
+		}
+		if message, err = io.ReadAll(reader); err != nil {
+			return nil, ErrFailedParseBody
+		}
+		if maxSize > 0 && int64(len(message)) > maxSize {
+			return nil, ErrRequestTooLarge
+		}
+	}
+	if err := proto.Unmarshal(message, m); err != nil {
+		return nil, ErrFailedParseBody
+	}
+	return m, nil
+}
+
+func appendGRPCWebFrame(b []byte, flags byte, data []byte) []byte {
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message value as the gRPC spec requires
+func encodeGRPCMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
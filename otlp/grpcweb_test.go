@@ -0,0 +1,124 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+)
+
+// splitGRPCWebFrames parses a gRPC-Web response body into its data and trailer frames
+func splitGRPCWebFrames(t *testing.T, b []byte) (data [][]byte, trailers string) {
+	for len(b) > 0 {
+		require.GreaterOrEqual(t, len(b), 5)
+		length := binary.BigEndian.Uint32(b[1:5])
+		frame := b[5 : 5+length]
+		if b[0]&grpcWebTrailerFlag != 0 {
+			trailers = string(frame)
+		} else {
+			data = append(data, frame)
+		}
+		b = b[5+length:]
+	}
+	return data, trailers
+}
+
+func TestGRPCWeb(t *testing.T) {
+	var got *TranslateOTLPRequestResult
+	handler := NewHTTPHandler(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		got = result
+		return nil
+	})
+	message, err := proto.Marshal(buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "browser_span"}))
+	require.NoError(t, err)
+
+	var compressed bytes.Buffer
+	writer, err := encoding.GetCompressor("gzip").Compress(&compressed)
+	require.NoError(t, err)
+	writer.Write(message)
+	writer.Close()
+
+	testCases := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+	}{
+		{"binary", "application/grpc-web+proto", "", appendGRPCWebFrame(nil, 0, message)},
+		{"text", "application/grpc-web-text", "", []byte(base64.StdEncoding.EncodeToString(appendGRPCWebFrame(nil, 0, message)))},
+		{"compressed", "application/grpc-web", "gzip", appendGRPCWebFrame(nil, grpcWebCompressedFlag, compressed.Bytes())},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got = nil
+			r := httptest.NewRequest(http.MethodPost, grpcTraceServicePath, bytes.NewReader(tc.body))
+			r.Header.Set(contentTypeHeader, tc.contentType)
+			r.Header.Set(grpcEncodingHeader, tc.encoding)
+			r.Header.Set(apiKeyHeader, "abc123DEF456ghi789jklm")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.Bytes()
+			if strings.HasPrefix(tc.contentType, grpcWebTextContentType) {
+				assert.Equal(t, "application/grpc-web-text+proto", w.Header().Get("Content-Type"))
+				body, err = base64.StdEncoding.DecodeString(w.Body.String())
+				require.NoError(t, err)
+			} else {
+				assert.Equal(t, "application/grpc-web+proto", w.Header().Get("Content-Type"))
+			}
+			data, trailers := splitGRPCWebFrames(t, body)
+			require.Equal(t, 1, len(data))
+			assert.NoError(t, proto.Unmarshal(data[0], &collectortrace.ExportTraceServiceResponse{}))
+			assert.Equal(t, "grpc-status: 0\r\ngrpc-message: \r\n", trailers)
+			require.NotNil(t, got)
+			assert.Equal(t, "browser_span", got.Batches[0].Events[0].Attributes["name"])
+		})
+	}
+
+	// errors go in the trailers
+	r := httptest.NewRequest(http.MethodPost, grpcTraceServicePath, bytes.NewReader(appendGRPCWebFrame(nil, 0, message)))
+	r.Header.Set(contentTypeHeader, "application/grpc-web+proto")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	data, trailers := splitGRPCWebFrames(t, w.Body.Bytes())
+	assert.Empty(t, data)
+	assert.Equal(t, "grpc-status: 16\r\ngrpc-message: missing 'x-honeycomb-team' header\r\n", trailers)
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	assert.Equal(t, "plain message", encodeGRPCMessage("plain message"))
+	assert.Equal(t, "100%25 caf%C3%A9%0A", encodeGRPCMessage("100% café\n"))
+}
+
+func TestReadGRPCWebMessageDistrustsFrameLength(t *testing.T) {
+	message, err := proto.Marshal(&collectortrace.ExportTraceServiceRequest{})
+	require.NoError(t, err)
+
+	// a header claiming 4GiB is rejected without allocating it, even with no maximum set
+	header := []byte{0, 0xff, 0xff, 0xff, 0xff}
+	_, err = readGRPCWebMessage(bytes.NewReader(header), "", &collectortrace.ExportTraceServiceRequest{}, 0)
+	assert.Equal(t, ErrRequestTooLarge, err)
+
+	// a frame shorter than its header claims is malformed
+	frame := appendGRPCWebFrame(nil, 0, []byte("short"))
+	binary.BigEndian.PutUint32(frame[1:5], 1<<20)
+	_, err = readGRPCWebMessage(bytes.NewReader(frame), "", &collectortrace.ExportTraceServiceRequest{}, 0)
+	assert.Equal(t, ErrFailedParseBody, err)
+
+	_, err = readGRPCWebMessage(bytes.NewReader(appendGRPCWebFrame(nil, 0, message)), "", &collectortrace.ExportTraceServiceRequest{}, 0)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,42 @@
+package otlp
+
+// MetricsHooks receives counts from each translated request, so callers can feed
+// their own metrics (e.g. Prometheus counters) without re-walking the result.
+// Methods are called synchronously once translation finishes, so they should be cheap.
+// Embed NoopMetricsHooks to implement only the methods you need.
+type MetricsHooks interface {
+	// SpansTranslated is called with the number of spans translated into events
+	SpansTranslated(n int)
+	// EventsEmitted is called once per batch with its dataset and number of events
+	EventsEmitted(dataset string, n int)
+	// BytesProcessed is called with the size of the OTLP request
+	BytesProcessed(n int)
+	// AttributesDropped is called with the number of span, span event, link and
+	// log record attributes dropped because they had no key or no usable value
+	AttributesDropped(n int)
+}
+
+// NoopMetricsHooks implements MetricsHooks by doing nothing
+type NoopMetricsHooks struct{}
+
+func (NoopMetricsHooks) SpansTranslated(n int)               {}
+func (NoopMetricsHooks) EventsEmitted(dataset string, n int) {}
+func (NoopMetricsHooks) BytesProcessed(n int)                {}
+func (NoopMetricsHooks) AttributesDropped(n int)             {}
+
+// reportMetrics passes a translated request's counts to hooks, if set
+func reportMetrics(hooks MetricsHooks, result *TranslateOTLPRequestResult, spans int, droppedAttributes int) {
+	if hooks == nil {
+		return
+	}
+	if spans > 0 {
+		hooks.SpansTranslated(spans)
+	}
+	for _, batch := range result.Batches {
+		hooks.EventsEmitted(batch.Dataset, len(batch.Events))
+	}
+	hooks.BytesProcessed(result.RequestSize)
+	if droppedAttributes > 0 {
+		hooks.AttributesDropped(droppedAttributes)
+	}
+}
@@ -0,0 +1,65 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+type recordingHooks struct {
+	NoopMetricsHooks
+	spans             int
+	events            map[string]int
+	bytes             int
+	droppedAttributes int
+}
+
+func (h *recordingHooks) SpansTranslated(n int) { h.spans += n }
+func (h *recordingHooks) EventsEmitted(dataset string, n int) {
+	if h.events == nil {
+		h.events = map[string]int{}
+	}
+	h.events[dataset] += n
+}
+func (h *recordingHooks) BytesProcessed(n int)    { h.bytes += n }
+func (h *recordingHooks) AttributesDropped(n int) { h.droppedAttributes += n }
+
+func TestTraceMetricsHooks(t *testing.T) {
+	span := &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Attributes: []*common.KeyValue{
+			stringAttr("kept", "value"),
+			stringAttr("", "no key"),
+			{Key: "no_value"},
+		},
+		Events: []*trace.Span_Event{{Name: "event"}},
+	}
+	req := buildTestTraceRequest([]*common.KeyValue{stringAttr("service.name", "my-service")}, span)
+
+	hooks := &recordingHooks{}
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{Hooks: hooks})
+	require.NoError(t, err)
+	assert.Equal(t, 1, hooks.spans)
+	assert.Equal(t, map[string]int{"my-service": 2}, hooks.events)
+	assert.Equal(t, result.RequestSize, hooks.bytes)
+	assert.Equal(t, 2, hooks.droppedAttributes)
+}
+
+func TestLogsMetricsHooks(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes = append(req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes, &common.KeyValue{Key: "no_value"})
+
+	hooks := &recordingHooks{}
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{Hooks: hooks})
+	require.NoError(t, err)
+	assert.Equal(t, 0, hooks.spans)
+	assert.Equal(t, map[string]int{"my-service": 1}, hooks.events)
+	assert.Equal(t, result.RequestSize, hooks.bytes)
+	assert.Equal(t, 1, hooks.droppedAttributes)
+}
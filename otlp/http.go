@@ -0,0 +1,247 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewTraceResponse returns the ExportTraceServiceResponse for a translated request.
+// Dropped spans and warnings are reported as a partial success, as the OTLP spec asks.
+func NewTraceResponse(result *TranslateOTLPRequestResult) *collectorTrace.ExportTraceServiceResponse {
+	response := &collectorTrace.ExportTraceServiceResponse{}
+	if result != nil && (result.DroppedSpans > 0 || len(result.Warnings) > 0) {
+		response.PartialSuccess = &collectorTrace.ExportTracePartialSuccess{
+			RejectedSpans: int64(result.DroppedSpans),
+			ErrorMessage:  strings.Join(result.Warnings, "; "),
+		}
+	}
+	return response
+}
+
+// NewLogsResponse returns the ExportLogsServiceResponse for a translated request.
+// Dropped log records and warnings are reported as a partial success.
+func NewLogsResponse(result *TranslateOTLPRequestResult) *collectorLogs.ExportLogsServiceResponse {
+	response := &collectorLogs.ExportLogsServiceResponse{}
+	if result != nil && (result.DroppedLogRecords > 0 || len(result.Warnings) > 0) {
+		response.PartialSuccess = &collectorLogs.ExportLogsPartialSuccess{
+			RejectedLogRecords: int64(result.DroppedLogRecords),
+			ErrorMessage:       strings.Join(result.Warnings, "; "),
+		}
+	}
+	return response
+}
+
+// WriteOtlpHttpResponse writes an OTLP/HTTP export response with a 200 status,
+// encoded as JSON if the request was JSON and as protobuf otherwise
+func WriteOtlpHttpResponse(w http.ResponseWriter, ri RequestInfo, response proto.Message) error {
+	return writeOtlpHttpMessage(w, ri, http.StatusOK, response)
+}
+
+// WriteOtlpHttpError writes an OTLP/HTTP error response for err: the status code
+// the OTLP spec gives for it, and a google.rpc.Status body encoded like
+// WriteOtlpHttpResponse encodes responses. RetryableErrors also set Retry-After.
+func WriteOtlpHttpError(w http.ResponseWriter, ri RequestInfo, err error) error {
+	setRetryAfterHeader(w, err)
+	return writeOtlpHttpMessage(w, ri, httpStatusCode(err), status.Convert(AsGRPCError(err)).Proto())
+}
+
+func writeOtlpHttpMessage(w http.ResponseWriter, ri RequestInfo, statusCode int, m proto.Message) error {
+	contentType := responseContentType(ri)
+	var body []byte
+	var err error
+	if contentType == "application/json" {
+		body, err = protojson.Marshal(m)
+	} else {
+		body, err = proto.Marshal(m)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	_, err = w.Write(body)
+	return err
+}
+
+// responseContentType returns the content type to respond with: the request's own,
+// or protobuf if it didn't have a supported one
+func responseContentType(ri RequestInfo) string {
+	if IsContentTypeSupported(ri.ContentType) {
+		return ri.ContentType
+	}
+	return "application/protobuf"
+}
+
+// OTLP/HTTP export paths served by HTTPHandler
+const (
+	TracesPath  = "/v1/traces"
+	MetricsPath = "/v1/metrics"
+	LogsPath    = "/v1/logs"
+)
+
+// HTTPHandler serves OTLP/HTTP trace and log export requests on their standard
+// paths, translating each request and passing the result to its ResultHandler.
+// Metrics aren't translated yet, so /v1/metrics requests fail with
+// ErrMetricsNotSupported rather than a 404 so clients don't retry them.
+// gRPC-Web requests to the OTLP gRPC service paths are served too.
+type HTTPHandler struct {
+	handler ResultHandler
+
+	// Options are applied when translating each request
+	Options TranslateOptions
+
+	// CORS, if set, allows browsers on the given origins to send requests
+	CORS *CORSOptions
+
+	// Limiter, if set, bounds how many requests are handled at once
+	Limiter *ConcurrencyLimiter
+
+	// MemoryBudget, if set, bounds the bytes of translated events held by
+	// requests until their handler returns. Until they've been translated,
+	// requests are estimated by their Content-Length, or by Options'
+	// MaxDecompressedBodySize when that's unknown or the body is compressed.
+	MemoryBudget *MemoryBudget
+}
+
+// NewHTTPHandler returns an HTTPHandler that sends translated requests to handler
+func NewHTTPHandler(handler ResultHandler) *HTTPHandler {
+	return &HTTPHandler{handler: handler}
+}
+
+// httpSignal is how HTTPHandler translates and responds to one signal's requests
+type httpSignal struct {
+	translateBody    func(io.Reader, RequestInfo, TranslateOptions) (*TranslateOTLPRequestResult, error)
+	newRequest       func() proto.Message
+	translateRequest func(proto.Message, RequestInfo, TranslateOptions) (*TranslateOTLPRequestResult, error)
+	response         func(*TranslateOTLPRequestResult) proto.Message
+}
+
+var (
+	traceSignal = &httpSignal{
+		translateBody: TranslateTraceRequestFromReaderNoCloseWithOptions,
+		newRequest:    func() proto.Message { return &collectorTrace.ExportTraceServiceRequest{} },
+		translateRequest: func(m proto.Message, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+			return TranslateTraceRequestWithOptions(m.(*collectorTrace.ExportTraceServiceRequest), ri, opts)
+		},
+		response: func(result *TranslateOTLPRequestResult) proto.Message { return NewTraceResponse(result) },
+	}
+	logsSignal = &httpSignal{
+		translateBody: TranslateLogsRequestFromReaderNoCloseWithOptions,
+		newRequest:    func() proto.Message { return &collectorLogs.ExportLogsServiceRequest{} },
+		translateRequest: func(m proto.Message, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+			return TranslateLogsRequestWithOptions(m.(*collectorLogs.ExportLogsServiceRequest), ri, opts)
+		},
+		response: func(result *TranslateOTLPRequestResult) proto.Message { return NewLogsResponse(result) },
+	}
+)
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var signal *httpSignal
+	switch r.URL.Path {
+	case TracesPath, grpcTraceServicePath:
+		signal = traceSignal
+	case LogsPath, grpcLogsServicePath:
+		signal = logsSignal
+	case MetricsPath:
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.CORS != nil && h.CORS.handle(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodOptions:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ri := GetRequestInfoFromHTTPRequest(r)
+	if signal == nil {
+		WriteOtlpHttpError(w, ri, ErrMetricsNotSupported)
+		return
+	}
+	release, err := h.Limiter.acquire(r.Context())
+	if err != nil {
+		writeHTTPHandlerError(w, r, ri, err)
+		return
+	}
+	defer release()
+	reservation, err := reserveMemory(r.Context(), h.MemoryBudget, h.memoryEstimate(r, ri))
+	if err != nil {
+		writeHTTPHandlerError(w, r, ri, err)
+		return
+	}
+	defer reservation.release()
+	h.serveSignal(w, r, ri, signal, func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		reservation.resize(result)
+		return h.handler(ctx, ri, result)
+	})
+}
+
+// memoryEstimate returns the bytes to reserve for a request before it's translated.
+// Content-Length only bounds uncompressed bodies, so chunked and compressed ones
+// are assumed to be as big as MaxDecompressedBodySize allows, or the largest body
+// buffer if it isn't set. It's capped at the budget so they still fit on their own.
+func (h *HTTPHandler) memoryEstimate(r *http.Request, ri RequestInfo) int64 {
+	compressed := isCompressedEncoding(ri.ContentEncoding) ||
+		(isGRPCWebRequest(r) && isCompressedEncoding(r.Header.Get(grpcEncodingHeader)))
+	if ri.ContentLength > 0 && !compressed {
+		return ri.ContentLength
+	}
+	estimate := h.Options.MaxDecompressedBodySize
+	if estimate <= 0 {
+		estimate = int64(bodyBufferTiers[len(bodyBufferTiers)-1])
+	}
+	if h.MemoryBudget != nil && estimate > h.MemoryBudget.limit {
+		estimate = h.MemoryBudget.limit
+	}
+	return estimate
+}
+
+func isCompressedEncoding(encoding string) bool {
+	return encoding != "" && encoding != "identity"
+}
+
+// writeHTTPHandlerError writes err in the request's protocol
+func writeHTTPHandlerError(w http.ResponseWriter, r *http.Request, ri RequestInfo, err error) {
+	if isGRPCWebRequest(r) {
+		writeGRPCWebResponse(w, r, nil, err)
+		return
+	}
+	WriteOtlpHttpError(w, ri, err)
+}
+
+// serveSignal translates a request and passes the result to handler
+func (h *HTTPHandler) serveSignal(w http.ResponseWriter, r *http.Request, ri RequestInfo, signal *httpSignal, handler ResultHandler) {
+	if isGRPCWebRequest(r) {
+		h.serveGRPCWeb(w, r, ri, signal, handler)
+		return
+	}
+	// the server closes the body
+	result, err := signal.translateBody(r.Body, ri, h.Options.withRequestContext(r.Context()))
+	if err != nil {
+		WriteOtlpHttpError(w, ri, err)
+		return
+	}
+	if err := handler(r.Context(), ri, result); err != nil {
+		WriteOtlpHttpError(w, ri, err)
+		return
+	}
+	WriteOtlpHttpResponse(w, ri, signal.response(result))
+}
@@ -0,0 +1,168 @@
+package otlp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewTraceResponse(t *testing.T) {
+	assert.Nil(t, NewTraceResponse(&TranslateOTLPRequestResult{}).PartialSuccess)
+
+	response := NewTraceResponse(&TranslateOTLPRequestResult{
+		DroppedSpans: 2,
+		Warnings:     []string{"dropped 2 spans without a dataset", "rounded 1 non-integral sample rates"},
+	})
+	require.NotNil(t, response.PartialSuccess)
+	assert.Equal(t, int64(2), response.PartialSuccess.RejectedSpans)
+	assert.Equal(t, "dropped 2 spans without a dataset; rounded 1 non-integral sample rates", response.PartialSuccess.ErrorMessage)
+}
+
+func TestNewLogsResponse(t *testing.T) {
+	assert.Nil(t, NewLogsResponse(&TranslateOTLPRequestResult{}).PartialSuccess)
+
+	response := NewLogsResponse(&TranslateOTLPRequestResult{Warnings: []string{"clamped 1 event timestamps outside the allowed window"}})
+	require.NotNil(t, response.PartialSuccess)
+	assert.Equal(t, int64(0), response.PartialSuccess.RejectedLogRecords)
+	assert.Equal(t, "clamped 1 event timestamps outside the allowed window", response.PartialSuccess.ErrorMessage)
+}
+
+func TestWriteOtlpHttpResponse(t *testing.T) {
+	response := NewTraceResponse(&TranslateOTLPRequestResult{DroppedSpans: 1})
+
+	for _, contentType := range []string{"application/protobuf", "application/x-protobuf", "application/json", ""} {
+		t.Run("content-type="+contentType, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			require.NoError(t, WriteOtlpHttpResponse(w, RequestInfo{ContentType: contentType}, response))
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			decoded := &collectortrace.ExportTraceServiceResponse{}
+			switch contentType {
+			case "application/json":
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				require.NoError(t, protojson.Unmarshal(w.Body.Bytes(), decoded))
+			case "":
+				assert.Equal(t, "application/protobuf", w.Header().Get("Content-Type"))
+				require.NoError(t, proto.Unmarshal(w.Body.Bytes(), decoded))
+			default:
+				assert.Equal(t, contentType, w.Header().Get("Content-Type"))
+				require.NoError(t, proto.Unmarshal(w.Body.Bytes(), decoded))
+			}
+			assert.Equal(t, int64(1), decoded.PartialSuccess.RejectedSpans)
+		})
+	}
+
+	// empty responses are still valid OTLP
+	w := httptest.NewRecorder()
+	require.NoError(t, WriteOtlpHttpResponse(w, RequestInfo{ContentType: "application/json"}, &collectorlogs.ExportLogsServiceResponse{}))
+	assert.Equal(t, "{}", w.Body.String())
+}
+
+func TestWriteOtlpHttpError(t *testing.T) {
+	w := httptest.NewRecorder()
+	require.NoError(t, WriteOtlpHttpError(w, RequestInfo{ContentType: "application/protobuf"}, ErrMissingAPIKeyHeader))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "application/protobuf", w.Header().Get("Content-Type"))
+	decoded := &spb.Status{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), decoded))
+	assert.Equal(t, int32(codes.Unauthenticated), decoded.Code)
+	assert.Equal(t, ErrMissingAPIKeyHeader.Message, decoded.Message)
+
+	w = httptest.NewRecorder()
+	require.NoError(t, WriteOtlpHttpError(w, RequestInfo{ContentType: "application/json"}, ErrFailedParseBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.NoError(t, protojson.Unmarshal(w.Body.Bytes(), decoded))
+	assert.Equal(t, int32(codes.InvalidArgument), decoded.Code)
+}
+
+func TestHTTPHandler(t *testing.T) {
+	var signals []string
+	handler := NewHTTPHandler(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		signals = append(signals, result.Batches[0].Events[0].Attributes["meta.signal_type"].(string))
+		return nil
+	})
+	traceBody, err := prepareOtlpRequestHttpBody(buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}), "application/json", "gzip")
+	require.NoError(t, err)
+	logsBody, err := prepareOtlpRequestHttpBody(buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service"), "application/protobuf", "")
+	require.NoError(t, err)
+
+	newRequest := func(method, path, contentType, encoding, body string) *http.Request {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set(apiKeyHeader, "abc123DEF456ghi789jklm")
+		r.Header.Set(contentTypeHeader, contentType)
+		r.Header.Set(contentEncodingHeader, encoding)
+		return r
+	}
+
+	testCases := []struct {
+		name        string
+		request     *http.Request
+		status      int
+		contentType string
+	}{
+		{"traces", newRequest(http.MethodPost, TracesPath, "application/json", "gzip", traceBody), http.StatusOK, "application/json"},
+		{"logs", newRequest(http.MethodPost, LogsPath, "application/protobuf", "", logsBody), http.StatusOK, "application/protobuf"},
+		{"metrics", newRequest(http.MethodPost, MetricsPath, "application/protobuf", "", ""), http.StatusNotImplemented, "application/protobuf"},
+		{"invalid body", newRequest(http.MethodPost, TracesPath, "application/protobuf", "", "not protobuf"), http.StatusBadRequest, "application/protobuf"},
+		{"options", newRequest(http.MethodOptions, TracesPath, "", "", ""), http.StatusNoContent, ""},
+		{"wrong method", newRequest(http.MethodGet, LogsPath, "", "", ""), http.StatusMethodNotAllowed, "text/plain; charset=utf-8"},
+		{"unknown path", newRequest(http.MethodPost, "/v1/unknown", "application/protobuf", "", ""), http.StatusNotFound, "text/plain; charset=utf-8"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tc.request)
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+	assert.Equal(t, []string{"trace", "log"}, signals)
+
+	// handler errors are written as OTLP errors
+	handler = NewHTTPHandler(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		return status.Error(codes.Unavailable, "sink unavailable")
+	})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, LogsPath, "application/protobuf", "", logsBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHTTPHandlerMemoryEstimate(t *testing.T) {
+	h := NewHTTPHandler(nil)
+	h.MemoryBudget = NewMemoryBudget(1 << 30)
+	estimate := func(header http.Header) int64 {
+		r := httptest.NewRequest(http.MethodPost, TracesPath, nil)
+		r.Header = header
+		return h.memoryEstimate(r, GetRequestInfoFromHTTPRequest(r))
+	}
+
+	assert.Equal(t, int64(1000), estimate(http.Header{"Content-Length": {"1000"}}))
+	assert.Equal(t, int64(1000), estimate(http.Header{"Content-Length": {"1000"}, "Content-Encoding": {"identity"}}))
+	// chunked or compressed bodies could decompress to anything up to the limit
+	largest := int64(bodyBufferTiers[len(bodyBufferTiers)-1])
+	assert.Equal(t, largest, estimate(http.Header{}))
+	assert.Equal(t, largest, estimate(http.Header{"Content-Length": {"1000"}, "Content-Encoding": {"gzip"}}))
+	assert.Equal(t, largest, estimate(http.Header{"Content-Length": {"1000"}, "Content-Type": {"application/grpc-web"}, "Grpc-Encoding": {"gzip"}}))
+
+	h.Options.MaxDecompressedBodySize = 1 << 20
+	assert.Equal(t, int64(1<<20), estimate(http.Header{"Content-Length": {"1000"}, "Content-Encoding": {"zstd"}}))
+	// but never more than the whole budget
+	h.MemoryBudget = NewMemoryBudget(5000)
+	assert.Equal(t, int64(5000), estimate(http.Header{"Content-Encoding": {"zstd"}}))
+}
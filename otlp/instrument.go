@@ -0,0 +1,100 @@
+package otlp
+
+import (
+	"context"
+	"time"
+
+	"github.com/honeycombio/husky"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/honeycombio/husky/otlp"
+
+// instrumentation wraps a single translation in a span and records its metrics
+// when TracerProvider or MeterProvider is set
+type instrumentation struct {
+	ctx    context.Context
+	span   oteltrace.Span
+	meter  metric.Meter
+	signal string
+	start  time.Time
+}
+
+// startInstrumentation starts instrumenting a translation of the given signal,
+// returning nil when self-instrumentation isn't configured
+func startInstrumentation(opts TranslateOptions, signal string) *instrumentation {
+	if opts.TracerProvider == nil && opts.MeterProvider == nil {
+		return nil
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	i := &instrumentation{ctx: ctx, signal: signal, start: time.Now()}
+	if opts.TracerProvider != nil {
+		tracer := opts.TracerProvider.Tracer(instrumentationName, oteltrace.WithInstrumentationVersion(husky.Version))
+		i.ctx, i.span = tracer.Start(ctx, "husky.translate_"+signal, oteltrace.WithAttributes(attribute.String("husky.signal", signal)))
+	}
+	if opts.MeterProvider != nil {
+		i.meter = opts.MeterProvider.Meter(instrumentationName, metric.WithInstrumentationVersion(husky.Version))
+	}
+	return i
+}
+
+// end finishes the span and records metrics for a translation's result
+func (i *instrumentation) end(result *TranslateOTLPRequestResult, err error) {
+	if i == nil {
+		return
+	}
+	var events int
+	if result != nil {
+		for _, batch := range result.Batches {
+			events += len(batch.Events)
+		}
+	}
+	if i.span != nil {
+		if err != nil {
+			i.span.RecordError(err)
+			i.span.SetStatus(otelcodes.Error, err.Error())
+		} else {
+			i.span.SetAttributes(
+				attribute.Int("husky.request_size", result.RequestSize),
+				attribute.Int("husky.batches", len(result.Batches)),
+				attribute.Int("husky.events", events),
+				attribute.Int("husky.warnings", len(result.Warnings)),
+			)
+		}
+		i.span.End()
+	}
+	if i.meter != nil {
+		i.recordMetrics(result, err)
+	}
+}
+
+func (i *instrumentation) recordMetrics(result *TranslateOTLPRequestResult, err error) {
+	signal := attribute.String("husky.signal", i.signal)
+	if duration, herr := i.meter.Float64Histogram("husky.translate.duration",
+		metric.WithUnit("ms"), metric.WithDescription("Time taken to translate an OTLP request")); herr == nil {
+		duration.Record(i.ctx, float64(time.Since(i.start))/float64(time.Millisecond),
+			metric.WithAttributes(signal, attribute.Bool("error", err != nil)))
+	}
+	if result == nil {
+		return
+	}
+	counter, cerr := i.meter.Int64Counter("husky.translate.events",
+		metric.WithUnit("{event}"), metric.WithDescription("Events emitted by translation"))
+	histogram, herr := i.meter.Int64Histogram("husky.translate.batch_events",
+		metric.WithUnit("{event}"), metric.WithDescription("Events per translated batch"))
+	for _, batch := range result.Batches {
+		attrs := metric.WithAttributes(signal, attribute.String("dataset", batch.Dataset))
+		if cerr == nil {
+			counter.Add(i.ctx, int64(len(batch.Events)), attrs)
+		}
+		if herr == nil {
+			histogram.Record(i.ctx, int64(len(batch.Events)), attrs)
+		}
+	}
+}
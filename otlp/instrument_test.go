@@ -0,0 +1,87 @@
+package otlp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// recordingMeter records the instruments husky uses and drops everything else
+type recordingMeter struct {
+	noop.Meter
+	durations int
+	events    map[string]int64
+}
+
+func (m *recordingMeter) Float64Histogram(string, ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return recordingDuration{m: m}, nil
+}
+
+func (m *recordingMeter) Int64Counter(string, ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return recordingEvents{m: m}, nil
+}
+
+type recordingDuration struct {
+	noop.Float64Histogram
+	m *recordingMeter
+}
+
+func (d recordingDuration) Record(context.Context, float64, ...metric.RecordOption) { d.m.durations++ }
+
+type recordingEvents struct {
+	noop.Int64Counter
+	m *recordingMeter
+}
+
+func (e recordingEvents) Add(_ context.Context, n int64, opts ...metric.AddOption) {
+	attrs := metric.NewAddConfig(opts).Attributes()
+	dataset, _ := attrs.Value("dataset")
+	e.m.events[dataset.AsString()] += n
+}
+
+type recordingMeterProvider struct {
+	noop.MeterProvider
+	meter *recordingMeter
+}
+
+func (p recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter { return p.meter }
+
+func TestSelfInstrumentation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	meter := &recordingMeter{events: map[string]int64{}}
+
+	req := buildTestTraceRequest([]*common.KeyValue{stringAttr("service.name", "my-service")},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+	)
+	opts := TranslateOptions{TracerProvider: tracerProvider, MeterProvider: recordingMeterProvider{meter: meter}}
+	_, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Equal(t, 1, len(spans))
+	assert.Equal(t, "husky.translate_traces", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("husky.events", 2))
+	assert.Equal(t, 1, meter.durations)
+	assert.Equal(t, map[string]int64{"my-service": 2}, meter.events)
+
+	// failed translations are marked on the span
+	_, err = TranslateTraceRequestWithOptions(req, RequestInfo{}, opts)
+	require.Error(t, err)
+	spans = recorder.Ended()
+	require.Equal(t, 2, len(spans))
+	assert.Equal(t, otelcodes.Error, spans[1].Status().Code)
+	assert.Equal(t, 2, meter.durations)
+}
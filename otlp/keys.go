@@ -0,0 +1,70 @@
+package otlp
+
+import "regexp"
+
+// KeyType identifies the kind of Honeycomb API key sent with a request
+type KeyType int
+
+const (
+	// KeyTypeUnknown is an empty key or one that doesn't match any known format
+	KeyTypeUnknown KeyType = iota
+	// KeyTypeClassic is a 32 character hex key for a Honeycomb Classic team
+	KeyTypeClassic
+	// KeyTypeClassicIngest is a 64 character ingest key for a Honeycomb Classic team
+	KeyTypeClassicIngest
+	// KeyTypeEnvironment is a 22 character configuration key for an environment
+	KeyTypeEnvironment
+	// KeyTypeEnvironmentIngest is a 64 character ingest key for an environment
+	KeyTypeEnvironmentIngest
+)
+
+var (
+	classicKeyPattern           = regexp.MustCompile("^[0-9a-f]{32}$")
+	classicIngestKeyPattern     = regexp.MustCompile("^hc[a-z]ic_[0-9a-z]{58}$")
+	environmentKeyPattern       = regexp.MustCompile("^[0-9a-zA-Z]{22}$")
+	environmentIngestKeyPattern = regexp.MustCompile("^hc[a-z]ik_[0-9a-z]{58}$")
+)
+
+// GetKeyType classifies an API key by its format. It does not check that the key is valid.
+func GetKeyType(apiKey string) KeyType {
+	switch len(apiKey) {
+	case 32:
+		if classicKeyPattern.MatchString(apiKey) {
+			return KeyTypeClassic
+		}
+	case 22:
+		if environmentKeyPattern.MatchString(apiKey) {
+			return KeyTypeEnvironment
+		}
+	case 64:
+		if classicIngestKeyPattern.MatchString(apiKey) {
+			return KeyTypeClassicIngest
+		}
+		if environmentIngestKeyPattern.MatchString(apiKey) {
+			return KeyTypeEnvironmentIngest
+		}
+	}
+	return KeyTypeUnknown
+}
+
+// IsClassicApiKey reports whether apiKey belongs to a Honeycomb Classic team,
+// whose requests must name their dataset rather than deriving it from service.name
+func IsClassicApiKey(apiKey string) bool {
+	keyType := GetKeyType(apiKey)
+	return keyType == KeyTypeClassic || keyType == KeyTypeClassicIngest
+}
+
+func (k KeyType) String() string {
+	switch k {
+	case KeyTypeClassic:
+		return "classic"
+	case KeyTypeClassicIngest:
+		return "classic_ingest"
+	case KeyTypeEnvironment:
+		return "environment"
+	case KeyTypeEnvironmentIngest:
+		return "environment_ingest"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,39 @@
+package otlp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetKeyType(t *testing.T) {
+	testCases := []struct {
+		name     string
+		apiKey   string
+		expected KeyType
+	}{
+		{name: "empty", apiKey: "", expected: KeyTypeUnknown},
+		{name: "classic", apiKey: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", expected: KeyTypeClassic},
+		{name: "classic with uppercase", apiKey: "A1A1A1A1A1A1A1A1A1A1A1A1A1A1A1A1", expected: KeyTypeUnknown},
+		{name: "classic ingest", apiKey: "hcaic_" + strings.Repeat("1a", 29), expected: KeyTypeClassicIngest},
+		{name: "environment", apiKey: "abc123DEF456ghi789jklm", expected: KeyTypeEnvironment},
+		{name: "environment ingest", apiKey: "hcaik_" + strings.Repeat("1a", 29), expected: KeyTypeEnvironmentIngest},
+		{name: "ingest key with bad prefix", apiKey: "hcaix_" + strings.Repeat("1a", 29), expected: KeyTypeUnknown},
+		{name: "ingest key too short", apiKey: "hcaik_" + strings.Repeat("1a", 28), expected: KeyTypeUnknown},
+		{name: "other", apiKey: "apikey", expected: KeyTypeUnknown},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, GetKeyType(tc.apiKey))
+		})
+	}
+}
+
+func TestIsClassicApiKey(t *testing.T) {
+	assert.True(t, IsClassicApiKey("a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"))
+	assert.True(t, IsClassicApiKey("hcaic_"+strings.Repeat("1a", 29)))
+	assert.False(t, IsClassicApiKey("abc123DEF456ghi789jklm"))
+	assert.False(t, IsClassicApiKey("hcaik_"+strings.Repeat("1a", 29)))
+	assert.False(t, IsClassicApiKey(""))
+}
@@ -0,0 +1,54 @@
+package otlp
+
+import (
+	"context"
+	"time"
+)
+
+// ConcurrencyLimiter bounds how many requests TraceServer, LogsServer and
+// HTTPHandler translate at once, so bursts of traffic can't exhaust memory.
+// Share one limiter between servers to bound them together.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+
+	// Backpressure is called when every slot is taken. Returning nil waits for a
+	// slot until the request's context is done; returning an error rejects the
+	// request with it. By default requests are rejected with NewThrottledError
+	// and a one second retry delay.
+	Backpressure func(ctx context.Context) error
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter allowing max concurrent requests
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire takes a slot, returning a function that releases it. A nil limiter
+// always succeeds.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	if l.Backpressure == nil {
+		return nil, NewThrottledError(time.Second)
+	}
+	if err := l.Backpressure(ctx); err != nil {
+		return nil, err
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,81 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	release, err := limiter.acquire(context.Background())
+	require.NoError(t, err)
+
+	// saturated, so rejected by default
+	_, err = limiter.acquire(context.Background())
+	assert.Equal(t, NewThrottledError(time.Second), err)
+
+	// backpressure can reject with its own error
+	sinkFull := errors.New("sink full")
+	limiter.Backpressure = func(ctx context.Context) error { return sinkFull }
+	_, err = limiter.acquire(context.Background())
+	assert.Equal(t, sinkFull, err)
+
+	// or wait for a slot until the context is done
+	var saturated int
+	limiter.Backpressure = func(ctx context.Context) error {
+		saturated++
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.acquire(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	release, err = limiter.acquire(context.Background())
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, 2, saturated)
+
+	// nil limiters don't limit
+	var unlimited *ConcurrencyLimiter
+	release, err = unlimited.acquire(context.Background())
+	require.NoError(t, err)
+	release()
+}
+
+func TestServersUseLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	release, err := limiter.acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+	handler := func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		t.Fatal("handler should not be called")
+		return nil
+	}
+
+	traceServer := NewTraceServer(handler)
+	traceServer.Limiter = limiter
+	_, err = traceServer.Export(context.Background(), &collectortrace.ExportTraceServiceRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	httpHandler := NewHTTPHandler(handler)
+	httpHandler.Limiter = limiter
+	w := httptest.NewRecorder()
+	httpHandler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, TracesPath, nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
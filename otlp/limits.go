@@ -0,0 +1,127 @@
+package otlp
+
+import (
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// checkTraceRequestLimits enforces the request size and shape limits in opts
+// before any translation work is done
+func checkTraceRequestLimits(request *collectorTrace.ExportTraceServiceRequest, opts TranslateOptions) error {
+	if opts.MaxAttributeDepth == 0 && opts.MaxEventsPerSpan == 0 && opts.MaxSpansPerRequest == 0 {
+		return nil
+	}
+	var spans int
+	for _, resourceSpan := range request.ResourceSpans {
+		if !resourceWithinDepth(resourceSpan.Resource, opts.MaxAttributeDepth) {
+			return ErrAttributeDepthExceeded
+		}
+		for _, scopeSpan := range resourceSpan.ScopeSpans {
+			if !scopeWithinDepth(scopeSpan.Scope, opts.MaxAttributeDepth) {
+				return ErrAttributeDepthExceeded
+			}
+			spans += len(scopeSpan.Spans)
+			if opts.MaxSpansPerRequest > 0 && spans > opts.MaxSpansPerRequest {
+				return ErrTooManySpans
+			}
+			for _, span := range scopeSpan.Spans {
+				if opts.MaxEventsPerSpan > 0 && len(span.Events) > opts.MaxEventsPerSpan {
+					return ErrTooManySpanEvents
+				}
+				if !attributesWithinDepth(span.Attributes, opts.MaxAttributeDepth) {
+					return ErrAttributeDepthExceeded
+				}
+				for _, sevent := range span.Events {
+					if !attributesWithinDepth(sevent.Attributes, opts.MaxAttributeDepth) {
+						return ErrAttributeDepthExceeded
+					}
+				}
+				for _, slink := range span.Links {
+					if !attributesWithinDepth(slink.Attributes, opts.MaxAttributeDepth) {
+						return ErrAttributeDepthExceeded
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkLogsRequestLimits enforces the attribute depth limit in opts on log
+// records, including their bodies
+func checkLogsRequestLimits(request *collectorLogs.ExportLogsServiceRequest, opts TranslateOptions) error {
+	if opts.MaxAttributeDepth == 0 {
+		return nil
+	}
+	for _, resourceLog := range request.ResourceLogs {
+		if !resourceWithinDepth(resourceLog.Resource, opts.MaxAttributeDepth) {
+			return ErrAttributeDepthExceeded
+		}
+		for _, scopeLog := range resourceLog.ScopeLogs {
+			if !scopeWithinDepth(scopeLog.Scope, opts.MaxAttributeDepth) {
+				return ErrAttributeDepthExceeded
+			}
+			for _, log := range scopeLog.LogRecords {
+				if !attributesWithinDepth(log.Attributes, opts.MaxAttributeDepth) ||
+					(log.Body != nil && valueDepth(log.Body, opts.MaxAttributeDepth) > opts.MaxAttributeDepth) {
+					return ErrAttributeDepthExceeded
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func resourceWithinDepth(r *resource.Resource, maxDepth int) bool {
+	return r == nil || attributesWithinDepth(r.Attributes, maxDepth)
+}
+
+func scopeWithinDepth(s *common.InstrumentationScope, maxDepth int) bool {
+	return s == nil || attributesWithinDepth(s.Attributes, maxDepth)
+}
+
+func attributesWithinDepth(attributes []*common.KeyValue, maxDepth int) bool {
+	if maxDepth == 0 {
+		return true
+	}
+	for _, attr := range attributes {
+		if attr.Value != nil && valueDepth(attr.Value, maxDepth) > maxDepth {
+			return false
+		}
+	}
+	return true
+}
+
+// valueDepth returns how many levels of arrays and kvlists a value is nested.
+// It stops descending once the depth passes limit, so hostile payloads can't
+// make it recurse arbitrarily deep.
+func valueDepth(value *common.AnyValue, limit int) int {
+	var children []*common.AnyValue
+	switch v := value.Value.(type) {
+	case *common.AnyValue_ArrayValue:
+		children = v.ArrayValue.GetValues()
+	case *common.AnyValue_KvlistValue:
+		for _, kv := range v.KvlistValue.GetValues() {
+			if kv.Value != nil {
+				children = append(children, kv.Value)
+			}
+		}
+	default:
+		return 0
+	}
+	depth := 1
+	if limit <= 0 {
+		return depth
+	}
+	for _, child := range children {
+		if d := 1 + valueDepth(child, limit-1); d > depth {
+			depth = d
+			if depth > limit {
+				break
+			}
+		}
+	}
+	return depth
+}
@@ -0,0 +1,86 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func nestedKvlist(depth int) *common.AnyValue {
+	value := &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "leaf"}}
+	for i := 0; i < depth; i++ {
+		value = &common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{
+			Values: []*common.KeyValue{{Key: "nested", Value: value}},
+		}}}
+	}
+	return value
+}
+
+func TestValueDepth(t *testing.T) {
+	assert.Equal(t, 0, valueDepth(nestedKvlist(0), 5))
+	assert.Equal(t, 1, valueDepth(nestedKvlist(1), 5))
+	assert.Equal(t, 3, valueDepth(nestedKvlist(3), 5))
+	array := &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+		Values: []*common.AnyValue{nestedKvlist(0), nestedKvlist(2)},
+	}}}
+	assert.Equal(t, 3, valueDepth(array, 5))
+	// stops descending once past the limit
+	assert.Equal(t, 3, valueDepth(nestedKvlist(100), 2))
+}
+
+func TestTraceRequestLimits(t *testing.T) {
+	newSpan := func(events int, attrs ...*common.KeyValue) *trace.Span {
+		span := &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Attributes: attrs}
+		for i := 0; i < events; i++ {
+			span.Events = append(span.Events, &trace.Span_Event{Name: "event"})
+		}
+		return span
+	}
+	nested := &common.KeyValue{Key: "nested", Value: nestedKvlist(3)}
+
+	testCases := []struct {
+		name     string
+		spans    []*trace.Span
+		opts     TranslateOptions
+		expected error
+	}{
+		{name: "no limits", spans: []*trace.Span{newSpan(5, nested), newSpan(0)}},
+		{name: "within limits", spans: []*trace.Span{newSpan(2, nested), newSpan(0)}, opts: TranslateOptions{MaxAttributeDepth: 3, MaxEventsPerSpan: 2, MaxSpansPerRequest: 2}},
+		{name: "too deep", spans: []*trace.Span{newSpan(0, nested)}, opts: TranslateOptions{MaxAttributeDepth: 2}, expected: ErrAttributeDepthExceeded},
+		{name: "too many events", spans: []*trace.Span{newSpan(3)}, opts: TranslateOptions{MaxEventsPerSpan: 2}, expected: ErrTooManySpanEvents},
+		{name: "too many spans", spans: []*trace.Span{newSpan(0), newSpan(0), newSpan(0)}, opts: TranslateOptions{MaxSpansPerRequest: 2}, expected: ErrTooManySpans},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, tc.spans...), testRequestInfo(), tc.opts)
+			if tc.expected != nil {
+				assert.Equal(t, tc.expected, err)
+				assert.Nil(t, result)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, result)
+		})
+	}
+
+	// span event attributes are checked too
+	span := newSpan(1)
+	span.Events[0].Attributes = []*common.KeyValue{nested}
+	_, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, span), testRequestInfo(), TranslateOptions{MaxAttributeDepth: 2})
+	assert.Equal(t, ErrAttributeDepthExceeded, err)
+}
+
+func TestLogsRequestAttributeDepthLimit(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body = nestedKvlist(3)
+
+	_, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{MaxAttributeDepth: 3})
+	assert.NoError(t, err)
+	_, err = TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{MaxAttributeDepth: 2})
+	assert.Equal(t, ErrAttributeDepthExceeded, err)
+}
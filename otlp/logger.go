@@ -0,0 +1,23 @@
+package otlp
+
+// Logger receives debug and warning messages from translation, such as dropped
+// attributes, shortened trace IDs and unrecognised content encodings, so they can
+// go through the host application's logging. Translation never fails because of
+// anything it logs.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+
+// logger returns the configured Logger, or one that discards everything
+func (opts TranslateOptions) logger() Logger {
+	if opts.Logger == nil {
+		return nopLogger{}
+	}
+	return opts.Logger
+}
@@ -0,0 +1,68 @@
+package otlp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type recordingLogger struct {
+	debug []string
+	warn  []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.warn = append(l.warn, fmt.Sprintf(format, args...))
+}
+
+func TestLoggerTraceRequest(t *testing.T) {
+	span := &trace.Span{
+		TraceId:    append(make([]byte, 8), 1, 2, 3, 4, 5, 6, 7, 8),
+		SpanId:     []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Attributes: []*common.KeyValue{{Key: "no_value"}},
+	}
+	logger := &recordingLogger{}
+	_, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, span), testRequestInfo(), TranslateOptions{Logger: logger})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"shortened 1 trace IDs with zero high bits to 64 bits",
+		"dropped 1 attributes without a key or a usable value",
+	}, logger.debug)
+	assert.Empty(t, logger.warn)
+
+	// no messages when IDs are kept at full length
+	logger = &recordingLogger{}
+	span.Attributes = nil
+	_, err = TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, span), testRequestInfo(), TranslateOptions{Logger: logger, FullTraceIDs: true})
+	require.NoError(t, err)
+	assert.Empty(t, logger.debug)
+}
+
+func TestLoggerRequestBody(t *testing.T) {
+	body, err := proto.Marshal(buildTestTraceRequest(nil))
+	require.NoError(t, err)
+	ri := testRequestInfo()
+	ri.ContentEncoding = "br"
+
+	logger := &recordingLogger{}
+	_, err = TranslateTraceRequestFromReaderWithOptions(io.NopCloser(bytes.NewReader(body)), ri, TranslateOptions{Logger: logger})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`unknown content encoding "br", reading body as uncompressed`}, logger.warn)
+
+	logger = &recordingLogger{}
+	_, err = TranslateTraceRequestFromReaderWithOptions(io.NopCloser(bytes.NewReader([]byte("not protobuf"))), testRequestInfo(), TranslateOptions{Logger: logger})
+	assert.Equal(t, ErrFailedParseBody, err)
+	require.Equal(t, 1, len(logger.debug))
+	assert.Contains(t, logger.debug[0], "failed to parse trace request body")
+}
@@ -2,120 +2,347 @@ package otlp
 
 import (
 	"encoding/hex"
+	"fmt"
 	"io"
 	"time"
 
 	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
 	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
 	"google.golang.org/protobuf/proto"
 )
 
+// logFieldCount is roughly how many fields we add to each log event ourselves,
+// on top of resource, scope and log attributes
+const logFieldCount = 14
+
 // TranslateLogsRequestFromReader translates an OTLP log request into Honeycomb-friendly structure from a reader (eg HTTP body)
 // RequestInfo is the parsed information from the gRPC metadata
+// The body is closed exactly once, whether or not translation succeeds
 func TranslateLogsRequestFromReader(body io.ReadCloser, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateLogsRequestFromReaderWithOptions(body, ri, TranslateOptions{})
+}
+
+// TranslateLogsRequestFromReaderWithOptions is like TranslateLogsRequestFromReader
+// but applies the given TranslateOptions
+func TranslateLogsRequestFromReaderWithOptions(body io.ReadCloser, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	defer body.Close()
+	return TranslateLogsRequestFromReaderNoCloseWithOptions(body, ri, opts)
+}
+
+// TranslateLogsRequestFromReaderNoClose is like TranslateLogsRequestFromReader but takes a plain
+// io.Reader and never closes it, for callers that manage the body themselves
+func TranslateLogsRequestFromReaderNoClose(body io.Reader, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateLogsRequestFromReaderNoCloseWithOptions(body, ri, TranslateOptions{})
+}
+
+// TranslateLogsRequestFromReaderNoCloseWithOptions is like TranslateLogsRequestFromReaderNoClose
+// but applies the given TranslateOptions
+func TranslateLogsRequestFromReaderNoCloseWithOptions(body io.Reader, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	ri = opts.allowSniffedContentType(ri)
 	if err := ri.ValidateLogsHeaders(); err != nil {
 		return nil, err
 	}
 	request := &collectorLogs.ExportLogsServiceRequest{}
-	if err := parseOtlpRequestBody(body, ri.ContentType, ri.ContentEncoding, request); err != nil {
+	if err := parseOtlpRequestBody(body, ri, request, opts); err != nil {
+		opts.logger().Debugf("failed to parse logs request body: %v", err)
+		if err == ErrRequestTooLarge {
+			return nil, err
+		}
 		return nil, ErrFailedParseBody
 	}
-	return TranslateLogsRequest(request, ri)
+	return TranslateLogsRequestWithOptions(request, ri, opts)
 }
 
 // TranslateLogsRequest translates an OTLP proto log request into Honeycomb-friendly structure
 // RequestInfo is the parsed information from the gRPC metadata
+// Logs sent by old SDKs as instrumentation_library_logs are moved into the
+// request's ScopeLogs, so the request may be modified
 func TranslateLogsRequest(request *collectorLogs.ExportLogsServiceRequest, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateLogsRequestWithOptions(request, ri, TranslateOptions{})
+}
+
+// TranslateLogsRequestWithOptions is like TranslateLogsRequest but applies the given TranslateOptions
+func TranslateLogsRequestWithOptions(request *collectorLogs.ExportLogsServiceRequest, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	instr := startInstrumentation(opts, "logs")
+	result, err := translateLogsRequest(request, ri, opts)
+	instr.end(result, err)
+	return result, err
+}
+
+func translateLogsRequest(request *collectorLogs.ExportLogsServiceRequest, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
 	if err := ri.ValidateLogsHeaders(); err != nil {
 		return nil, err
 	}
-	batches := []Batch{}
+	labels := startProfilerLabels(opts, "logs", request)
+	defer labels.end()
+	upgradeLegacyLogsRequest(request)
+	if err := checkLogsRequestLimits(request, opts); err != nil {
+		return nil, err
+	}
+	repairedStrings := repairRequestUTF8(request, opts)
+	verifiedKeys, err := verifyAPIKeyOverrides(len(request.ResourceLogs), func(i int) *resource.Resource {
+		return request.ResourceLogs[i].Resource
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	result, opts := opts.acquireResult()
+	batches := opts.newBatches(len(request.ResourceLogs))
+	totalEvents := 0
 	for _, resourceLog := range request.ResourceLogs {
-		var events []Event
+		totalEvents += countLogRecords(resourceLog)
+	}
+	arena := newEventArena(opts.EventBuffer, totalEvents)
+	var eventsBySDK map[TelemetrySDK]int
+	var droppedAttributes int
+	var sampledOutEvents int
+	var truncatedBodies int
+	var rejectedLogs int
+	var droppedLogs int
+	for _, resourceLog := range request.ResourceLogs {
+		var routed routedBatches
 		resourceAttrs := getResourceAttributes(resourceLog.Resource)
-		dataset := getLogsDataset(ri, resourceAttrs)
+		resourceRI, apiKey, ok := verifiedResourceRequestInfo(ri, resourceAttrs, opts, verifiedKeys)
+		if !ok {
+			rejectedLogs += countLogRecords(resourceLog)
+			continue
+		}
+		dataset, ok := popDatasetOverride(resourceAttrs, opts)
+		if !ok {
+			dataset = getLogsDataset(resourceRI, resourceAttrs, opts)
+		}
+		if dataset == "" {
+			droppedLogs += countLogRecords(resourceLog)
+			continue
+		}
+		events := arena.take(countLogRecords(resourceLog))
+		labels.dataset(dataset)
+		addRequestAttributes(resourceAttrs, resourceRI, opts)
+		sdk, hasSDK := getTelemetrySDK(resourceAttrs)
+		if opts.IncludeTelemetrySDK && hasSDK {
+			addTelemetrySDKAttributes(resourceAttrs, sdk)
+		}
 
 		for _, scopeLog := range resourceLog.ScopeLogs {
-			scopeAttrs := getScopeAttributes(scopeLog.Scope)
+			inheritedAttrs := mergeAttributes(resourceAttrs, getScopeAttributes(scopeLog.Scope, opts))
+			shareInherited := canShareAttributes(inheritedAttrs, opts)
 
 			for _, log := range scopeLog.GetLogRecords() {
-				attrs := map[string]interface{}{
-					"severity":         getLogSeverity(log.SeverityNumber),
-					"severity_code":    int(log.SeverityNumber),
-					"meta.signal_type": "log",
-					"flags":            log.Flags,
+				attrs := make(map[string]interface{}, eventMapSize(logFieldCount, inheritedAttrs, shareInherited, log.Attributes, opts))
+				attrs["severity"] = getLogSeverity(log.SeverityNumber, opts.severityLevels())
+				attrs["severity_code"] = int(log.SeverityNumber)
+				attrs["meta.signal_type"] = "log"
+				attrs["flags"] = log.Flags
+				// the low byte of a log's flags holds the W3C trace flags; zero means unset
+				if log.Flags != 0 {
+					attrs["trace.sampled"] = log.Flags&traceFlagSampled != 0
+				}
+				if log.DroppedAttributesCount != 0 {
+					attrs["dropped_attributes_count"] = int64(log.DroppedAttributesCount)
 				}
-				if len(log.TraceId) > 0 {
-					attrs["trace.trace_id"] = BytesToTraceID(log.TraceId)
+				// all-zero IDs mean the log isn't part of a trace
+				if isSetID(log.TraceId) {
+					attrs["trace.trace_id"] = opts.traceID(log.TraceId)
+					addOriginalTraceID(attrs, log.TraceId, opts)
 					// only add meta.annotation_type if the log is associated to a trace
-					attrs["meta.annotation_type"] = "span_event"
+					if !opts.OmitLogAnnotationType {
+						attrs["meta.annotation_type"] = "span_event"
+					}
 				}
-				if len(log.SpanId) > 0 {
+				if isSetID(log.SpanId) {
 					attrs["trace.parent_id"] = hex.EncodeToString(log.SpanId)
 				}
 				if log.SeverityText != "" {
 					attrs["severity_text"] = log.SeverityText
 				}
 				if log.Body != nil {
-					if val, truncatedBytes := getValue(log.Body); val != nil {
-						attrs["body"] = val
+					if val, truncatedBytes := getLogBody(log.Body, opts.MaxLogBodySize); val != nil {
+						bodyField := opts.logBodyField()
+						attrs[bodyField] = val
 						if truncatedBytes != 0 {
 							// if we trim the body, add telemetry about it
 							attrs["meta.truncated_bytes"] = truncatedBytes
-							attrs["meta.truncated_field"] = "body"
+							attrs["meta.truncated_field"] = bodyField
+							truncatedBodies++
 						}
 					}
 				}
 
-				// copy resource & scope attributes then log attributes
-				for k, v := range resourceAttrs {
-					attrs[k] = v
-				}
-				for k, v := range scopeAttrs {
-					attrs[k] = v
-				}
+				// add resource & scope attributes then log attributes
+				inherited := inheritAttributes(attrs, inheritedAttrs, shareInherited)
+				var values []Attribute
 				if log.Attributes != nil {
-					addAttributesToMap(attrs, log.Attributes)
+					droppedAttributes += addTypedAttributes(attrs, opts.typedValues(&values), log.Attributes, opts)
 				}
 
+				logDataset, routeLog := popDatasetOverride(attrs, opts)
+
 				// Now we need to wrap the eventAttrs in an event so we can specify the timestamp
 				// which is the StartTime as a time.Time object
-				timestamp := time.Unix(0, int64(log.TimeUnixNano)).UTC()
-				events = append(events, Event{
+				timestamp := getLogTimestamp(log, attrs, ri)
+				addTimestampNanos(attrs, timestamp, opts)
+				sampleRate, _ := popSampleRateOverride(attrs, opts, ri.SampleRate)
+				event := Event{
 					Attributes: attrs,
+					Inherited:  inherited,
+					Values:     values,
 					Timestamp:  timestamp,
-				})
+					SampleRate: sampleRate,
+				}
+				if opts.Sampler != nil {
+					var traceID string
+					if isSetID(log.TraceId) {
+						traceID = opts.traceID(log.TraceId)
+					}
+					if !sampleEvent(opts.Sampler, traceID, &event) {
+						sampledOutEvents++
+						continue
+					}
+				}
+				if routeLog && logDataset != dataset {
+					routed.add(logDataset, proto.Size(log), []Event{event})
+				} else {
+					events = append(events, event)
+				}
 			}
 		}
 		batches = append(batches, Batch{
 			Dataset:   dataset,
-			SizeBytes: proto.Size(resourceLog),
+			SizeBytes: proto.Size(resourceLog) - routed.sizeBytes(),
 			Events:    events,
+			APIKey:    apiKey,
+			Team:      resourceRI.Team,
 		})
+		for i := range routed.batches {
+			routed.batches[i].APIKey = apiKey
+			routed.batches[i].Team = resourceRI.Team
+		}
+		batches = append(batches, routed.batches...)
+		if opts.IncludeTelemetrySDK && hasSDK {
+			if eventsBySDK == nil {
+				eventsBySDK = map[TelemetrySDK]int{}
+			}
+			eventsBySDK[sdk] += len(events) + routed.eventCount()
+		}
+	}
+	var warnings []string
+	if clamped := clampEventTimestamps(batches, ri.now(), opts.TimestampTolerance); clamped > 0 {
+		warnings = append(warnings, fmt.Sprintf("clamped %d event timestamps outside the allowed window", clamped))
+	}
+	if truncatedBodies > 0 {
+		warnings = append(warnings, fmt.Sprintf("truncated %d log bodies to %d bytes", truncatedBodies, logBodySizeLimit(opts.MaxLogBodySize)))
+	}
+	if rejectedLogs > 0 {
+		warnings = append(warnings, fmt.Sprintf("dropped %d log records with a rejected API key override", rejectedLogs))
+	}
+	if droppedLogs > 0 {
+		warnings = append(warnings, fmt.Sprintf("dropped %d log records without a dataset", droppedLogs))
+	}
+	if repairedStrings > 0 {
+		warnings = append(warnings, fmt.Sprintf("replaced invalid UTF-8 in %d strings", repairedStrings))
+	}
+	if droppedAttributes > 0 {
+		opts.logger().Debugf("dropped %d attributes without a key or a usable value", droppedAttributes)
+	}
+	sanitizeBatchDatasets(batches, opts)
+	warnings = append(warnings, foldOverflowColumns(batches, opts)...)
+	oversizedEvents, warning := applyOversizedEventPolicy(batches, opts)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	applyMetaFieldOptions(batches, opts)
+	*result = TranslateOTLPRequestResult{
+		RequestSize:          proto.Size(request),
+		Batches:              batches,
+		EventsByTelemetrySDK: eventsBySDK,
+		DroppedLogRecords:    rejectedLogs + droppedLogs,
+		OversizedEvents:      oversizedEvents,
+		SampledOutEvents:     sampledOutEvents,
+		Team:                 ri.Team,
+		Warnings:             warnings,
+	}
+	if opts.PoolResults {
+		result.events = arena.buf
+	}
+	reportMetrics(opts.Hooks, result, 0, droppedAttributes)
+	return result, nil
+}
+
+// getLogBody returns a log record's body as an event field value. Structured
+// bodies are JSON-encoded; if maxSize is set, bodies of any type are capped at it.
+func getLogBody(body *common.AnyValue, maxSize int) (interface{}, int) {
+	if maxSize <= 0 {
+		return getValue(body)
+	}
+	if s, ok := body.Value.(*common.AnyValue_StringValue); ok {
+		truncated := truncateString(s.StringValue, maxSize)
+		return truncated, len(s.StringValue) - len(truncated)
+	}
+	return getLimitedValue(body, maxSize)
+}
+
+// logBodySizeLimit returns the size structured log bodies are capped at
+func logBodySizeLimit(maxSize int) int {
+	if maxSize > 0 {
+		return maxSize
+	}
+	return fieldSizeMax
+}
+
+func countLogRecords(resourceLog *logs.ResourceLogs) int {
+	n := 0
+	for _, scopeLog := range resourceLog.ScopeLogs {
+		n += len(scopeLog.LogRecords)
+	}
+	return n
+}
+
+// isSetID reports whether a log record's trace or span ID is present and not all zeros
+func isSetID(id []byte) bool {
+	return len(id) > 0 && !isAllZero(id)
+}
+
+// getLogTimestamp returns when a log record happened: its time, or its observed
+// time if that's unset, or failing both when the request was received. Fallbacks
+// are recorded in the record's meta.timestamp_source.
+func getLogTimestamp(log *logs.LogRecord, attrs map[string]interface{}, ri RequestInfo) time.Time {
+	if log.TimeUnixNano != 0 {
+		return time.Unix(0, int64(log.TimeUnixNano)).UTC()
 	}
-	return &TranslateOTLPRequestResult{
-		RequestSize: proto.Size(request),
-		Batches:     batches,
-	}, nil
+	if log.ObservedTimeUnixNano != 0 {
+		attrs["meta.timestamp_source"] = "observed_time"
+		return time.Unix(0, int64(log.ObservedTimeUnixNano)).UTC()
+	}
+	attrs["meta.timestamp_source"] = "received_time"
+	return ri.now().UTC()
+}
+
+// SeverityLevel names the level of log records with severity numbers from Min to Max inclusive
+type SeverityLevel struct {
+	Min   logs.SeverityNumber
+	Max   logs.SeverityNumber
+	Level string
+}
+
+// DefaultSeverityLevels are the levels of the OTLP severity number ranges
+var DefaultSeverityLevels = []SeverityLevel{
+	{Min: logs.SeverityNumber_SEVERITY_NUMBER_TRACE, Max: logs.SeverityNumber_SEVERITY_NUMBER_TRACE4, Level: "trace"},
+	{Min: logs.SeverityNumber_SEVERITY_NUMBER_DEBUG, Max: logs.SeverityNumber_SEVERITY_NUMBER_DEBUG4, Level: "debug"},
+	{Min: logs.SeverityNumber_SEVERITY_NUMBER_INFO, Max: logs.SeverityNumber_SEVERITY_NUMBER_INFO4, Level: "info"},
+	{Min: logs.SeverityNumber_SEVERITY_NUMBER_WARN, Max: logs.SeverityNumber_SEVERITY_NUMBER_WARN4, Level: "warn"},
+	{Min: logs.SeverityNumber_SEVERITY_NUMBER_ERROR, Max: logs.SeverityNumber_SEVERITY_NUMBER_ERROR4, Level: "error"},
+	{Min: logs.SeverityNumber_SEVERITY_NUMBER_FATAL, Max: logs.SeverityNumber_SEVERITY_NUMBER_FATAL4, Level: "fatal"},
 }
 
-func getLogSeverity(severity logs.SeverityNumber) string {
-	switch severity {
-	case logs.SeverityNumber_SEVERITY_NUMBER_TRACE, logs.SeverityNumber_SEVERITY_NUMBER_TRACE2, logs.SeverityNumber_SEVERITY_NUMBER_TRACE3, logs.SeverityNumber_SEVERITY_NUMBER_TRACE4:
-		return "trace"
-	case logs.SeverityNumber_SEVERITY_NUMBER_DEBUG, logs.SeverityNumber_SEVERITY_NUMBER_DEBUG2, logs.SeverityNumber_SEVERITY_NUMBER_DEBUG3, logs.SeverityNumber_SEVERITY_NUMBER_DEBUG4:
-		return "debug"
-	case logs.SeverityNumber_SEVERITY_NUMBER_INFO, logs.SeverityNumber_SEVERITY_NUMBER_INFO2, logs.SeverityNumber_SEVERITY_NUMBER_INFO3, logs.SeverityNumber_SEVERITY_NUMBER_INFO4:
-		return "info"
-	case logs.SeverityNumber_SEVERITY_NUMBER_WARN, logs.SeverityNumber_SEVERITY_NUMBER_WARN2, logs.SeverityNumber_SEVERITY_NUMBER_WARN3, logs.SeverityNumber_SEVERITY_NUMBER_WARN4:
-		return "warn"
-	case logs.SeverityNumber_SEVERITY_NUMBER_ERROR, logs.SeverityNumber_SEVERITY_NUMBER_ERROR2, logs.SeverityNumber_SEVERITY_NUMBER_ERROR3, logs.SeverityNumber_SEVERITY_NUMBER_ERROR4:
-		return "error"
-	case logs.SeverityNumber_SEVERITY_NUMBER_FATAL, logs.SeverityNumber_SEVERITY_NUMBER_FATAL2, logs.SeverityNumber_SEVERITY_NUMBER_FATAL3, logs.SeverityNumber_SEVERITY_NUMBER_FATAL4:
-		return "fatal"
-	case logs.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
-		fallthrough
-	default:
-		return "unspecified"
+// getLogSeverity returns the level of the first range containing severity, or
+// "unspecified" if none do
+func getLogSeverity(severity logs.SeverityNumber, levels []SeverityLevel) string {
+	for _, level := range levels {
+		if severity >= level.Min && severity <= level.Max {
+			return level.Level
+		}
 	}
+	return "unspecified"
 }
@@ -2,7 +2,9 @@ package otlp
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -39,13 +41,12 @@ func TestTranslateLogsRequest(t *testing.T) {
 				Dataset:     "legacy-dataset",
 				ContentType: "application/protobuf",
 			},
-			expectedDataset: testServiceName,
+			expectedDataset: "legacy-dataset",
 		},
 		{
 			Name: "E&S",
 			ri: RequestInfo{
 				ApiKey:      "abc123DEF456ghi789jklm",
-				Dataset:     "legacy-dataset",
 				ContentType: "application/protobuf",
 			},
 			expectedDataset: testServiceName,
@@ -99,13 +100,12 @@ func TestTranslateHttpLogsRequest(t *testing.T) {
 				ApiKey:  "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1",
 				Dataset: "legacy-dataset",
 			},
-			expectedDataset: testServiceName,
+			expectedDataset: "legacy-dataset",
 		},
 		{
 			Name: "E&S",
 			ri: RequestInfo{
-				ApiKey:  "abc123DEF456ghi789jklm",
-				Dataset: "legacy-dataset",
+				ApiKey: "abc123DEF456ghi789jklm",
 			},
 			expectedDataset: testServiceName,
 		},
@@ -185,6 +185,8 @@ func TestLogs_DetermineDestinationDataset(t *testing.T) {
 						datasetHeader   string
 						testServiceName string
 						expectedDataset string
+						// expectedLegacyDataset is used instead for Classic keys when set
+						expectedLegacyDataset string
 					}{
 						{
 							desc:            "when no service.name or dataset header are present, use our fallback",
@@ -198,10 +200,11 @@ func TestLogs_DetermineDestinationDataset(t *testing.T) {
 							expectedDataset: "unknown_log_source",
 						},
 						{
-							desc:            "when service.name is set to something non-default and there is no dataset header, use the service.name",
-							datasetHeader:   "",
-							testServiceName: "awesome_service",
-							expectedDataset: "awesome_service",
+							desc:                  "when service.name is set to something non-default and there is no dataset header, use the service.name unless the key is Classic",
+							datasetHeader:         "",
+							testServiceName:       "awesome_service",
+							expectedDataset:       "awesome_service",
+							expectedLegacyDataset: "unknown_log_source",
 						},
 						{
 							desc:            "when dataset header is set and there is no service.name, use the dataset header",
@@ -210,10 +213,10 @@ func TestLogs_DetermineDestinationDataset(t *testing.T) {
 							expectedDataset: "a_dataset_set_for_the_data",
 						},
 						{
-							desc:            "when both dataset and service.name are set, use the service.name",
+							desc:            "when both dataset and service.name are set, use the dataset header",
 							datasetHeader:   "a_dataset_set_for_the_data",
 							testServiceName: "awesome_service",
-							expectedDataset: "awesome_service",
+							expectedDataset: "a_dataset_set_for_the_data",
 						},
 						{
 							desc:            "when dataset header is set and the service.name is OTel SDK default, use the dataset header",
@@ -250,8 +253,12 @@ func TestLogs_DetermineDestinationDataset(t *testing.T) {
 								t.Errorf("lolwut - What kind of protocol is %v?", protocol)
 							}
 
+							expected := tC.expectedDataset
+							if env.Name == "Classic" && tC.expectedLegacyDataset != "" {
+								expected = tC.expectedLegacyDataset
+							}
 							batch := result.Batches[0]
-							assert.Equal(t, tC.expectedDataset, batch.Dataset)
+							assert.Equal(t, expected, batch.Dataset)
 						})
 					}
 				})
@@ -390,6 +397,111 @@ func TestCanExtractBody(t *testing.T) {
 	}
 }
 
+func TestLogSeverityLevelsOption(t *testing.T) {
+	levels := []SeverityLevel{
+		{Min: logs.SeverityNumber_SEVERITY_NUMBER_TRACE, Max: logs.SeverityNumber_SEVERITY_NUMBER_INFO4, Level: "low"},
+		{Min: logs.SeverityNumber_SEVERITY_NUMBER_WARN, Max: logs.SeverityNumber_SEVERITY_NUMBER_WARN4, Level: "warning"},
+	}
+	testCases := []struct {
+		severity logs.SeverityNumber
+		expected string
+	}{
+		{severity: logs.SeverityNumber_SEVERITY_NUMBER_DEBUG2, expected: "low"},
+		{severity: logs.SeverityNumber_SEVERITY_NUMBER_WARN3, expected: "warning"},
+		{severity: logs.SeverityNumber_SEVERITY_NUMBER_ERROR, expected: "unspecified"},
+	}
+	for _, tc := range testCases {
+		req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+		req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].SeverityNumber = tc.severity
+
+		result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{SeverityLevels: levels})
+		require.NoError(t, err)
+		attrs := result.Batches[0].Events[0].Attributes
+		assert.Equal(t, tc.expected, attrs["severity"])
+		assert.Equal(t, int(tc.severity), attrs["severity_code"])
+	}
+}
+
+func TestLogTimestampFallback(t *testing.T) {
+	logTime := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	observedTime := logTime.Add(time.Second)
+	receivedAt := logTime.Add(time.Minute)
+	testCases := []struct {
+		name           string
+		time, observed time.Time
+		expected       time.Time
+		expectedSource interface{}
+	}{
+		{name: "time", time: logTime, observed: observedTime, expected: logTime},
+		{name: "observed time", observed: observedTime, expected: observedTime, expectedSource: "observed_time"},
+		{name: "received time", expected: receivedAt, expectedSource: "received_time"},
+	}
+	unixNano := func(t time.Time) uint64 {
+		if t.IsZero() {
+			return 0
+		}
+		return uint64(t.UnixNano())
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+			log := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+			log.TimeUnixNano = unixNano(tc.time)
+			log.ObservedTimeUnixNano = unixNano(tc.observed)
+			ri := testRequestInfo()
+			ri.ReceivedAt = receivedAt
+
+			result, err := TranslateLogsRequest(req, ri)
+			require.NoError(t, err)
+			ev := result.Batches[0].Events[0]
+			assert.Equal(t, tc.expected, ev.Timestamp)
+			assert.Equal(t, tc.expectedSource, ev.Attributes["meta.timestamp_source"])
+		})
+	}
+}
+
+func TestLogBodyOptions(t *testing.T) {
+	kvlist := &common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{
+		Values: []*common.KeyValue{{Key: "message", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "hello world"}}}},
+	}}}
+	testCases := []struct {
+		name           string
+		body           *common.AnyValue
+		opts           TranslateOptions
+		field          string
+		expectedValue  interface{}
+		truncatedBytes int
+	}{
+		{name: "custom field", body: kvlist, opts: TranslateOptions{LogBodyField: "message"}, field: "message", expectedValue: "{\"message\":\"hello world\"}\n"},
+		{name: "string within limit", body: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "short"}}, opts: TranslateOptions{MaxLogBodySize: 5}, field: "body", expectedValue: "short"},
+		{name: "string truncated", body: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "héllo world"}}, opts: TranslateOptions{MaxLogBodySize: 2}, field: "body", expectedValue: "h", truncatedBytes: 11},
+		{name: "structured truncated", body: kvlist, opts: TranslateOptions{MaxLogBodySize: 10, LogBodyField: "message"}, field: "message", expectedValue: "{\"message\"", truncatedBytes: 16},
+		{name: "numbers are never truncated", body: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 123456}}, opts: TranslateOptions{MaxLogBodySize: 2}, field: "body", expectedValue: int64(123456)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+			req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body = tc.body
+
+			result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), tc.opts)
+			require.NoError(t, err)
+			attrs := result.Batches[0].Events[0].Attributes
+			assert.Equal(t, tc.expectedValue, attrs[tc.field])
+			if tc.field != "body" {
+				assert.NotContains(t, attrs, "body")
+			}
+			if tc.truncatedBytes == 0 {
+				assert.NotContains(t, attrs, "meta.truncated_bytes")
+				assert.Empty(t, result.Warnings)
+				return
+			}
+			assert.Equal(t, tc.truncatedBytes, attrs["meta.truncated_bytes"])
+			assert.Equal(t, tc.field, attrs["meta.truncated_field"])
+			assert.Equal(t, []string{fmt.Sprintf("truncated 1 log bodies to %d bytes", tc.opts.MaxLogBodySize)}, result.Warnings)
+		})
+	}
+}
+
 func TestLogsRequestWithInvalidContentTypeReturnsError(t *testing.T) {
 	req := &collectorlogs.ExportLogsServiceRequest{}
 	ri := RequestInfo{
@@ -415,6 +527,33 @@ func TestLogsRequestWithInvalidBodyReturnsError(t *testing.T) {
 	assert.Equal(t, ErrFailedParseBody, err)
 }
 
+func TestLogTraceCorrelationFields(t *testing.T) {
+	traceID, spanID := test.RandomBytes(16), test.RandomBytes(8)
+	req := buildExportLogsServiceRequest(traceID, spanID, time.Now(), "my-service")
+
+	result, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, BytesToTraceID(traceID), attrs["trace.trace_id"])
+	assert.Equal(t, hex.EncodeToString(spanID), attrs["trace.parent_id"])
+	assert.Equal(t, "span_event", attrs["meta.annotation_type"])
+
+	result, err = TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{OmitLogAnnotationType: true})
+	require.NoError(t, err)
+	attrs = result.Batches[0].Events[0].Attributes
+	assert.Equal(t, BytesToTraceID(traceID), attrs["trace.trace_id"])
+	assert.NotContains(t, attrs, "meta.annotation_type")
+
+	// all-zero IDs aren't part of a trace
+	req = buildExportLogsServiceRequest(make([]byte, 16), make([]byte, 8), time.Now(), "my-service")
+	result, err = TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	attrs = result.Batches[0].Events[0].Attributes
+	assert.NotContains(t, attrs, "trace.trace_id")
+	assert.NotContains(t, attrs, "trace.parent_id")
+	assert.NotContains(t, attrs, "meta.annotation_type")
+}
+
 func TestLogsWithoutTraceIdDoesNotGetAnnotationType(t *testing.T) {
 	startTimestamp := time.Now()
 
@@ -517,3 +656,138 @@ func buildExportLogsServiceRequest(traceID []byte, spanID []byte, startTimestamp
 
 	return req
 }
+
+func TestLogsUserAgentIsAddedWhenEnabled(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	ri := testRequestInfo()
+	ri.UserAgent = "OTel-OTLP-Exporter-Go/1.11.1"
+
+	result, err := TranslateLogsRequestWithOptions(req, ri, TranslateOptions{IncludeUserAgent: true})
+	require.NoError(t, err)
+	assert.Equal(t, "OTel-OTLP-Exporter-Go/1.11.1", result.Batches[0].Events[0].Attributes["meta.otlp.user_agent"])
+}
+
+func TestLogsDatasetResolverOverridesDefaultRouting(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	resolver := DatasetResolverFunc(func(ri RequestInfo, resourceAttrs map[string]interface{}) string {
+		return "logs-" + resourceAttrs["service.name"].(string)
+	})
+
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{DatasetResolver: resolver})
+	require.NoError(t, err)
+	assert.Equal(t, "logs-my-service", result.Batches[0].Dataset)
+}
+
+func TestLogsWithoutADatasetAreDropped(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	resolver := DatasetResolverFunc(func(ri RequestInfo, resourceAttrs map[string]interface{}) string {
+		return ""
+	})
+
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{DatasetResolver: resolver})
+	require.NoError(t, err)
+	assert.Empty(t, result.Batches)
+	assert.Equal(t, 1, result.DroppedLogRecords)
+	assert.Contains(t, result.Warnings, "dropped 1 log records without a dataset")
+	assert.Equal(t, int64(1), NewLogsResponse(result).PartialSuccess.RejectedLogRecords)
+}
+
+func TestLogsResourceAPIKeyOverrideAttribute(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	resource := req.ResourceLogs[0].Resource
+	resource.Attributes = append(resource.Attributes, &common.KeyValue{
+		Key:   "honeycomb.api_key",
+		Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "tenant-key"}},
+	})
+
+	opts := TranslateOptions{
+		APIKeyAttribute: "honeycomb.api_key",
+		APIKeyResolver: TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+			return Team{Slug: "tenant"}, nil
+		}),
+	}
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "tenant-key", result.Batches[0].APIKey)
+	assert.Equal(t, &Team{Slug: "tenant"}, result.Batches[0].Team)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "honeycomb.api_key")
+}
+
+func TestLogsDatasetOverrideAttributes(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	scopeLog := req.ResourceLogs[0].ScopeLogs[0]
+	scopeLog.LogRecords = append(scopeLog.LogRecords, &logs.LogRecord{
+		Body:       &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "audit"}},
+		Attributes: []*common.KeyValue{stringAttr("honeycomb.dataset", "audit-logs")},
+	})
+
+	result, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "my-service", result.Batches[0].Dataset)
+	assert.Equal(t, 1, len(result.Batches[0].Events))
+	assert.Equal(t, "audit-logs", result.Batches[1].Dataset)
+	require.Equal(t, 1, len(result.Batches[1].Events))
+	assert.Equal(t, "audit", result.Batches[1].Events[0].Attributes["body"])
+	assert.NotContains(t, result.Batches[1].Events[0].Attributes, "honeycomb.dataset")
+	assert.Equal(t, []string{"audit-logs", "my-service"}, PeekDatasets(req, testRequestInfo(), TranslateOptions{}))
+
+	stats, err := LogsRequestStats(req, testRequestInfo(), TranslateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"my-service": 1, "audit-logs": 1}, stats.EventsByDataset)
+
+	req = buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	resource := req.ResourceLogs[0].Resource
+	resource.Attributes = append(resource.Attributes, stringAttr("honeycomb.dataset", "all-logs"))
+	result, err = TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "all-logs", result.Batches[0].Dataset)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "honeycomb.dataset")
+	assert.Equal(t, []string{"all-logs"}, PeekDatasets(req, testRequestInfo(), TranslateOptions{}))
+}
+
+func TestLogsUnknownLogSourceDatasetOption(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "")
+	ri := RequestInfo{ApiKey: "abc123DEF456ghi789jklm", ContentType: "application/protobuf"}
+
+	result, err := TranslateLogsRequestWithOptions(req, ri, TranslateOptions{UnknownLogSourceDataset: "stray-logs"})
+	require.NoError(t, err)
+	assert.Equal(t, "stray-logs", result.Batches[0].Dataset)
+
+	result, err = TranslateLogsRequest(req, ri)
+	require.NoError(t, err)
+	assert.Equal(t, "unknown_log_source", result.Batches[0].Dataset)
+}
+
+func TestLogFlagsAndDroppedAttributesCount(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	scopeLog := req.ResourceLogs[0].ScopeLogs[0]
+	scopeLog.LogRecords = append(scopeLog.LogRecords,
+		&logs.LogRecord{Flags: 0x01, DroppedAttributesCount: 3},
+		&logs.LogRecord{Flags: 0x02},
+	)
+
+	result, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 3, len(events))
+	assert.NotContains(t, events[0].Attributes, "trace.sampled")
+	assert.NotContains(t, events[0].Attributes, "dropped_attributes_count")
+	assert.Equal(t, uint32(0x01), events[1].Attributes["flags"])
+	assert.Equal(t, true, events[1].Attributes["trace.sampled"])
+	assert.Equal(t, int64(3), events[1].Attributes["dropped_attributes_count"])
+	assert.Equal(t, false, events[2].Attributes["trace.sampled"])
+	assert.NotContains(t, events[2].Attributes, "dropped_attributes_count")
+}
+
+func TestLogsIncludeTimestampNanos(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	log := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	log.TimeUnixNano = 1667822400123456789
+
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeTimestampNanos: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1667822400123456789), result.Batches[0].Events[0].Attributes["timestamp_ns"])
+}
@@ -0,0 +1,302 @@
+package otlp
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// eventJSON mirrors the event format of Honeycomb's batch API
+type eventJSON struct {
+	Time       string                 `json:"time"`
+	SampleRate int32                  `json:"samplerate"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// MarshalJSON encodes the event in the format of Honeycomb's batch API, with the
+// timestamp as an RFC 3339 string with nanosecond precision
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{
+		Time:       e.Timestamp.UTC().Format(time.RFC3339Nano),
+		SampleRate: e.SampleRate,
+		Data:       e.Fields(),
+	})
+}
+
+// UnmarshalJSON decodes an event encoded by MarshalJSON. Whole numbers in the
+// attributes are decoded as int64 and all other numbers as float64, so a float
+// attribute with no fractional part comes back as an int64.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Time       string              `json:"time"`
+		SampleRate int32               `json:"samplerate"`
+		Data       jsoniter.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var timestamp time.Time
+	if raw.Time != "" {
+		var err error
+		if timestamp, err = time.Parse(time.RFC3339Nano, raw.Time); err != nil {
+			return err
+		}
+	}
+	var attrs map[string]interface{}
+	if len(raw.Data) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(raw.Data))
+		dec.UseNumber()
+		if err := dec.Decode(&attrs); err != nil {
+			return err
+		}
+		for k, v := range attrs {
+			if n, ok := v.(stdjson.Number); ok {
+				attrs[k] = numberValue(n)
+			}
+		}
+	}
+	*e = Event{
+		Attributes: attrs,
+		Timestamp:  timestamp,
+		SampleRate: raw.SampleRate,
+	}
+	return nil
+}
+
+// numberValue converts a decoded JSON number back to the int64 or float64 we translate numbers to
+func numberValue(n stdjson.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// JSONSizeBytes returns the exact number of bytes MarshalJSON encodes the event to,
+// which is what Honeycomb's per-event size limits apply to. Common attribute types
+// are sized without encoding them.
+func (e Event) JSONSizeBytes() int {
+	var tsBuf [64]byte
+	ts := e.Timestamp.UTC().AppendFormat(tsBuf[:0], time.RFC3339Nano)
+	var numBuf [32]byte
+	size := len(`{"time":`) + jsonStringSize(string(ts)) +
+		len(`,"samplerate":`) + len(strconv.AppendInt(numBuf[:0], int64(e.SampleRate), 10)) +
+		len(`,"data":`) + len(`}`)
+	if e.Attributes == nil && e.Span == nil && e.Inherited == nil && len(e.Values) == 0 {
+		return size + len("null")
+	}
+	size += len("{}")
+	fields := 0
+	e.eachField(func(k string, v interface{}) {
+		size += jsonStringSize(k) + len(":") + jsonValueSize(v)
+		fields++
+	})
+	if fields > 1 {
+		size += fields - 1 // commas
+	}
+	return size
+}
+
+// JSONSizeBytes returns the size of the batch's events encoded as the JSON array
+// Honeycomb's batch API accepts, which is what its request size limits apply to
+func (b Batch) JSONSizeBytes() int {
+	size := len("[]")
+	for _, event := range b.Events {
+		size += event.JSONSizeBytes()
+	}
+	if len(b.Events) > 1 {
+		size += len(b.Events) - 1 // commas
+	}
+	return size
+}
+
+// jsonValueSize returns the encoded size of an attribute value
+func jsonValueSize(v interface{}) int {
+	var buf [32]byte
+	switch v := v.(type) {
+	case nil:
+		return len("null")
+	case string:
+		return jsonStringSize(v)
+	case bool:
+		if v {
+			return len("true")
+		}
+		return len("false")
+	case int:
+		return len(strconv.AppendInt(buf[:0], int64(v), 10))
+	case int32:
+		return len(strconv.AppendInt(buf[:0], int64(v), 10))
+	case int64:
+		return len(strconv.AppendInt(buf[:0], v, 10))
+	case uint32:
+		return len(strconv.AppendUint(buf[:0], uint64(v), 10))
+	case uint64:
+		return len(strconv.AppendUint(buf[:0], v, 10))
+	case float64:
+		return len(appendJSONFloat(buf[:0], v, 64))
+	case float32:
+		return len(appendJSONFloat(buf[:0], float64(v), 32))
+	case Value:
+		return jsonValueSize(v.Interface())
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		return len(data)
+	}
+}
+
+// appendJSONFloat formats f the way our JSON encoder does: as a decimal, switching
+// to exponent notation for very large and very small magnitudes. Unlike
+// encoding/json, it keeps the exponent's leading zero (1e-07).
+func appendJSONFloat(buf []byte, f float64, bits int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	return strconv.AppendFloat(buf, f, format, -1, bits)
+}
+
+// jsonStringSize returns the encoded size of s as a quoted JSON string, with the
+// escaping our encoder shares with encoding/json: HTML characters, control characters, U+2028,
+// U+2029 and invalid UTF-8 are all escaped
+func jsonStringSize(s string) int {
+	size := len(`""`)
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			switch {
+			case b == '"' || b == '\\' || b == '\n' || b == '\r' || b == '\t':
+				size += 2
+			case b < 0x20 || b == '<' || b == '>' || b == '&':
+				size += len(`\u0000`)
+			default:
+				size++
+			}
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 || r == '\u2028' || r == '\u2029' {
+			size += len(`\u0000`)
+		} else {
+			size += n
+		}
+		i += n
+	}
+	return size
+}
+
+type batchJSON struct {
+	Dataset   string  `json:"dataset"`
+	SizeBytes int     `json:"size_bytes"`
+	Events    []Event `json:"events"`
+	APIKey    string  `json:"api_key,omitempty"`
+	Team      *Team   `json:"team,omitempty"`
+}
+
+// MarshalJSON encodes the batch with its dataset, size, events, API key override and team
+func (b Batch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(batchJSON(b))
+}
+
+// UnmarshalJSON decodes a batch encoded by MarshalJSON
+func (b *Batch) UnmarshalJSON(data []byte) error {
+	var raw batchJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*b = Batch(raw)
+	return nil
+}
+
+type telemetrySDKCountJSON struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Version  string `json:"version"`
+	Count    int    `json:"count"`
+}
+
+type resultJSON struct {
+	RequestSize          int                     `json:"request_size"`
+	Batches              []Batch                 `json:"batches"`
+	EventsByTelemetrySDK []telemetrySDKCountJSON `json:"events_by_telemetry_sdk,omitempty"`
+	DroppedSpans         int                     `json:"dropped_spans,omitempty"`
+	DroppedLogRecords    int                     `json:"dropped_log_records,omitempty"`
+	InvalidIDSpans       int                     `json:"invalid_id_spans,omitempty"`
+	OversizedEvents      int                     `json:"oversized_events,omitempty"`
+	SampledOutEvents     int                     `json:"sampled_out_events,omitempty"`
+	Team                 *Team                   `json:"team,omitempty"`
+	Warnings             []string                `json:"warnings,omitempty"`
+}
+
+// MarshalJSON encodes the result so it can be queued and decoded elsewhere with
+// UnmarshalJSON. EventsByTelemetrySDK is encoded as a list sorted by SDK.
+func (r TranslateOTLPRequestResult) MarshalJSON() ([]byte, error) {
+	raw := resultJSON{
+		RequestSize:       r.RequestSize,
+		Batches:           r.Batches,
+		DroppedSpans:      r.DroppedSpans,
+		DroppedLogRecords: r.DroppedLogRecords,
+		InvalidIDSpans:    r.InvalidIDSpans,
+		OversizedEvents:   r.OversizedEvents,
+		SampledOutEvents:  r.SampledOutEvents,
+		Team:              r.Team,
+		Warnings:          r.Warnings,
+	}
+	for sdk, count := range r.EventsByTelemetrySDK {
+		raw.EventsByTelemetrySDK = append(raw.EventsByTelemetrySDK, telemetrySDKCountJSON{
+			Name:     sdk.Name,
+			Language: sdk.Language,
+			Version:  sdk.Version,
+			Count:    count,
+		})
+	}
+	sort.Slice(raw.EventsByTelemetrySDK, func(i, j int) bool {
+		a, b := raw.EventsByTelemetrySDK[i], raw.EventsByTelemetrySDK[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Language != b.Language {
+			return a.Language < b.Language
+		}
+		return a.Version < b.Version
+	})
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON
+func (r *TranslateOTLPRequestResult) UnmarshalJSON(data []byte) error {
+	var raw resultJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = TranslateOTLPRequestResult{
+		RequestSize:       raw.RequestSize,
+		Batches:           raw.Batches,
+		DroppedSpans:      raw.DroppedSpans,
+		DroppedLogRecords: raw.DroppedLogRecords,
+		InvalidIDSpans:    raw.InvalidIDSpans,
+		OversizedEvents:   raw.OversizedEvents,
+		SampledOutEvents:  raw.SampledOutEvents,
+		Team:              raw.Team,
+		Warnings:          raw.Warnings,
+	}
+	if len(raw.EventsByTelemetrySDK) > 0 {
+		r.EventsByTelemetrySDK = make(map[TelemetrySDK]int, len(raw.EventsByTelemetrySDK))
+		for _, c := range raw.EventsByTelemetrySDK {
+			r.EventsByTelemetrySDK[TelemetrySDK{Name: c.Name, Language: c.Language, Version: c.Version}] += c.Count
+		}
+	}
+	return nil
+}
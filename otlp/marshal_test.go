@@ -0,0 +1,111 @@
+package otlp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventMarshalJSON(t *testing.T) {
+	event := Event{
+		Attributes: map[string]interface{}{"name": "span", "error": true},
+		Timestamp:  time.Date(2022, 11, 7, 12, 0, 0, 123456789, time.UTC),
+		SampleRate: 10,
+	}
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"time":"2022-11-07T12:00:00.123456789Z","samplerate":10,"data":{"name":"span","error":true}}`, string(data))
+}
+
+func TestResultJSONRoundTrip(t *testing.T) {
+	result := &TranslateOTLPRequestResult{
+		RequestSize: 100,
+		Batches: []Batch{{
+			Dataset:   "my-service",
+			SizeBytes: 100,
+			APIKey:    "tenant-key",
+			Team:      &Team{Slug: "tenant"},
+			Events: []Event{{
+				Attributes: map[string]interface{}{
+					"name":           "span",
+					"duration_ms":    1.5,
+					"span.num_links": int64(2),
+					"error":          true,
+				},
+				Timestamp:  time.Date(2022, 11, 7, 12, 0, 0, 123456789, time.UTC),
+				SampleRate: 10,
+			}},
+		}},
+		EventsByTelemetrySDK: map[TelemetrySDK]int{
+			{Name: "opentelemetry", Language: "go", Version: "1.11.1"}: 1,
+		},
+		DroppedSpans:      1,
+		DroppedLogRecords: 1,
+		InvalidIDSpans:    1,
+		OversizedEvents:   1,
+		SampledOutEvents:  1,
+		Team:              &Team{Slug: "my-team", EnvironmentSlug: "prod"},
+		Warnings:          []string{"dropped 1 spans without a dataset"},
+	}
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	decoded := &TranslateOTLPRequestResult{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, result, decoded)
+}
+
+func TestEventJSONSizeBytes(t *testing.T) {
+	values := []interface{}{
+		nil, true, false, "", "plain", `quote " and \ backslash`, "tab\tnewline\nreturn\r",
+		"\x01\x1f control", "<html> & entities", "café 日本 \U0001F600", "  ",
+		"invalid \xff\xfe utf-8", 0, -1, int32(math.MinInt32), int64(math.MaxInt64), uint32(7), uint64(math.MaxUint64),
+		0.0, 1.5, -123.456, 1e-7, 1e-6, 1e20, 1e21, -1e-10, math.MaxFloat64, math.SmallestNonzeroFloat64,
+		float32(0.1), float32(1e-7), float32(3e21), []interface{}{"a", 1}, map[string]interface{}{"k": "v"},
+	}
+	for _, v := range values {
+		event := Event{
+			Attributes: map[string]interface{}{"field <&>": v, "other": "value"},
+			Timestamp:  time.Date(2022, 11, 7, 12, 0, 0, 120000000, time.UTC),
+			SampleRate: 100,
+		}
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+		assert.Equal(t, len(data), event.JSONSizeBytes(), "value %#v", v)
+	}
+
+	for _, event := range []Event{
+		{},
+		{Attributes: map[string]interface{}{}},
+		{
+			Attributes: map[string]interface{}{"name": "renamed"},
+			Values:     []Attribute{{Key: "route", Value: StringValue("/users")}, {Key: "count", Value: Int64Value(3)}},
+			Inherited:  map[string]interface{}{"service.name": "my-service"},
+			Span:       &SpanFields{TraceID: "abc", Name: "span", DurationMs: 2.5, Error: true},
+			Timestamp:  time.Now(),
+		},
+	} {
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+		assert.Equal(t, len(data), event.JSONSizeBytes())
+	}
+}
+
+func TestBatchJSONSizeBytes(t *testing.T) {
+	event := Event{Attributes: map[string]interface{}{"name": "span"}, Timestamp: time.Now()}
+	for _, n := range []int{0, 1, 3} {
+		batch := Batch{Events: make([]Event, n)}
+		for i := range batch.Events {
+			batch.Events[i] = event
+		}
+		data, err := json.Marshal(batch.Events)
+		require.NoError(t, err)
+		if n == 0 {
+			data = []byte("[]")
+		}
+		assert.Equal(t, len(data), batch.JSONSizeBytes())
+	}
+}
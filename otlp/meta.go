@@ -0,0 +1,55 @@
+package otlp
+
+import (
+	"reflect"
+	"strings"
+)
+
+// metaFieldPrefix is the namespace of the fields translation adds to describe an
+// event rather than the telemetry itself, such as meta.signal_type
+const metaFieldPrefix = "meta."
+
+// applyMetaFieldOptions renames the meta.* fields of the events in batches with
+// opts.MetaFieldPrefix, or removes them if opts.OmitMetaFields is set. It runs
+// once everything else has been added, so the rest of translation only ever
+// deals with the default names.
+func applyMetaFieldOptions(batches []Batch, opts TranslateOptions) {
+	if !opts.OmitMetaFields && (opts.MetaFieldPrefix == "" || opts.MetaFieldPrefix == metaFieldPrefix) {
+		return
+	}
+	// events from the same scope share one Inherited map, which must only be renamed once
+	renamedInherited := map[uintptr]struct{}{}
+	for i := range batches {
+		for j := range batches[i].Events {
+			ev := &batches[i].Events[j]
+			renameMetaFields(ev.Attributes, opts)
+			if ev.Inherited != nil {
+				ptr := reflect.ValueOf(ev.Inherited).Pointer()
+				if _, ok := renamedInherited[ptr]; !ok {
+					renamedInherited[ptr] = struct{}{}
+					renameMetaFields(ev.Inherited, opts)
+				}
+			}
+			if ev.Span != nil {
+				ev.Span.omitSignalType = opts.OmitMetaFields
+				ev.Span.signalTypeField = opts.MetaFieldPrefix + "signal_type"
+			}
+		}
+	}
+}
+
+// renameMetaFields applies opts' meta field options to the keys of attrs
+func renameMetaFields(attrs map[string]interface{}, opts TranslateOptions) {
+	var keys []string
+	for k := range attrs {
+		if strings.HasPrefix(k, metaFieldPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if !opts.OmitMetaFields {
+			attrs[opts.MetaFieldPrefix+k[len(metaFieldPrefix):]] = attrs[k]
+		}
+		delete(attrs, k)
+	}
+}
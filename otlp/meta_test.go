@@ -0,0 +1,64 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestMetaFieldPrefix(t *testing.T) {
+	req := buildTestTraceRequest([]*common.KeyValue{stringAttr("service.name", "my-service")}, &trace.Span{
+		TraceId:    test.RandomBytes(16),
+		SpanId:     test.RandomBytes(8),
+		Attributes: []*common.KeyValue{stringAttr("meta.team", "payments")},
+		Events:     []*trace.Span_Event{{Name: "span_event"}},
+	}, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+	})
+	ri := testRequestInfo()
+	ri.UserAgent = "OTel-OTLP-Exporter-Go/1.11.1"
+
+	for _, opts := range []TranslateOptions{
+		{IncludeUserAgent: true, MetaFieldPrefix: "hny."},
+		{IncludeUserAgent: true, MetaFieldPrefix: "hny.", TypedSpanFields: true, SharedResourceAttributes: true},
+	} {
+		result, err := TranslateTraceRequestWithOptions(req, ri, opts)
+		require.NoError(t, err)
+		events := result.Batches[0].Events
+		require.Equal(t, 3, len(events))
+		span, spanEvent := events[0].Fields(), events[1].Fields()
+		assert.Equal(t, "trace", span["hny.signal_type"])
+		assert.Equal(t, "payments", span["hny.team"])
+		assert.Equal(t, "span_event", spanEvent["hny.annotation_type"])
+		for _, ev := range events {
+			fields := ev.Fields()
+			assert.Equal(t, "OTel-OTLP-Exporter-Go/1.11.1", fields["hny.otlp.user_agent"])
+			for k := range fields {
+				assert.NotContains(t, k, "meta.")
+			}
+		}
+	}
+}
+
+func TestOmitMetaFields(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{OmitMetaFields: true})
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "my-service", attrs["service.name"])
+	for k := range attrs {
+		assert.NotContains(t, k, "meta.")
+	}
+
+	traceReq := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+	traceResult, err := TranslateTraceRequestWithOptions(traceReq, testRequestInfo(), TranslateOptions{OmitMetaFields: true, TypedSpanFields: true})
+	require.NoError(t, err)
+	assert.NotContains(t, traceResult.Batches[0].Events[0].Fields(), "meta.signal_type")
+}
@@ -0,0 +1,180 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MarshalMsgpack encodes the batch's events in the MessagePack format accepted by
+// Honeycomb's /1/batch endpoint: an array of objects with time, samplerate and data
+// keys. Timestamps are encoded as RFC 3339 strings with nanosecond precision.
+func (b Batch) MarshalMsgpack() ([]byte, error) {
+	buf := make([]byte, 0, b.SizeBytes)
+	buf = appendMsgpackArrayHeader(buf, len(b.Events))
+	for _, event := range b.Events {
+		buf = appendMsgpackEvent(buf, event)
+	}
+	return buf, nil
+}
+
+func appendMsgpackEvent(buf []byte, event Event) []byte {
+	buf = appendMsgpackMapHeader(buf, 3)
+	buf = appendMsgpackString(buf, "time")
+	buf = appendMsgpackString(buf, event.Timestamp.UTC().Format(time.RFC3339Nano))
+	buf = appendMsgpackString(buf, "samplerate")
+	buf = appendMsgpackInt(buf, int64(event.SampleRate))
+	buf = appendMsgpackString(buf, "data")
+	buf = appendMsgpackMapHeader(buf, event.NumFields())
+	for _, attr := range event.Values {
+		buf = appendMsgpackString(buf, attr.Key)
+		buf = appendMsgpackTypedValue(buf, attr.Value)
+	}
+	event.eachUntypedField(func(k string, v interface{}) {
+		buf = appendMsgpackString(buf, k)
+		buf = appendMsgpackValue(buf, v)
+	})
+	return buf
+}
+
+func appendMsgpackValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case Value:
+		return appendMsgpackTypedValue(buf, v)
+	case bool:
+		if v {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case string:
+		return appendMsgpackString(buf, v)
+	case int:
+		return appendMsgpackInt(buf, int64(v))
+	case int32:
+		return appendMsgpackInt(buf, int64(v))
+	case int64:
+		return appendMsgpackInt(buf, v)
+	case uint32:
+		return appendMsgpackInt(buf, int64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			buf = append(buf, 0xcf)
+			return binary.BigEndian.AppendUint64(buf, v)
+		}
+		return appendMsgpackInt(buf, int64(v))
+	case float64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+	default:
+		return appendMsgpackString(buf, fmt.Sprint(v))
+	}
+}
+
+// appendMsgpackTypedValue encodes v without the type switch needed for interface{} values
+func appendMsgpackTypedValue(buf []byte, v Value) []byte {
+	switch v.kind {
+	case ValueKindString:
+		return appendMsgpackString(buf, v.str)
+	case ValueKindInt64:
+		return appendMsgpackInt(buf, v.AsInt64())
+	case ValueKindFloat64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, v.num)
+	case ValueKindBool:
+		if v.num != 0 {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case ValueKindBytes:
+		return appendMsgpackBytes(buf, v.str)
+	case ValueKindSlice:
+		buf = appendMsgpackArrayHeader(buf, len(*v.list))
+		for _, elem := range *v.list {
+			buf = appendMsgpackTypedValue(buf, elem)
+		}
+		return buf
+	default:
+		return append(buf, 0xc0)
+	}
+}
+
+func appendMsgpackBytes(buf []byte, b string) []byte {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xc5)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xc6)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+	return append(buf, b...)
+}
+
+func appendMsgpackInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return append(buf, byte(i))
+	case i < 0 && i >= -32:
+		return append(buf, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(buf, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf = append(buf, 0xd1)
+		return binary.BigEndian.AppendUint16(buf, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf = append(buf, 0xd2)
+		return binary.BigEndian.AppendUint32(buf, uint32(i))
+	default:
+		buf = append(buf, 0xd3)
+		return binary.BigEndian.AppendUint64(buf, uint64(i))
+	}
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xda)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xdb)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendMsgpackArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xdc)
+		return binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xdd)
+		return binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+}
+
+func appendMsgpackMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xde)
+		return binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xdf)
+		return binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+}
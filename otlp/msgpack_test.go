@@ -0,0 +1,65 @@
+package otlp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchMarshalMsgpack(t *testing.T) {
+	batch := Batch{
+		Dataset: "my-service",
+		Events: []Event{{
+			Attributes: map[string]interface{}{"name": "span"},
+			Timestamp:  time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC),
+			SampleRate: 10,
+		}},
+	}
+	data, err := batch.MarshalMsgpack()
+	require.NoError(t, err)
+
+	expected := []byte{0x91, 0x83}
+	expected = append(expected, 0xa4)
+	expected = append(expected, "time"...)
+	expected = append(expected, 0xb4)
+	expected = append(expected, "2022-11-07T12:00:00Z"...)
+	expected = append(expected, 0xaa)
+	expected = append(expected, "samplerate"...)
+	expected = append(expected, 0x0a)
+	expected = append(expected, 0xa4)
+	expected = append(expected, "data"...)
+	expected = append(expected, 0x81, 0xa4)
+	expected = append(expected, "name"...)
+	expected = append(expected, 0xa4)
+	expected = append(expected, "span"...)
+	assert.Equal(t, expected, data)
+}
+
+func TestAppendMsgpackValue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    interface{}
+		expected []byte
+	}{
+		{name: "nil", value: nil, expected: []byte{0xc0}},
+		{name: "true", value: true, expected: []byte{0xc3}},
+		{name: "false", value: false, expected: []byte{0xc2}},
+		{name: "positive fixint", value: int64(5), expected: []byte{0x05}},
+		{name: "negative fixint", value: int64(-1), expected: []byte{0xff}},
+		{name: "int8", value: int64(-100), expected: []byte{0xd0, 0x9c}},
+		{name: "int16", value: 500, expected: []byte{0xd1, 0x01, 0xf4}},
+		{name: "int32", value: int32(100000), expected: []byte{0xd2, 0x00, 0x01, 0x86, 0xa0}},
+		{name: "int64", value: int64(1 << 40), expected: []byte{0xd3, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{name: "uint64", value: uint64(1 << 63), expected: []byte{0xcf, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{name: "float64", value: 1.5, expected: []byte{0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{name: "str8", value: strings.Repeat("a", 40), expected: append([]byte{0xd9, 40}, strings.Repeat("a", 40)...)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, appendMsgpackValue(nil, tc.value))
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package otlp
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/honeycombio/husky"
+	"go.opentelemetry.io/otel/metric"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// TranslateOptions controls optional translation behavior.
+// The zero value matches the behavior of the translate functions that don't take options.
+type TranslateOptions struct {
+	// IncludeUserAgent adds the request's User-Agent to every event as meta.otlp.user_agent
+	IncludeUserAgent bool
+
+	// IncludeTelemetrySDK adds normalized meta.telemetry_sdk.* fields to every event
+	// when the resource has telemetry.sdk.* attributes, and counts events per SDK
+	// in the result's EventsByTelemetrySDK.
+	IncludeTelemetrySDK bool
+
+	// IncludeHuskyVersion adds the husky library version to every event as meta.husky.version
+	IncludeHuskyVersion bool
+
+	// DatasetResolver, if set, replaces the default service.name based dataset
+	// routing for both traces and logs. Resolving to an empty dataset drops the
+	// resource's spans or log records, which are counted in the result's
+	// DroppedSpans or DroppedLogRecords.
+	DatasetResolver DatasetResolver
+
+	// DisableControlAttributes treats honeycomb.dataset, honeycomb.table, APIKeyAttribute
+	// and honeycomb.sample_rate as ordinary fields rather
+	// than interpreting them as routing and sampling controls and removing them
+	DisableControlAttributes bool
+
+	// DisableUTF8Repair skips replacing invalid UTF-8 in the request's strings with
+	// the Unicode replacement character before translation. The repair modifies the
+	// request in place, and repaired strings are counted in the result's Warnings.
+	// Requests decoded from protobuf or JSON are always valid, so callers that only
+	// translate those can set it to skip the walk over the request.
+	DisableUTF8Repair bool
+
+	// APIKeyAttribute, if set, names a resource attribute (conventionally
+	// honeycomb.api_key) whose value replaces RequestInfo.ApiKey for that resource's
+	// batches. Gateways merging several teams' telemetry into one request use it; the
+	// attribute is never sent. When APIKeyResolver is set, overriding keys are only
+	// honored once it accepts them, and resources whose key it rejects are dropped;
+	// otherwise they're applied unverified.
+	APIKeyAttribute string
+
+	// APIKeyResolver verifies the keys named by APIKeyAttribute, once per distinct key
+	// per request with Context, and supplies their batches' Team. Wrap it in a
+	// TeamCache so busy gateways don't check every key on every request. A
+	// RetryableError or other failure to check a key fails the whole translation.
+	APIKeyResolver TeamResolver
+
+	// ServiceNamespaceSeparator, if set, prefixes the service.name derived dataset
+	// with the resource's service.namespace, joined by the separator
+	// (e.g. "/" gives "namespace/service"). Resources without a namespace are unaffected.
+	// Note that SanitizeDatasetNames replaces slashes.
+	ServiceNamespaceSeparator string
+
+	// UnknownServiceDataset replaces "unknown_service" as the dataset for spans
+	// whose resource has a missing or unknown service.name
+	UnknownServiceDataset string
+
+	// UnknownLogSourceDataset replaces "unknown_log_source" as the dataset for logs
+	// without an x-honeycomb-dataset header that are sent with a Classic key, or
+	// whose resource has a missing or unknown service.name
+	UnknownLogSourceDataset string
+
+	// RejectUnknownService drops spans whose resource has a missing or unknown
+	// service.name instead of sending them to a fallback dataset
+	RejectUnknownService bool
+
+	// PreserveUnknownServiceProcess keeps SDK default service names such as
+	// "unknown_service:python" as the dataset, rather than collapsing them into
+	// the unknown service dataset
+	PreserveUnknownServiceProcess bool
+
+	// SanitizeDatasetNames cleans up dataset names before they are used: surrounding
+	// whitespace is trimmed, control characters removed, slashes replaced with dashes,
+	// and the name capped at MaxDatasetNameLength. When a name changes, the original
+	// is kept on each of the batch's events as meta.original_dataset.
+	SanitizeDatasetNames bool
+
+	// MaxDatasetNameLength caps sanitized dataset names, in bytes.
+	// Defaults to 255 when zero.
+	MaxDatasetNameLength int
+
+	// SampleRateKeys are the attributes checked for an event's sample rate, in order
+	// of precedence; the first one present is used and removed from the event.
+	// Defaults to "sampleRate", then "SampleRate".
+	SampleRateKeys []string
+
+	// PromoteExceptions copies exception.type, exception.message and exception.stacktrace
+	// from a span's "exception" span events onto the span itself and sets error=true,
+	// so errors can be queried on spans
+	PromoteExceptions bool
+
+	// MarkExceptionEvents sets error=true on "exception" span events themselves, with
+	// their exception.message (or exception.type) copied to error.message, so errors
+	// can be queried on span events without knowing the exception.* conventions
+	MarkExceptionEvents bool
+
+	// HTTPServerErrorsAsErrors marks spans with an UNSET status as errors when their
+	// http.status_code or http.response.status_code is 5xx, as many auto-instrumentation
+	// libraries don't set span status
+	HTTPServerErrorsAsErrors bool
+
+	// StatusCodeFormat selects how a span's status code is emitted
+	StatusCodeFormat StatusCodeFormat
+
+	// SpanStatusErrorMapper, if set, decides whether a span's status marks it as an
+	// error, replacing the default of treating only STATUS_CODE_ERROR as an error
+	SpanStatusErrorMapper SpanStatusErrorMapper
+
+	// SpanTimeFormat adds start_time and end_time fields to span events in the given format
+	SpanTimeFormat SpanTimeFormat
+
+	// SpanKindFormat selects which of the type and span.kind fields carry a span's kind
+	SpanKindFormat SpanKindFormat
+
+	// ScopeFieldFormat selects the fields an instrumentation scope's name and version
+	// are emitted in
+	ScopeFieldFormat ScopeFieldFormat
+
+	// IncludeDurationMicros adds an integer duration_us field to span events, alongside duration_ms
+	IncludeDurationMicros bool
+
+	// IncludeDurationNanos adds an integer duration_ns field to span events, alongside duration_ms
+	IncludeDurationNanos bool
+
+	// DropInvalidDurations drops spans whose end time is before their start time.
+	// By default their duration is clamped to zero and they're flagged with meta.invalid_duration.
+	DropInvalidDurations bool
+
+	// TimestampTolerance, if set, clamps event timestamps to within this long before
+	// or after the time the request was received (see RequestInfo.ReceivedAt), so
+	// clock-skewed data can't land years away. Clamped events keep their original
+	// timestamp in meta.original_timestamp.
+	TimestampTolerance time.Duration
+
+	// ReplaceZeroTimestamps gives spans with no start time the time the request was
+	// received (see RequestInfo.ReceivedAt) rather than the Unix epoch, and flags
+	// them with meta.invalid_timestamp
+	ReplaceZeroTimestamps bool
+
+	// IncludeTimestampNanos adds each event's timestamp as integer Unix nanoseconds in
+	// timestamp_ns, so consumers that serialize times at millisecond precision can
+	// still order events exactly. It's the time before any TimestampTolerance clamping.
+	IncludeTimestampNanos bool
+
+	// MetaFieldPrefix replaces the "meta." prefix of meta.* fields, such as
+	// meta.signal_type and meta.annotation_type, for schemas that reserve the meta
+	// namespace. Attributes sent with meta.* keys are renamed too. Fields are renamed
+	// after MaxEventSize is checked, so a longer prefix can take events slightly over it.
+	MetaFieldPrefix string
+
+	// OmitMetaFields removes meta.* fields from events altogether, for slimmer events
+	OmitMetaFields bool
+
+	// MaxAttributeDepth, if set, rejects requests with array or kvlist attribute
+	// values nested more than this many levels deep with ErrAttributeDepthExceeded.
+	// A kvlist of scalar values has a depth of 1.
+	MaxAttributeDepth int
+
+	// MaxEventsPerSpan, if set, rejects trace requests containing a span with more
+	// span events than this with ErrTooManySpanEvents
+	MaxEventsPerSpan int
+
+	// MaxSpansPerRequest, if set, rejects trace requests with more spans than this
+	// with ErrTooManySpans
+	MaxSpansPerRequest int
+
+	// MaxDecompressedBodySize, if set, rejects request bodies that decompress to
+	// more than this many bytes with ErrRequestTooLarge, so small compressed
+	// payloads can't expand to exhaust memory. Only applies when reading a body.
+	MaxDecompressedBodySize int64
+
+	// SniffContentType detects gzip and zstd compression and JSON or protobuf encoding
+	// from the first bytes of a request body, for senders that leave out or mislabel
+	// their Content-Type and Content-Encoding. Only applies when reading a body.
+	SniffContentType bool
+
+	// SkipDecompression reads request bodies as uncompressed whatever their
+	// Content-Encoding, for proxies that decompress bodies but forward the original
+	// header. SniffContentType instead decompresses only bodies that start with
+	// gzip or zstd magic bytes. Only applies when reading a body.
+	SkipDecompression bool
+
+	// DefaultSpanName, if set, replaces empty span names, including the parent_name
+	// of span events and links
+	DefaultSpanName string
+
+	// OmitLogAnnotationType leaves meta.annotation_type off logs with a trace ID.
+	// By default it's set to "span_event", so trace views show such logs as
+	// events of the span they were logged in.
+	OmitLogAnnotationType bool
+
+	// SeverityLevels maps log severity numbers to the level names in the severity
+	// field, alongside the number itself in severity_code. Numbers in no range are
+	// "unspecified". Defaults to DefaultSeverityLevels.
+	SeverityLevels []SeverityLevel
+
+	// LogBodyField is the event field a log record's body is written to, "body" if empty
+	LogBodyField string
+
+	// MaxLogBodySize, if set, truncates log bodies to this many bytes, string bodies
+	// included. By default string bodies are sent whole, and structured bodies are
+	// JSON-encoded and capped at 64KiB. Truncated bodies are marked with
+	// meta.truncated_bytes and meta.truncated_field and counted in the result's Warnings.
+	MaxLogBodySize int
+
+	// MaxSpanNameLength, if set, truncates span names longer than this many bytes.
+	// The number of truncated names is reported in the result's Warnings.
+	MaxSpanNameLength int
+
+	// MaxStatusMessageLength, if set, truncates span status messages longer than this
+	// many bytes. The number of truncated messages is reported in the result's Warnings.
+	// Invalid UTF-8 is always stripped from status messages.
+	MaxStatusMessageLength int
+
+	// FullTraceIDs always emits 128-bit trace IDs as 32 hex characters. By default,
+	// IDs whose first 64 bits are zero are shortened to 16 characters.
+	FullTraceIDs bool
+
+	// IncludeOriginalTraceID adds the full 32 character hex trace ID as
+	// meta.original_trace_id whenever a trace ID is shortened
+	IncludeOriginalTraceID bool
+
+	// FlagInvalidIDs marks spans with a missing, all-zero or wrong-length trace or
+	// span ID with meta.invalid_trace_id or meta.invalid_span_id. Such spans are
+	// counted in the result's InvalidIDSpans whether or not they're flagged.
+	FlagInvalidIDs bool
+
+	// DropInvalidIDs drops spans with a missing, all-zero or wrong-length trace or
+	// span ID instead of translating them
+	DropInvalidIDs bool
+
+	// AttributePrecedence decides whether span, span event and link attributes
+	// override resource attributes with the same key, or the other way around
+	AttributePrecedence AttributePrecedence
+
+	// ReportAttributeConflicts adds a warning to the result for each resource whose
+	// attributes share keys with its spans' attributes, listing the keys
+	ReportAttributeConflicts bool
+
+	// Hooks, if set, is given each request's counts of spans translated, events
+	// emitted, bytes processed and attributes dropped
+	Hooks MetricsHooks
+
+	// Logger, if set, receives debug and warning messages about problems that
+	// translation works around rather than failing on
+	Logger Logger
+
+	// TracerProvider, if set, wraps each translation in a span with the request's
+	// size and event counts, so translation shows up in the host's own traces
+	TracerProvider oteltrace.TracerProvider
+
+	// MeterProvider, if set, records translation duration and events emitted per
+	// dataset as husky.translate.duration, husky.translate.events and
+	// husky.translate.batch_events
+	MeterProvider metric.MeterProvider
+
+	// Context is the parent for self-instrumentation spans, is passed with recorded
+	// metrics and bounds calls to APIKeyResolver. Defaults to context.Background(),
+	// or the request's context in TraceServer, LogsServer and HTTPHandler.
+	Context context.Context
+
+	// ProfilerLabels tags the translating goroutine with pprof labels for the
+	// signal, request size bucket and current dataset, so CPU profiles of ingest
+	// services attribute time to signals and tenants. Labels are taken from
+	// Context and the goroutine is reset to Context's labels when translation ends.
+	ProfilerLabels bool
+
+	// TypedSpanFields stores each span's fixed fields (IDs, name, kind, duration,
+	// link and event counts and error) in the event's Span field instead of its
+	// Attributes map, cutting per-span allocations. Consumers must read events
+	// with Event.Get or Event.Fields; JSON and MessagePack encoding include both.
+	TypedSpanFields bool
+
+	// SharedResourceAttributes attaches a scope's resource and scope attributes to
+	// each event as one shared Inherited map instead of copying them into every
+	// event's Attributes, which saves work for resources with many attributes.
+	// Consumers must read events with Event.Get or Event.Fields; JSON and
+	// MessagePack encoding include every layer.
+	SharedResourceAttributes bool
+
+	// TypedAttributeValues stores span, span event, link and log record attributes
+	// in each event's Values map of typed Values instead of its Attributes map, so
+	// scalar values aren't boxed. Attributes that translation itself reads, such as
+	// sample rates, dataset overrides and meta.* fields, stay in Attributes.
+	// Consumers must read events with Event.Get or Event.Fields; JSON and
+	// MessagePack encoding include every layer.
+	TypedAttributeValues bool
+
+	// EventBuffer, if it has room for every event, is used as backing storage for
+	// the events of trace and log results, so callers translating many requests can
+	// reuse one slice rather than allocating one per request. The buffer's contents
+	// are overwritten, so it mustn't be reused until the previous result is no
+	// longer needed.
+	EventBuffer []Event
+
+	// BatchBuffer, if it has capacity, is used as backing storage for the batches of
+	// trace and log results, with the same reuse rules as EventBuffer
+	BatchBuffer []Batch
+
+	// PoolResults takes trace and log results from the package's pool, reusing the
+	// batch and event storage of results released with ReleaseResult. Callers must
+	// release each result once they're done with it, or it's simply garbage collected.
+	PoolResults bool
+
+	// MaxEventSize, if positive, is the largest JSON-encoded size in bytes allowed
+	// for an event. Larger events are handled according to OversizedEventPolicy
+	// and counted in the result's OversizedEvents.
+	MaxEventSize int
+
+	// OversizedEventPolicy selects whether events larger than MaxEventSize are
+	// flagged with meta.oversized, truncated or dropped. Defaults to flagging them.
+	OversizedEventPolicy OversizedEventPolicy
+
+	// MaxColumnsPerBatch, if positive, limits the number of distinct fields across
+	// each batch's events, guarding datasets against attribute explosions such as
+	// IDs used as keys. Fields are admitted in the order they're first seen, and
+	// those beyond the limit are folded into a single JSON field per event.
+	MaxColumnsPerBatch int
+
+	// ColumnOverflowField names the field holding attributes folded by
+	// MaxColumnsPerBatch. Defaults to meta.overflow_attributes.
+	ColumnOverflowField string
+
+	// Sampler, if set, is asked about every translated span, span event, link and
+	// log event, and can drop it or change its sample rate. Dropped events are
+	// counted in the result's SampledOutEvents.
+	Sampler Sampler
+}
+
+// AttributePrecedence selects which attributes win when a span and its resource set the same key
+type AttributePrecedence int
+
+const (
+	// SpanAttributesFirst lets span, span event and link attributes override resource attributes
+	SpanAttributesFirst AttributePrecedence = iota
+	// ResourceAttributesFirst keeps resource attributes over span, span event and link attributes
+	ResourceAttributesFirst
+)
+
+// SpanTimeFormat selects whether and how a span's start and end times are emitted
+type SpanTimeFormat int
+
+const (
+	// SpanTimesOmitted doesn't emit start_time or end_time
+	SpanTimesOmitted SpanTimeFormat = iota
+	// SpanTimesAsRFC3339Nano emits start_time and end_time as RFC3339 strings with nanoseconds
+	SpanTimesAsRFC3339Nano
+	// SpanTimesAsUnixNano emits start_time and end_time as integer Unix nanoseconds
+	SpanTimesAsUnixNano
+)
+
+// SpanStatusErrorMapper reports whether a span with the given status should have
+// error=true. The status is nil if the span didn't have one.
+type SpanStatusErrorMapper func(status *trace.Status) bool
+
+// NeverError is a SpanStatusErrorMapper that never marks spans as errors
+func NeverError(status *trace.Status) bool {
+	return false
+}
+
+// SpanKindFormat selects which fields a span's kind is emitted in
+type SpanKindFormat int
+
+const (
+	// SpanKindAsTypeAndSpanKind emits the kind as both span.kind and the older type
+	SpanKindAsTypeAndSpanKind SpanKindFormat = iota
+	// SpanKindAsSpanKindOnly emits the kind as span.kind only
+	SpanKindAsSpanKindOnly
+	// SpanKindAsTypeOnly emits the kind as type only, for consumers built before span.kind
+	SpanKindAsTypeOnly
+)
+
+// ScopeFieldFormat selects which fields an instrumentation scope's name and version are emitted in
+type ScopeFieldFormat int
+
+const (
+	// ScopeAsLibraryFields emits library.name and library.version
+	ScopeAsLibraryFields ScopeFieldFormat = iota
+	// ScopeAsOTelScopeFields emits otel.scope.name and otel.scope.version, as the
+	// OpenTelemetry Collector's exporters do
+	ScopeAsOTelScopeFields
+	// ScopeAsLibraryAndOTelScopeFields emits both sets of fields
+	ScopeAsLibraryAndOTelScopeFields
+)
+
+// StatusCodeFormat selects how a span's status code is emitted
+type StatusCodeFormat int
+
+const (
+	// StatusCodeAsNumber emits status_code as the OTLP enum value, e.g. 2
+	StatusCodeAsNumber StatusCodeFormat = iota
+	// StatusCodeAsText emits status_code as "UNSET", "OK" or "ERROR"
+	StatusCodeAsText
+	// StatusCodeAsNumberAndText emits the numeric status_code and the text as status_code_text
+	StatusCodeAsNumberAndText
+)
+
+func (opts TranslateOptions) sampleRateKeys() []string {
+	if len(opts.SampleRateKeys) > 0 {
+		return opts.SampleRateKeys
+	}
+	return defaultSampleRateKeys
+}
+
+// DatasetResolver chooses the dataset that a resource's events are sent to
+type DatasetResolver interface {
+	ResolveDataset(ri RequestInfo, resourceAttrs map[string]interface{}) string
+}
+
+// DatasetResolverFunc adapts an ordinary function to the DatasetResolver interface
+type DatasetResolverFunc func(ri RequestInfo, resourceAttrs map[string]interface{}) string
+
+// ResolveDataset calls f(ri, resourceAttrs)
+func (f DatasetResolverFunc) ResolveDataset(ri RequestInfo, resourceAttrs map[string]interface{}) string {
+	return f(ri, resourceAttrs)
+}
+
+// addRequestAttributes adds fields derived from the request, rather than from the
+// OTLP payload, to attrs. Callers pass the resource attributes so the fields are
+// copied onto every event alongside them.
+func addRequestAttributes(attrs map[string]interface{}, ri RequestInfo, opts TranslateOptions) {
+	if opts.IncludeUserAgent && ri.UserAgent != "" {
+		attrs["meta.otlp.user_agent"] = ri.UserAgent
+	}
+	if opts.IncludeHuskyVersion {
+		attrs["meta.husky.version"] = husky.Version
+	}
+}
+
+// addTimestampNanos adds an event's timestamp to attrs as timestamp_ns if opts asks for it
+func addTimestampNanos(attrs map[string]interface{}, timestamp time.Time, opts TranslateOptions) {
+	if opts.IncludeTimestampNanos {
+		attrs["timestamp_ns"] = timestamp.UnixNano()
+	}
+}
+
+func (opts TranslateOptions) severityLevels() []SeverityLevel {
+	if len(opts.SeverityLevels) > 0 {
+		return opts.SeverityLevels
+	}
+	return DefaultSeverityLevels
+}
+
+func (opts TranslateOptions) unknownLogSourceDataset() string {
+	if opts.UnknownLogSourceDataset != "" {
+		return opts.UnknownLogSourceDataset
+	}
+	return unknownLogSource
+}
+
+// requestContext returns Context, or context.Background() if it isn't set
+func (opts TranslateOptions) requestContext() context.Context {
+	if opts.Context == nil {
+		return context.Background()
+	}
+	return opts.Context
+}
+
+// withRequestContext returns opts with Context set to ctx unless it's already set
+func (opts TranslateOptions) withRequestContext(ctx context.Context) TranslateOptions {
+	if opts.Context == nil {
+		opts.Context = ctx
+	}
+	return opts
+}
+
+// logBodyField returns the field log bodies are written to
+func (opts TranslateOptions) logBodyField() string {
+	if opts.LogBodyField != "" {
+		return opts.LogBodyField
+	}
+	return "body"
+}
+
+// traceID encodes a trace ID for an event field, keeping its full length when FullTraceIDs is set
+func (opts TranslateOptions) traceID(id []byte) string {
+	if opts.FullTraceIDs {
+		return hex.EncodeToString(id)
+	}
+	return BytesToTraceID(id)
+}
+
+// shortensTraceID reports whether traceID shortens id to 64 bits
+func (opts TranslateOptions) shortensTraceID(id []byte) bool {
+	return !opts.FullTraceIDs && len(id) == traceIDLongLength && shouldTrimTraceId(id)
+}
+
+// addOriginalTraceID adds meta.original_trace_id when IncludeOriginalTraceID is
+// set and traceID would be shortened
+func addOriginalTraceID(attrs map[string]interface{}, id []byte, opts TranslateOptions) {
+	if opts.IncludeOriginalTraceID && opts.shortensTraceID(id) {
+		attrs["meta.original_trace_id"] = hex.EncodeToString(id)
+	}
+}
@@ -0,0 +1,113 @@
+package otlp
+
+import "fmt"
+
+// OversizedEventPolicy selects what happens to events larger than MaxEventSize
+type OversizedEventPolicy int
+
+const (
+	// FlagOversizedEvents keeps oversized events, adding meta.oversized to them
+	FlagOversizedEvents OversizedEventPolicy = iota
+	// TruncateOversizedEvents shortens an oversized event's largest string fields
+	// until it fits, dropping it if it still doesn't fit once they're empty
+	TruncateOversizedEvents
+	// DropOversizedEvents removes oversized events from their batches
+	DropOversizedEvents
+)
+
+// oversizedEventProtectedKeys are never truncated, since events are useless without them
+var oversizedEventProtectedKeys = map[string]struct{}{
+	"trace.trace_id":       {},
+	"trace.span_id":        {},
+	"trace.parent_id":      {},
+	"trace.link.trace_id":  {},
+	"trace.link.span_id":   {},
+	"meta.signal_type":     {},
+	"meta.annotation_type": {},
+}
+
+// applyOversizedEventPolicy finds events whose JSON encoding is larger than
+// opts.MaxEventSize and handles them according to opts.OversizedEventPolicy,
+// returning the number of oversized events and a warning describing them
+func applyOversizedEventPolicy(batches []Batch, opts TranslateOptions) (int, string) {
+	if opts.MaxEventSize <= 0 {
+		return 0, ""
+	}
+	var oversized, dropped int
+	for i := range batches {
+		events := batches[i].Events
+		kept := events[:0]
+		for j := range events {
+			ev := events[j]
+			if ev.JSONSizeBytes() <= opts.MaxEventSize {
+				kept = append(kept, ev)
+				continue
+			}
+			oversized++
+			switch opts.OversizedEventPolicy {
+			case FlagOversizedEvents:
+				ev.SetAttr("meta.oversized", true)
+			case TruncateOversizedEvents:
+				if !truncateOversizedEvent(&ev, opts.MaxEventSize) {
+					dropped++
+					continue
+				}
+			case DropOversizedEvents:
+				dropped++
+				continue
+			}
+			kept = append(kept, ev)
+		}
+		batches[i].Events = kept
+	}
+	if oversized == 0 {
+		return 0, ""
+	}
+	switch opts.OversizedEventPolicy {
+	case FlagOversizedEvents:
+		return oversized, fmt.Sprintf("flagged %d events larger than %d bytes", oversized, opts.MaxEventSize)
+	case TruncateOversizedEvents:
+		if dropped > 0 {
+			return oversized, fmt.Sprintf("truncated %d and dropped %d events larger than %d bytes", oversized-dropped, dropped, opts.MaxEventSize)
+		}
+		return oversized, fmt.Sprintf("truncated %d events larger than %d bytes", oversized, opts.MaxEventSize)
+	default:
+		return oversized, fmt.Sprintf("dropped %d events larger than %d bytes", oversized, opts.MaxEventSize)
+	}
+}
+
+// truncateOversizedEvent shortens ev's largest string fields until its JSON
+// encoding fits in maxSize bytes, reporting false if it can't be made to fit
+func truncateOversizedEvent(ev *Event, maxSize int) bool {
+	for {
+		size := ev.JSONSizeBytes()
+		if size <= maxSize {
+			return true
+		}
+		key, value := largestStringField(*ev)
+		if value == "" {
+			return false
+		}
+		// every byte removed shrinks the encoding by at least a byte
+		keep := len(value) - (size - maxSize)
+		if keep < 0 {
+			keep = 0
+		}
+		ev.SetAttr(key, truncateString(value, keep))
+	}
+}
+
+// largestStringField returns the event's longest string field that may be truncated
+func largestStringField(ev Event) (key string, value string) {
+	ev.eachField(func(k string, v interface{}) {
+		s, ok := v.(string)
+		if !ok || len(s) <= len(value) {
+			return
+		}
+		if _, protected := oversizedEventProtectedKeys[k]; protected {
+			return
+		}
+		key, value = k, s
+	})
+	return key, value
+}
@@ -0,0 +1,106 @@
+package otlp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestOversizedEventPolicy(t *testing.T) {
+	newRequest := func() []*trace.Span {
+		return []*trace.Span{
+			{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "small"},
+			{
+				TraceId: test.RandomBytes(16),
+				SpanId:  test.RandomBytes(8),
+				Name:    "large",
+				Attributes: []*common.KeyValue{
+					stringAttr("big", strings.Repeat("x", 2000)),
+					stringAttr("medium", strings.Repeat("y", 600)),
+				},
+			},
+		}
+	}
+	const maxSize = 1000
+
+	testCases := []struct {
+		name           string
+		policy         OversizedEventPolicy
+		expectedEvents int
+		expectedWarn   string
+	}{
+		{name: "flag", policy: FlagOversizedEvents, expectedEvents: 2, expectedWarn: "flagged 1 events larger than 1000 bytes"},
+		{name: "truncate", policy: TruncateOversizedEvents, expectedEvents: 2, expectedWarn: "truncated 1 events larger than 1000 bytes"},
+		{name: "drop", policy: DropOversizedEvents, expectedEvents: 1, expectedWarn: "dropped 1 events larger than 1000 bytes"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, newRequest()...), testRequestInfo(), TranslateOptions{
+				MaxEventSize:         maxSize,
+				OversizedEventPolicy: tc.policy,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 1, result.OversizedEvents)
+			assert.Contains(t, result.Warnings, tc.expectedWarn)
+			events := result.Batches[0].Events
+			require.Equal(t, tc.expectedEvents, len(events))
+			assert.Equal(t, "small", events[0].Attributes["name"])
+			assert.NotContains(t, events[0].Attributes, "meta.oversized")
+			if tc.expectedEvents == 1 {
+				return
+			}
+			large := events[1]
+			switch tc.policy {
+			case FlagOversizedEvents:
+				assert.Equal(t, true, large.Attributes["meta.oversized"])
+				assert.Equal(t, 2000, len(large.Attributes["big"].(string)))
+			case TruncateOversizedEvents:
+				assert.LessOrEqual(t, large.JSONSizeBytes(), maxSize)
+				assert.Less(t, len(large.Attributes["big"].(string)), 2000)
+				// the largest field is truncated first, so the smaller one is untouched
+				assert.Equal(t, 600, len(large.Attributes["medium"].(string)))
+				assert.Equal(t, "large", large.Attributes["name"])
+			}
+		})
+	}
+
+	result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, newRequest()...), testRequestInfo(), TranslateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.OversizedEvents)
+	assert.Empty(t, result.Warnings)
+}
+
+func TestTruncateOversizedEvent(t *testing.T) {
+	event := Event{
+		Attributes: map[string]interface{}{
+			"trace.trace_id": strings.Repeat("a", 32),
+			"first":          strings.Repeat("é", 300),
+			"second":         strings.Repeat("b", 500),
+		},
+		Inherited: map[string]interface{}{"shared": strings.Repeat("c", 400)},
+	}
+	require.True(t, truncateOversizedEvent(&event, 500))
+	assert.LessOrEqual(t, event.JSONSizeBytes(), 500)
+	assert.Equal(t, 32, len(event.Attributes["trace.trace_id"].(string)))
+	// shared attributes are shadowed rather than modified
+	assert.Equal(t, 400, len(event.Inherited["shared"].(string)))
+
+	// protected and non-string fields can't be truncated
+	event = Event{Attributes: map[string]interface{}{"trace.trace_id": strings.Repeat("a", 200), "n": int64(1)}}
+	assert.False(t, truncateOversizedEvent(&event, 50))
+}
+
+func TestOversizedLogs(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body = &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: strings.Repeat("x", 5000)}}
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{MaxEventSize: 1000, OversizedEventPolicy: DropOversizedEvents})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.OversizedEvents)
+	assert.Empty(t, result.Batches[0].Events)
+}
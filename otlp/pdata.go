@@ -0,0 +1,215 @@
+package otlp
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// TranslateTraces translates OpenTelemetry Collector trace data into Honeycomb-friendly structure.
+// The data is copied into the OTLP request structs directly, without serializing it to bytes.
+func TranslateTraces(td ptrace.Traces, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateTracesWithOptions(td, ri, TranslateOptions{})
+}
+
+// TranslateTracesWithOptions is like TranslateTraces but applies the given TranslateOptions
+func TranslateTracesWithOptions(td ptrace.Traces, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	return TranslateTraceRequestWithOptions(tracesToRequest(td), ri, opts)
+}
+
+// TranslateLogs translates OpenTelemetry Collector log data into Honeycomb-friendly structure.
+// The data is copied into the OTLP request structs directly, without serializing it to bytes.
+func TranslateLogs(ld plog.Logs, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateLogsWithOptions(ld, ri, TranslateOptions{})
+}
+
+// TranslateLogsWithOptions is like TranslateLogs but applies the given TranslateOptions
+func TranslateLogsWithOptions(ld plog.Logs, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	return TranslateLogsRequestWithOptions(logsToRequest(ld), ri, opts)
+}
+
+func tracesToRequest(td ptrace.Traces) *collectorTrace.ExportTraceServiceRequest {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		resourceSpans := &trace.ResourceSpans{
+			Resource:  pdataResource(rs.Resource()),
+			SchemaUrl: rs.SchemaUrl(),
+		}
+		sss := rs.ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			ss := sss.At(j)
+			scopeSpans := &trace.ScopeSpans{
+				Scope:     pdataScope(ss.Scope()),
+				SchemaUrl: ss.SchemaUrl(),
+			}
+			spans := ss.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				scopeSpans.Spans = append(scopeSpans.Spans, pdataSpan(spans.At(k)))
+			}
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+		}
+		request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+	}
+	return request
+}
+
+func pdataSpan(s ptrace.Span) *trace.Span {
+	span := &trace.Span{
+		TraceId:                pdataTraceID(s.TraceID()),
+		SpanId:                 pdataSpanID(s.SpanID()),
+		TraceState:             s.TraceState().AsRaw(),
+		ParentSpanId:           pdataSpanID(s.ParentSpanID()),
+		Name:                   s.Name(),
+		Kind:                   trace.Span_SpanKind(s.Kind()),
+		StartTimeUnixNano:      uint64(s.StartTimestamp()),
+		EndTimeUnixNano:        uint64(s.EndTimestamp()),
+		Attributes:             pdataAttributes(s.Attributes()),
+		DroppedAttributesCount: s.DroppedAttributesCount(),
+		DroppedEventsCount:     s.DroppedEventsCount(),
+		DroppedLinksCount:      s.DroppedLinksCount(),
+		Flags:                  s.Flags(),
+		Status: &trace.Status{
+			Code:    trace.Status_StatusCode(s.Status().Code()),
+			Message: s.Status().Message(),
+		},
+	}
+	events := s.Events()
+	for i := 0; i < events.Len(); i++ {
+		e := events.At(i)
+		span.Events = append(span.Events, &trace.Span_Event{
+			TimeUnixNano:           uint64(e.Timestamp()),
+			Name:                   e.Name(),
+			Attributes:             pdataAttributes(e.Attributes()),
+			DroppedAttributesCount: e.DroppedAttributesCount(),
+		})
+	}
+	links := s.Links()
+	for i := 0; i < links.Len(); i++ {
+		l := links.At(i)
+		span.Links = append(span.Links, &trace.Span_Link{
+			TraceId:                pdataTraceID(l.TraceID()),
+			SpanId:                 pdataSpanID(l.SpanID()),
+			TraceState:             l.TraceState().AsRaw(),
+			Attributes:             pdataAttributes(l.Attributes()),
+			DroppedAttributesCount: l.DroppedAttributesCount(),
+			Flags:                  l.Flags(),
+		})
+	}
+	return span
+}
+
+func logsToRequest(ld plog.Logs) *collectorLogs.ExportLogsServiceRequest {
+	request := &collectorLogs.ExportLogsServiceRequest{}
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		rl := rls.At(i)
+		resourceLogs := &logs.ResourceLogs{
+			Resource:  pdataResource(rl.Resource()),
+			SchemaUrl: rl.SchemaUrl(),
+		}
+		sls := rl.ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			sl := sls.At(j)
+			scopeLogs := &logs.ScopeLogs{
+				Scope:     pdataScope(sl.Scope()),
+				SchemaUrl: sl.SchemaUrl(),
+			}
+			records := sl.LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				lr := records.At(k)
+				scopeLogs.LogRecords = append(scopeLogs.LogRecords, &logs.LogRecord{
+					TimeUnixNano:           uint64(lr.Timestamp()),
+					ObservedTimeUnixNano:   uint64(lr.ObservedTimestamp()),
+					SeverityNumber:         logs.SeverityNumber(lr.SeverityNumber()),
+					SeverityText:           lr.SeverityText(),
+					Body:                   pdataValue(lr.Body()),
+					Attributes:             pdataAttributes(lr.Attributes()),
+					DroppedAttributesCount: lr.DroppedAttributesCount(),
+					Flags:                  uint32(lr.Flags()),
+					TraceId:                pdataTraceID(lr.TraceID()),
+					SpanId:                 pdataSpanID(lr.SpanID()),
+				})
+			}
+			resourceLogs.ScopeLogs = append(resourceLogs.ScopeLogs, scopeLogs)
+		}
+		request.ResourceLogs = append(request.ResourceLogs, resourceLogs)
+	}
+	return request
+}
+
+func pdataResource(r pcommon.Resource) *resource.Resource {
+	return &resource.Resource{
+		Attributes:             pdataAttributes(r.Attributes()),
+		DroppedAttributesCount: r.DroppedAttributesCount(),
+	}
+}
+
+func pdataScope(s pcommon.InstrumentationScope) *common.InstrumentationScope {
+	return &common.InstrumentationScope{
+		Name:                   s.Name(),
+		Version:                s.Version(),
+		Attributes:             pdataAttributes(s.Attributes()),
+		DroppedAttributesCount: s.DroppedAttributesCount(),
+	}
+}
+
+// pdataTraceID returns nil for an empty trace ID, matching an unset bytes field
+func pdataTraceID(id pcommon.TraceID) []byte {
+	if id.IsEmpty() {
+		return nil
+	}
+	return id[:]
+}
+
+// pdataSpanID returns nil for an empty span ID, matching an unset bytes field
+func pdataSpanID(id pcommon.SpanID) []byte {
+	if id.IsEmpty() {
+		return nil
+	}
+	return id[:]
+}
+
+func pdataAttributes(m pcommon.Map) []*common.KeyValue {
+	if m.Len() == 0 {
+		return nil
+	}
+	attrs := make([]*common.KeyValue, 0, m.Len())
+	m.Range(func(k string, v pcommon.Value) bool {
+		attrs = append(attrs, &common.KeyValue{Key: k, Value: pdataValue(v)})
+		return true
+	})
+	return attrs
+}
+
+func pdataValue(v pcommon.Value) *common.AnyValue {
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: v.Str()}}
+	case pcommon.ValueTypeBool:
+		return &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: v.Bool()}}
+	case pcommon.ValueTypeInt:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: v.Int()}}
+	case pcommon.ValueTypeDouble:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: v.Double()}}
+	case pcommon.ValueTypeBytes:
+		return &common.AnyValue{Value: &common.AnyValue_BytesValue{BytesValue: v.Bytes().AsRaw()}}
+	case pcommon.ValueTypeSlice:
+		s := v.Slice()
+		values := make([]*common.AnyValue, 0, s.Len())
+		for i := 0; i < s.Len(); i++ {
+			values = append(values, pdataValue(s.At(i)))
+		}
+		return &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{Values: values}}}
+	case pcommon.ValueTypeMap:
+		return &common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{Values: pdataAttributes(v.Map())}}}
+	}
+	return nil
+}
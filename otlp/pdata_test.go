@@ -0,0 +1,84 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestTranslateTraces(t *testing.T) {
+	start := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "my-service")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("my-library")
+	span := ss.Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	span.SetName("test_span")
+	span.SetKind(ptrace.SpanKindServer)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Second)))
+	span.Status().SetCode(ptrace.StatusCodeError)
+	span.Attributes().PutInt("http.status_code", 500)
+	span.Attributes().PutEmptySlice("tags").AppendEmpty().SetStr("a")
+	span.Events().AppendEmpty().SetName("span_event")
+	link := span.Links().AppendEmpty()
+	link.SetSpanID(pcommon.SpanID{8, 7, 6, 5, 4, 3, 2, 1})
+	link.SetFlags(1)
+
+	result, err := TranslateTraces(td, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	batch := result.Batches[0]
+	assert.Equal(t, "my-service", batch.Dataset)
+	require.Equal(t, 3, len(batch.Events))
+
+	ev := batch.Events[0]
+	assert.Equal(t, start, ev.Timestamp)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", ev.Attributes["trace.trace_id"])
+	assert.Equal(t, "0102030405060708", ev.Attributes["trace.span_id"])
+	assert.NotContains(t, ev.Attributes, "trace.parent_id")
+	assert.Equal(t, "test_span", ev.Attributes["name"])
+	assert.Equal(t, "server", ev.Attributes["span.kind"])
+	assert.Equal(t, float64(1000), ev.Attributes["duration_ms"])
+	assert.Equal(t, true, ev.Attributes["error"])
+	assert.Equal(t, int64(500), ev.Attributes["http.status_code"])
+	assert.Equal(t, "[\"a\"]\n", ev.Attributes["tags"])
+	assert.Equal(t, "my-library", ev.Attributes["library.name"])
+	assert.Equal(t, "span_event", batch.Events[1].Attributes["name"])
+	assert.Equal(t, "0807060504030201", batch.Events[2].Attributes["trace.link.span_id"])
+	assert.Equal(t, true, batch.Events[2].Attributes["trace.link.sampled"])
+	assert.Equal(t, int64(1), batch.Events[2].Attributes["trace.link.flags"])
+}
+
+func TestTranslateLogs(t *testing.T) {
+	ts := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "my-service")
+	lr := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	lr.SetSeverityText("info")
+	lr.Body().SetStr("log message")
+	lr.Attributes().PutBool("handled", true)
+
+	result, err := TranslateLogs(ld, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	batch := result.Batches[0]
+	assert.Equal(t, "my-service", batch.Dataset)
+	require.Equal(t, 1, len(batch.Events))
+	ev := batch.Events[0]
+	assert.Equal(t, ts, ev.Timestamp)
+	assert.Equal(t, "log message", ev.Attributes["body"])
+	assert.Equal(t, "info", ev.Attributes["severity_text"])
+	assert.Equal(t, true, ev.Attributes["handled"])
+	assert.NotContains(t, ev.Attributes, "trace.trace_id")
+}
@@ -0,0 +1,100 @@
+package otlp
+
+import (
+	"sort"
+
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorProfiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1experimental"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// PeekDatasets returns the sorted datasets a trace, logs or profiles request
+// would be routed to, without translating it, so routers can shard requests first.
+// It reads only resource, scope and span attributes and doesn't validate headers.
+// Names are sanitized as translation sanitizes them when SanitizeDatasetNames is set.
+// Requests of any other type return nil.
+func PeekDatasets(request proto.Message, ri RequestInfo, opts TranslateOptions) []string {
+	datasets := map[string]struct{}{}
+	add := func(dataset string) {
+		if dataset != "" {
+			datasets[sentDatasetName(dataset, opts)] = struct{}{}
+		}
+	}
+	switch req := request.(type) {
+	case *collectorTrace.ExportTraceServiceRequest:
+		for _, resourceSpan := range req.ResourceSpans {
+			dataset := getResourceDataset(ri, resourceSpan.Resource, opts)
+			if dataset == "" {
+				continue
+			}
+			for _, scopeSpan := range resourceSpan.ScopeSpans {
+				scopeDataset, ok := attributeDatasetOverride(scopeSpan.Scope.GetAttributes(), opts)
+				if !ok {
+					scopeDataset = dataset
+				}
+				for _, span := range scopeSpan.Spans {
+					spanDataset, ok := attributeDatasetOverride(span.Attributes, opts)
+					if !ok {
+						spanDataset = scopeDataset
+					}
+					add(spanDataset)
+				}
+			}
+		}
+	case *collectorLogs.ExportLogsServiceRequest:
+		for _, resourceLog := range req.ResourceLogs {
+			dataset := getLogsResourceDataset(ri, resourceLog.Resource, opts)
+			if dataset == "" {
+				continue
+			}
+			for _, scopeLog := range resourceLog.ScopeLogs {
+				scopeDataset, ok := attributeDatasetOverride(scopeLog.Scope.GetAttributes(), opts)
+				if !ok {
+					scopeDataset = dataset
+				}
+				for _, log := range scopeLog.LogRecords {
+					logDataset, ok := attributeDatasetOverride(log.Attributes, opts)
+					if !ok {
+						logDataset = scopeDataset
+					}
+					add(logDataset)
+				}
+			}
+		}
+	case *collectorProfiles.ExportProfilesServiceRequest:
+		for _, resourceProfile := range req.ResourceProfiles {
+			add(getResourceDataset(ri, resourceProfile.Resource, opts))
+		}
+	default:
+		return nil
+	}
+	names := make([]string, 0, len(datasets))
+	for dataset := range datasets {
+		names = append(names, dataset)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getLogsResourceDataset is like getResourceDataset for a logs resource
+func getLogsResourceDataset(ri RequestInfo, r *resource.Resource, opts TranslateOptions) string {
+	attrs := getResourceAttributes(r)
+	ri, _ = resourceRequestInfo(ri, attrs, opts)
+	if dataset, ok := popDatasetOverride(attrs, opts); ok {
+		return dataset
+	}
+	return getLogsDataset(ri, attrs, opts)
+}
+
+// getResourceDataset returns the dataset for a trace or profiles resource: its
+// honeycomb.dataset override if it has one, otherwise the one getDataset picks
+func getResourceDataset(ri RequestInfo, r *resource.Resource, opts TranslateOptions) string {
+	attrs := getResourceAttributes(r)
+	ri, _ = resourceRequestInfo(ri, attrs, opts)
+	if dataset, ok := popDatasetOverride(attrs, opts); ok {
+		return dataset
+	}
+	return getDataset(ri, attrs, opts)
+}
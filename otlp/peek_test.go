@@ -0,0 +1,56 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestPeekDatasets(t *testing.T) {
+	span := func(attrs ...*common.KeyValue) *trace.Span {
+		return &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Attributes: attrs}
+	}
+	req := &collectortrace.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+		ScopeSpans: []*trace.ScopeSpans{{
+			Spans: []*trace.Span{span(), span(stringAttr("honeycomb.dataset", "routed"))},
+		}},
+	}, {
+		Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringAttr("honeycomb.dataset", "other")}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span()}}},
+	}, {
+		Resource:   &resource.Resource{},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span(stringAttr("honeycomb.dataset", "from-unknown"))}}},
+	}}}
+
+	assert.Equal(t, []string{"my-service", "other", "routed"}, PeekDatasets(req, testRequestInfo(), TranslateOptions{RejectUnknownService: true}))
+	assert.Equal(t, []string{"from-unknown", "my-service", "other", "routed"}, PeekDatasets(req, testRequestInfo(), TranslateOptions{}))
+
+	// the datasets match the batches translation produces
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{RejectUnknownService: true})
+	assert.NoError(t, err)
+	var batchDatasets []string
+	for _, batch := range result.Batches {
+		batchDatasets = append(batchDatasets, batch.Dataset)
+	}
+	assert.ElementsMatch(t, batchDatasets, PeekDatasets(req, testRequestInfo(), TranslateOptions{RejectUnknownService: true}))
+
+	logsReq := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-logs")
+	assert.Equal(t, []string{"my-logs"}, PeekDatasets(logsReq, testRequestInfo(), TranslateOptions{}))
+
+	assert.Nil(t, PeekDatasets(&trace.Span{}, testRequestInfo(), TranslateOptions{}))
+
+	// names are sanitized as translation sends them
+	req = buildTestTraceRequest([]*common.KeyValue{stringAttr("honeycomb.dataset", "team/a")}, span())
+	opts := TranslateOptions{SanitizeDatasetNames: true}
+	assert.Equal(t, []string{"team-a"}, PeekDatasets(req, testRequestInfo(), opts))
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", result.Batches[0].Dataset)
+}
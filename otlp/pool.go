@@ -0,0 +1,70 @@
+package otlp
+
+import "sync"
+
+var resultPool = sync.Pool{
+	New: func() interface{} { return &TranslateOTLPRequestResult{} },
+}
+
+// AcquireResult returns an empty result from the package's pool, holding the
+// batch and event storage of a previously released result when one is available
+func AcquireResult() *TranslateOTLPRequestResult {
+	return resultPool.Get().(*TranslateOTLPRequestResult)
+}
+
+// ReleaseResult resets result and returns it to the package's pool. Neither the
+// result nor any of its batches or events may be used afterwards.
+func ReleaseResult(result *TranslateOTLPRequestResult) {
+	if result == nil {
+		return
+	}
+	result.Reset()
+	resultPool.Put(result)
+}
+
+// Reset empties the result while keeping its batch and event storage for reuse
+func (r *TranslateOTLPRequestResult) Reset() {
+	for i := range r.Batches {
+		r.Batches[i].Reset()
+	}
+	for sdk := range r.EventsByTelemetrySDK {
+		delete(r.EventsByTelemetrySDK, sdk)
+	}
+	*r = TranslateOTLPRequestResult{
+		Batches:              r.Batches[:0],
+		EventsByTelemetrySDK: r.EventsByTelemetrySDK,
+		Warnings:             r.Warnings[:0],
+		events:               r.events[:0],
+	}
+}
+
+// Reset empties the batch while keeping its events' storage for reuse
+func (b *Batch) Reset() {
+	for i := range b.Events {
+		b.Events[i].Reset()
+	}
+	*b = Batch{Events: b.Events[:0]}
+}
+
+// Reset empties the event. Translation builds fresh attributes for every event,
+// so its Attributes map and Values are dropped rather than pinned by the pool.
+func (e *Event) Reset() {
+	*e = Event{}
+}
+
+// acquireResult returns the result a translation fills in. With PoolResults set
+// it comes from the pool, and any batch and event storage it holds is used unless
+// the options already provide buffers.
+func (opts TranslateOptions) acquireResult() (*TranslateOTLPRequestResult, TranslateOptions) {
+	if !opts.PoolResults {
+		return &TranslateOTLPRequestResult{}, opts
+	}
+	result := AcquireResult()
+	if cap(opts.BatchBuffer) == 0 {
+		opts.BatchBuffer = result.Batches
+	}
+	if cap(opts.EventBuffer) == 0 {
+		opts.EventBuffer = result.events
+	}
+	return result, opts
+}
@@ -0,0 +1,84 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestEventReset(t *testing.T) {
+	attrs := map[string]interface{}{"name": "span"}
+	event := Event{
+		Attributes: attrs,
+		Values:     []Attribute{{Key: "route", Value: StringValue("/")}},
+		Inherited:  map[string]interface{}{"service.name": "my-service"},
+		Span:       &SpanFields{Name: "span"},
+		Timestamp:  time.Now(),
+		SampleRate: 10,
+	}
+	event.Reset()
+	assert.Nil(t, event.Attributes, "the attribute map is released")
+	assert.Equal(t, "span", attrs["name"], "the released map is left untouched")
+	assert.Nil(t, event.Values)
+	assert.Nil(t, event.Inherited)
+	assert.Nil(t, event.Span)
+	assert.True(t, event.Timestamp.IsZero())
+	assert.Equal(t, int32(0), event.SampleRate)
+}
+
+func TestResultReset(t *testing.T) {
+	result := &TranslateOTLPRequestResult{
+		RequestSize: 100,
+		Batches: []Batch{{
+			Dataset:   "my-service",
+			SizeBytes: 100,
+			Events:    []Event{{Attributes: map[string]interface{}{"name": "span"}}},
+		}},
+		EventsByTelemetrySDK: map[TelemetrySDK]int{{Name: "opentelemetry"}: 1},
+		DroppedSpans:         1,
+		InvalidIDSpans:       1,
+		Warnings:             []string{"warning"},
+	}
+	batches := result.Batches
+	result.Reset()
+
+	assert.Empty(t, result.Batches)
+	assert.Equal(t, 1, cap(result.Batches))
+	assert.Equal(t, Batch{Events: []Event{}}, batches[0])
+	assert.Equal(t, 1, cap(batches[0].Events))
+	assert.Empty(t, result.EventsByTelemetrySDK)
+	assert.Equal(t, 0, result.RequestSize)
+	assert.Equal(t, 0, result.DroppedSpans)
+	assert.Equal(t, 0, result.InvalidIDSpans)
+	assert.Empty(t, result.Warnings)
+}
+
+func TestPoolResults(t *testing.T) {
+	newRequest := func() []*trace.Span {
+		return []*trace.Span{
+			{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "a"},
+			{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "b"},
+		}
+	}
+	opts := TranslateOptions{PoolResults: true}
+	first, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, newRequest()...), testRequestInfo(), opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(first.Batches[0].Events))
+	firstEvent := &first.Batches[0].Events[0]
+	ReleaseResult(first)
+
+	second, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, newRequest()...), testRequestInfo(), opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(second.Batches[0].Events))
+	assert.Equal(t, "a", second.Batches[0].Events[0].Attributes["name"])
+	if second != first {
+		t.Skip("the pool didn't return the released result")
+	}
+	assert.Equal(t, firstEvent, &second.Batches[0].Events[0])
+	ReleaseResult(second)
+	ReleaseResult(nil)
+}
@@ -0,0 +1,302 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	collectorProfiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1experimental"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1experimental"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// TranslateProfilesRequestFromReader translates an OTLP/HTTP profiles request into Honeycomb-friendly structure
+// RequestInfo is the parsed information from the HTTP headers
+// The body is closed exactly once, whether or not translation succeeds
+func TranslateProfilesRequestFromReader(body io.ReadCloser, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateProfilesRequestFromReaderWithOptions(body, ri, TranslateOptions{})
+}
+
+// TranslateProfilesRequestFromReaderWithOptions is like TranslateProfilesRequestFromReader
+// but applies the given TranslateOptions
+func TranslateProfilesRequestFromReaderWithOptions(body io.ReadCloser, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	defer body.Close()
+	return TranslateProfilesRequestFromReaderNoCloseWithOptions(body, ri, opts)
+}
+
+// TranslateProfilesRequestFromReaderNoClose is like TranslateProfilesRequestFromReader but takes a plain
+// io.Reader and never closes it, for callers that manage the body themselves
+func TranslateProfilesRequestFromReaderNoClose(body io.Reader, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateProfilesRequestFromReaderNoCloseWithOptions(body, ri, TranslateOptions{})
+}
+
+// TranslateProfilesRequestFromReaderNoCloseWithOptions is like TranslateProfilesRequestFromReaderNoClose
+// but applies the given TranslateOptions
+func TranslateProfilesRequestFromReaderNoCloseWithOptions(body io.Reader, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	ri = opts.allowSniffedContentType(ri)
+	if err := ri.ValidateProfilesHeaders(); err != nil {
+		return nil, err
+	}
+	request := &collectorProfiles.ExportProfilesServiceRequest{}
+	if err := parseOtlpRequestBody(body, ri, request, opts); err != nil {
+		opts.logger().Debugf("failed to parse profiles request body: %v", err)
+		if err == ErrRequestTooLarge {
+			return nil, err
+		}
+		return nil, ErrFailedParseBody
+	}
+	return TranslateProfilesRequestWithOptions(request, ri, opts)
+}
+
+// TranslateProfilesRequest translates an OTLP/gRPC profiles request into Honeycomb-friendly structure.
+// Each profile sample becomes an event carrying its stack, values and the profile's resource.
+// The OTLP profiles signal is experimental, so the generated fields may change along with it.
+func TranslateProfilesRequest(request *collectorProfiles.ExportProfilesServiceRequest, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateProfilesRequestWithOptions(request, ri, TranslateOptions{})
+}
+
+// TranslateProfilesRequestWithOptions is like TranslateProfilesRequest but applies the given TranslateOptions
+func TranslateProfilesRequestWithOptions(request *collectorProfiles.ExportProfilesServiceRequest, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	instr := startInstrumentation(opts, "profiles")
+	result, err := translateProfilesRequest(request, ri, opts)
+	instr.end(result, err)
+	return result, err
+}
+
+func translateProfilesRequest(request *collectorProfiles.ExportProfilesServiceRequest, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	if err := ri.ValidateProfilesHeaders(); err != nil {
+		return nil, err
+	}
+	labels := startProfilerLabels(opts, "profiles", request)
+	defer labels.end()
+	repairedStrings := repairRequestUTF8(request, opts)
+	verifiedKeys, err := verifyAPIKeyOverrides(len(request.ResourceProfiles), func(i int) *resource.Resource {
+		return request.ResourceProfiles[i].Resource
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var rejectedResources int
+	var batches []Batch
+	var eventsBySDK map[TelemetrySDK]int
+	for _, resourceProfile := range request.ResourceProfiles {
+		var events []Event
+		resourceAttrs := getResourceAttributes(resourceProfile.Resource)
+		resourceRI, apiKey, ok := verifiedResourceRequestInfo(ri, resourceAttrs, opts, verifiedKeys)
+		if !ok {
+			rejectedResources++
+			continue
+		}
+		dataset, ok := popDatasetOverride(resourceAttrs, opts)
+		if !ok {
+			dataset = getDataset(resourceRI, resourceAttrs, opts)
+		}
+		if dataset == "" {
+			continue
+		}
+		labels.dataset(dataset)
+		addRequestAttributes(resourceAttrs, resourceRI, opts)
+		sdk, hasSDK := getTelemetrySDK(resourceAttrs)
+		if opts.IncludeTelemetrySDK && hasSDK {
+			addTelemetrySDKAttributes(resourceAttrs, sdk)
+		}
+
+		for _, scopeProfile := range resourceProfile.ScopeProfiles {
+			scopeAttrs := getScopeAttributes(scopeProfile.Scope, opts)
+
+			for _, container := range scopeProfile.GetProfiles() {
+				profile := container.Profile
+				if profile == nil {
+					continue
+				}
+				profileAttrs := map[string]interface{}{
+					"meta.signal_type": "profile",
+				}
+				if len(container.ProfileId) > 0 {
+					profileAttrs["profile.id"] = hex.EncodeToString(container.ProfileId)
+				}
+				if profile.PeriodType != nil {
+					profileAttrs["profile.period_type"] = profileString(profile, profile.PeriodType.Type)
+					profileAttrs["profile.period_unit"] = profileString(profile, profile.PeriodType.Unit)
+					profileAttrs["profile.period"] = profile.Period
+				}
+				for k, v := range resourceAttrs {
+					profileAttrs[k] = v
+				}
+				for k, v := range scopeAttrs {
+					profileAttrs[k] = v
+				}
+				addAttributesToMap(profileAttrs, container.Attributes)
+
+				timestamp := time.Unix(0, int64(container.StartTimeUnixNano)).UTC()
+				if container.StartTimeUnixNano == 0 {
+					timestamp = time.Unix(0, profile.TimeNanos).UTC()
+				}
+
+				for _, sample := range profile.Sample {
+					attrs := make(map[string]interface{}, len(profileAttrs)+len(sample.Value)+2)
+					for k, v := range profileAttrs {
+						attrs[k] = v
+					}
+					addProfileSampleAttributes(attrs, profile, sample, opts)
+
+					sampleTimestamp := timestamp
+					if len(sample.TimestampsUnixNano) > 0 {
+						sampleTimestamp = time.Unix(0, int64(sample.TimestampsUnixNano[0])).UTC()
+					}
+					addTimestampNanos(attrs, sampleTimestamp, opts)
+					events = append(events, Event{
+						Attributes: attrs,
+						Timestamp:  sampleTimestamp,
+						SampleRate: ri.defaultSampleRate(),
+					})
+				}
+			}
+		}
+		batches = append(batches, Batch{
+			Dataset:   dataset,
+			SizeBytes: proto.Size(resourceProfile),
+			Events:    events,
+			APIKey:    apiKey,
+			Team:      resourceRI.Team,
+		})
+		if opts.IncludeTelemetrySDK && hasSDK {
+			if eventsBySDK == nil {
+				eventsBySDK = map[TelemetrySDK]int{}
+			}
+			eventsBySDK[sdk] += len(events)
+		}
+	}
+	var warnings []string
+	if clamped := clampEventTimestamps(batches, ri.now(), opts.TimestampTolerance); clamped > 0 {
+		warnings = append(warnings, fmt.Sprintf("clamped %d event timestamps outside the allowed window", clamped))
+	}
+	if rejectedResources > 0 {
+		warnings = append(warnings, fmt.Sprintf("dropped profiles from %d resources with a rejected API key override", rejectedResources))
+	}
+	if repairedStrings > 0 {
+		warnings = append(warnings, fmt.Sprintf("replaced invalid UTF-8 in %d strings", repairedStrings))
+	}
+	sanitizeBatchDatasets(batches, opts)
+	warnings = append(warnings, foldOverflowColumns(batches, opts)...)
+	oversizedEvents, warning := applyOversizedEventPolicy(batches, opts)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	applyMetaFieldOptions(batches, opts)
+	result := &TranslateOTLPRequestResult{
+		RequestSize:          proto.Size(request),
+		Batches:              batches,
+		EventsByTelemetrySDK: eventsBySDK,
+		OversizedEvents:      oversizedEvents,
+		Team:                 ri.Team,
+		Warnings:             warnings,
+	}
+	reportMetrics(opts.Hooks, result, 0, 0)
+	return result, nil
+}
+
+// addProfileSampleAttributes adds a sample's stack, values, attributes, labels and trace link
+func addProfileSampleAttributes(attrs map[string]interface{}, profile *profiles.Profile, sample *profiles.Sample, opts TranslateOptions) {
+	frames := profileSampleFrames(profile, sample)
+	if len(frames) > 0 {
+		// frames are ordered leaf first
+		attrs["profile.frame"] = frames[0]
+		attrs["profile.stack"] = strings.Join(frames, "\n")
+		attrs["profile.stack_depth"] = len(frames)
+	}
+	for i, value := range sample.Value {
+		if i >= len(profile.SampleType) {
+			break
+		}
+		sampleType := profile.SampleType[i]
+		key := "profile." + profileString(profile, sampleType.Type)
+		if unit := profileString(profile, sampleType.Unit); unit != "" {
+			key += "." + unit
+		}
+		attrs[key] = value
+	}
+	for _, index := range sample.Attributes {
+		if index < uint64(len(profile.AttributeTable)) {
+			addAttributesToMap(attrs, profile.AttributeTable[index:index+1])
+		}
+	}
+	for _, label := range sample.Label {
+		key := profileString(profile, label.Key)
+		if key == "" {
+			continue
+		}
+		if label.Str != 0 {
+			attrs[key] = profileString(profile, label.Str)
+		} else {
+			attrs[key] = label.Num
+		}
+	}
+	if sample.Link < uint64(len(profile.LinkTable)) {
+		if link := profile.LinkTable[sample.Link]; len(link.TraceId) > 0 {
+			attrs["trace.trace_id"] = opts.traceID(link.TraceId)
+			addOriginalTraceID(attrs, link.TraceId, opts)
+			attrs["trace.parent_id"] = hex.EncodeToString(link.SpanId)
+		}
+	}
+}
+
+// profileSampleFrames returns the function names of a sample's stack, leaf first.
+// Locations without function information are shown by address.
+func profileSampleFrames(profile *profiles.Profile, sample *profiles.Sample) []string {
+	var frames []string
+	for _, index := range profileSampleLocations(profile, sample) {
+		if index >= uint64(len(profile.Location)) {
+			continue
+		}
+		location := profile.Location[index]
+		if len(location.Line) == 0 {
+			frames = append(frames, fmt.Sprintf("0x%x", location.Address))
+			continue
+		}
+		// lines are ordered from the innermost inlined function outwards
+		for _, line := range location.Line {
+			if line.FunctionIndex >= uint64(len(profile.Function)) {
+				continue
+			}
+			frames = append(frames, profileString(profile, profile.Function[line.FunctionIndex].Name))
+		}
+	}
+	return frames
+}
+
+// profileSampleLocations returns the indexes into profile.Location for a sample,
+// using the location_indices table when present and the deprecated
+// location_index field when the start index and length are unset
+func profileSampleLocations(profile *profiles.Profile, sample *profiles.Sample) []uint64 {
+	if sample.LocationsLength == 0 {
+		return sample.LocationIndex
+	}
+	start, end := sample.LocationsStartIndex, sample.LocationsStartIndex+sample.LocationsLength
+	if len(profile.LocationIndices) == 0 {
+		indexes := make([]uint64, 0, sample.LocationsLength)
+		for i := start; i < end; i++ {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+	if end > uint64(len(profile.LocationIndices)) {
+		return nil
+	}
+	indexes := make([]uint64, 0, sample.LocationsLength)
+	for _, i := range profile.LocationIndices[start:end] {
+		indexes = append(indexes, uint64(i))
+	}
+	return indexes
+}
+
+// profileString looks up an index into the profile's string table
+func profileString(profile *profiles.Profile, index int64) string {
+	if index < 0 || index >= int64(len(profile.StringTable)) {
+		return ""
+	}
+	return profile.StringTable[index]
+}
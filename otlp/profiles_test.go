@@ -0,0 +1,136 @@
+package otlp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectorProfiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1experimental"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1experimental"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func buildTestProfilesRequest(start time.Time) *collectorProfiles.ExportProfilesServiceRequest {
+	return &collectorProfiles.ExportProfilesServiceRequest{
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{
+				Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")},
+			},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.ProfileContainer{{
+					ProfileId:         []byte{0x01, 0x02},
+					StartTimeUnixNano: uint64(start.UnixNano()),
+					Profile: &profiles.Profile{
+						StringTable: []string{"", "cpu", "nanoseconds", "main", "handler", "thread"},
+						SampleType:  []*profiles.ValueType{{Type: 1, Unit: 2}},
+						PeriodType:  &profiles.ValueType{Type: 1, Unit: 2},
+						Period:      10000000,
+						Function: []*profiles.Function{
+							{Id: 1, Name: 3},
+							{Id: 2, Name: 4},
+						},
+						Location: []*profiles.Location{
+							{Id: 1, Line: []*profiles.Line{{FunctionIndex: 0}}},
+							{Id: 2, Line: []*profiles.Line{{FunctionIndex: 1}}},
+							{Id: 3, Address: 0xbeef},
+						},
+						LocationIndices: []int64{2, 1, 0},
+						AttributeTable:  []*common.KeyValue{intAttr("thread.id", 7)},
+						Sample: []*profiles.Sample{
+							{
+								LocationsStartIndex: 0,
+								LocationsLength:     3,
+								Value:               []int64{20000000},
+								Attributes:          []uint64{0},
+							},
+							{
+								LocationIndex:      []uint64{0},
+								Value:              []int64{10000000},
+								Label:              []*profiles.Label{{Key: 5, Str: 3}},
+								TimestampsUnixNano: []uint64{uint64(start.Add(time.Second).UnixNano())},
+							},
+						},
+					},
+				}},
+			}},
+		}},
+	}
+}
+
+func TestTranslateProfilesRequest(t *testing.T) {
+	start := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	result, err := TranslateProfilesRequest(buildTestProfilesRequest(start), testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	batch := result.Batches[0]
+	assert.Equal(t, "my-service", batch.Dataset)
+	require.Equal(t, 2, len(batch.Events))
+
+	ev := batch.Events[0]
+	assert.Equal(t, start, ev.Timestamp)
+	assert.Equal(t, int32(1), ev.SampleRate)
+	assert.Equal(t, "profile", ev.Attributes["meta.signal_type"])
+	assert.Equal(t, "0102", ev.Attributes["profile.id"])
+	assert.Equal(t, "cpu", ev.Attributes["profile.period_type"])
+	assert.Equal(t, int64(10000000), ev.Attributes["profile.period"])
+	assert.Equal(t, "0xbeef", ev.Attributes["profile.frame"])
+	assert.Equal(t, "0xbeef\nhandler\nmain", ev.Attributes["profile.stack"])
+	assert.Equal(t, 3, ev.Attributes["profile.stack_depth"])
+	assert.Equal(t, int64(20000000), ev.Attributes["profile.cpu.nanoseconds"])
+	assert.Equal(t, int64(7), ev.Attributes["thread.id"])
+	assert.Equal(t, "my-service", ev.Attributes["service.name"])
+
+	ev = batch.Events[1]
+	assert.Equal(t, start.Add(time.Second), ev.Timestamp)
+	assert.Equal(t, "main", ev.Attributes["profile.stack"])
+	assert.Equal(t, "main", ev.Attributes["thread"])
+	assert.Equal(t, int64(10000000), ev.Attributes["profile.cpu.nanoseconds"])
+}
+
+func TestProfilesOversizedEventPolicy(t *testing.T) {
+	start := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	req := buildTestProfilesRequest(start)
+	req.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Profile.StringTable[3] = strings.Repeat("main", 1000)
+
+	result, err := TranslateProfilesRequestWithOptions(req, testRequestInfo(), TranslateOptions{
+		MaxEventSize:         2000,
+		OversizedEventPolicy: DropOversizedEvents,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.OversizedEvents)
+	assert.Empty(t, result.Batches[0].Events)
+	assert.Len(t, result.Warnings, 1)
+}
+
+func TestProfilesMaxColumnsPerBatch(t *testing.T) {
+	start := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	result, err := TranslateProfilesRequestWithOptions(buildTestProfilesRequest(start), testRequestInfo(), TranslateOptions{MaxColumnsPerBatch: 5})
+	require.NoError(t, err)
+	fields := map[string]struct{}{}
+	for _, ev := range result.Batches[0].Events {
+		for k := range ev.Attributes {
+			fields[k] = struct{}{}
+		}
+	}
+	assert.LessOrEqual(t, len(fields), 6, "the limit plus the overflow field")
+	assert.Contains(t, fields, "meta.overflow_attributes")
+	assert.Len(t, result.Warnings, 1)
+}
+
+func TestTranslateProfilesRequestFromReader(t *testing.T) {
+	start := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	body, err := proto.Marshal(buildTestProfilesRequest(start))
+	require.NoError(t, err)
+
+	result, err := TranslateProfilesRequestFromReader(io.NopCloser(bytes.NewReader(body)), testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, 2, len(result.Batches[0].Events))
+	assert.Equal(t, len(body), result.RequestSize)
+}
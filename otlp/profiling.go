@@ -0,0 +1,65 @@
+package otlp
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// profilerLabels tracks the pprof labels applied to the translating goroutine
+// when ProfilerLabels is set
+type profilerLabels struct {
+	base context.Context
+	ctx  context.Context
+}
+
+// startProfilerLabels labels the current goroutine with the signal and size bucket
+// of request, returning nil when ProfilerLabels isn't set
+func startProfilerLabels(opts TranslateOptions, signal string, request proto.Message) *profilerLabels {
+	if !opts.ProfilerLabels {
+		return nil
+	}
+	base := opts.Context
+	if base == nil {
+		base = context.Background()
+	}
+	p := &profilerLabels{base: base}
+	p.ctx = pprof.WithLabels(base, pprof.Labels(
+		"husky.signal", signal,
+		"husky.request_size", requestSizeBucket(proto.Size(request)),
+	))
+	pprof.SetGoroutineLabels(p.ctx)
+	return p
+}
+
+// dataset labels the current goroutine with the dataset being translated,
+// replacing any previous dataset label
+func (p *profilerLabels) dataset(dataset string) {
+	if p == nil {
+		return
+	}
+	pprof.SetGoroutineLabels(pprof.WithLabels(p.ctx, pprof.Labels("husky.dataset", dataset)))
+}
+
+// end restores the goroutine's labels to those of the options' Context
+func (p *profilerLabels) end() {
+	if p == nil {
+		return
+	}
+	pprof.SetGoroutineLabels(p.base)
+}
+
+// requestSizeBucket groups request sizes coarsely to keep label cardinality low
+func requestSizeBucket(size int) string {
+	switch {
+	case size < 64<<10:
+		return "<64KiB"
+	case size < 1<<20:
+		return "<1MiB"
+	case size < 8<<20:
+		return "<8MiB"
+	default:
+		return ">=8MiB"
+	}
+}
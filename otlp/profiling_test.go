@@ -0,0 +1,48 @@
+package otlp
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestRequestSizeBucket(t *testing.T) {
+	assert.Equal(t, "<64KiB", requestSizeBucket(0))
+	assert.Equal(t, "<64KiB", requestSizeBucket(64<<10-1))
+	assert.Equal(t, "<1MiB", requestSizeBucket(64<<10))
+	assert.Equal(t, "<8MiB", requestSizeBucket(1<<20))
+	assert.Equal(t, ">=8MiB", requestSizeBucket(8<<20))
+}
+
+func TestProfilerLabels(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+	assert.Nil(t, startProfilerLabels(TranslateOptions{}, "traces", req))
+
+	parent := pprof.WithLabels(context.Background(), pprof.Labels("tenant", "acme"))
+	labels := startProfilerLabels(TranslateOptions{ProfilerLabels: true, Context: parent}, "traces", req)
+	require.NotNil(t, labels)
+	defer labels.end()
+	signal, _ := pprof.Label(labels.ctx, "husky.signal")
+	assert.Equal(t, "traces", signal)
+	size, _ := pprof.Label(labels.ctx, "husky.request_size")
+	assert.Equal(t, "<64KiB", size)
+	tenant, _ := pprof.Label(labels.ctx, "tenant")
+	assert.Equal(t, "acme", tenant)
+}
+
+func TestTranslateWithProfilerLabels(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{ProfilerLabels: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(result.Batches))
+
+	logsResult, err := TranslateLogsRequestWithOptions(buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service"), testRequestInfo(), TranslateOptions{ProfilerLabels: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(logsResult.Batches))
+}
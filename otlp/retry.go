@@ -0,0 +1,61 @@
+package otlp
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RetryableError tells clients to retry their export after RetryAfter. Over HTTP
+// it's written with a Retry-After header, and over gRPC with a RetryInfo detail,
+// which OTLP clients need before they'll retry RESOURCE_EXHAUSTED.
+type RetryableError struct {
+	OTLPError
+	RetryAfter time.Duration
+}
+
+// NewThrottledError returns a 429 / RESOURCE_EXHAUSTED error asking the client to
+// slow down and retry after retryAfter
+func NewThrottledError(retryAfter time.Duration) RetryableError {
+	return RetryableError{
+		OTLPError:  OTLPError{"too many requests", http.StatusTooManyRequests, codes.ResourceExhausted},
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewUnavailableError returns a 503 / UNAVAILABLE error asking the client to retry
+// after retryAfter
+func NewUnavailableError(retryAfter time.Duration) RetryableError {
+	return RetryableError{
+		OTLPError:  OTLPError{"service unavailable", http.StatusServiceUnavailable, codes.Unavailable},
+		RetryAfter: retryAfter,
+	}
+}
+
+// grpcStatus returns the error's status with its retry delay attached
+func (e RetryableError) grpcStatus() *status.Status {
+	s := status.New(e.GRPCStatusCode, e.Message)
+	if e.RetryAfter <= 0 {
+		return s
+	}
+	if detailed, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)}); err == nil {
+		return detailed
+	}
+	return s
+}
+
+// setRetryAfterHeader sets Retry-After, in whole seconds rounded up, if err is a RetryableError
+func setRetryAfterHeader(w http.ResponseWriter, err error) {
+	var retryable RetryableError
+	if !errors.As(err, &retryable) || retryable.RetryAfter <= 0 {
+		return
+	}
+	seconds := (retryable.RetryAfter + time.Second - 1) / time.Second
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+}
@@ -0,0 +1,51 @@
+package otlp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryableErrorGRPC(t *testing.T) {
+	s := status.Convert(AsGRPCError(NewThrottledError(1500 * time.Millisecond)))
+	assert.Equal(t, codes.ResourceExhausted, s.Code())
+	require.Equal(t, 1, len(s.Details()))
+	retryInfo, ok := s.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, retryInfo.RetryDelay.AsDuration())
+
+	// wrapped errors keep their delay
+	s = status.Convert(AsGRPCError(fmt.Errorf("sink full: %w", NewUnavailableError(time.Second))))
+	assert.Equal(t, codes.Unavailable, s.Code())
+	assert.Equal(t, 1, len(s.Details()))
+
+	// no delay, no details
+	s = status.Convert(AsGRPCError(NewUnavailableError(0)))
+	assert.Equal(t, codes.Unavailable, s.Code())
+	assert.Empty(t, s.Details())
+}
+
+func TestRetryableErrorHTTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	require.NoError(t, WriteOtlpHttpError(w, RequestInfo{ContentType: "application/json"}, NewThrottledError(1500*time.Millisecond)))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "RetryInfo")
+
+	w = httptest.NewRecorder()
+	require.NoError(t, WriteOtlpHttpError(w, RequestInfo{}, NewUnavailableError(30*time.Second)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	require.NoError(t, WriteOtlpHttpError(w, RequestInfo{}, ErrFailedParseBody))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
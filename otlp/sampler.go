@@ -0,0 +1,95 @@
+package otlp
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"math"
+)
+
+// Sampler decides, for each translated event, whether to keep it and at what
+// sample rate, so agents can head sample during translation. The trace ID is
+// formatted as it appears in the event's trace.trace_id, and is empty for logs
+// not associated with a trace. The event must not be modified.
+type Sampler interface {
+	// Sample returns whether to keep the event and the sample rate to give it.
+	// A sample rate of 0 leaves the event's sample rate unchanged.
+	Sample(traceID string, event Event) (keep bool, sampleRate int32)
+}
+
+// SamplerFunc adapts an ordinary function to the Sampler interface
+type SamplerFunc func(traceID string, event Event) (bool, int32)
+
+// Sample calls f(traceID, event)
+func (f SamplerFunc) Sample(traceID string, event Event) (bool, int32) {
+	return f(traceID, event)
+}
+
+// sampleEvent asks sampler about ev, applying its sample rate and reporting
+// whether to keep it
+func sampleEvent(sampler Sampler, traceID string, ev *Event) bool {
+	keep, sampleRate := sampler.Sample(traceID, *ev)
+	if keep && sampleRate > 0 {
+		ev.SampleRate = sampleRate
+	}
+	return keep
+}
+
+// sampleEvents filters events in place with sampler, returning the events kept
+// and the number dropped
+func sampleEvents(sampler Sampler, traceID string, events []Event) ([]Event, int) {
+	kept := events[:0]
+	for i := range events {
+		if sampleEvent(sampler, traceID, &events[i]) {
+			kept = append(kept, events[i])
+		}
+	}
+	return kept, len(events) - len(kept)
+}
+
+// RefinerySalt is appended to trace IDs by Refinery's deterministic sampler.
+// Set it as a DeterministicSampler's Salt to make the same decisions as Refinery.
+const RefinerySalt = "5VQ8l2jE5aJLPVqk"
+
+// DeterministicSampler keeps 1 in SampleRate traces, deciding from a hash of the
+// trace ID alone so that every span of a trace gets the same decision on every
+// ingest node. With no Salt it makes the same decisions as the beelines'
+// deterministic sampler. Kept events have their sample rate multiplied by
+// SampleRate; events without a trace ID are always kept.
+type DeterministicSampler struct {
+	SampleRate uint32
+	Salt       string
+}
+
+// NewDeterministicSampler returns a sampler keeping 1 in sampleRate traces
+func NewDeterministicSampler(sampleRate uint32) *DeterministicSampler {
+	return &DeterministicSampler{SampleRate: sampleRate}
+}
+
+// Sample implements Sampler
+func (s *DeterministicSampler) Sample(traceID string, event Event) (bool, int32) {
+	if s.SampleRate <= 1 || traceID == "" {
+		return true, 0
+	}
+	if !s.Keep(traceID) {
+		return false, 0
+	}
+	rate := int64(event.SampleRate)
+	if rate < 1 {
+		rate = 1
+	}
+	rate *= int64(s.SampleRate)
+	if rate > math.MaxInt32 {
+		rate = math.MaxInt32
+	}
+	return true, int32(rate)
+}
+
+// Keep reports whether the trace with the given ID is sampled
+func (s *DeterministicSampler) Keep(traceID string) bool {
+	if s.SampleRate <= 1 {
+		return true
+	}
+	sum := sha1.Sum([]byte(traceID + s.Salt))
+	upperBound := math.MaxUint32 / s.SampleRate
+	return binary.BigEndian.Uint32(sum[:4]) <= upperBound
+}
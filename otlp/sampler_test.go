@@ -0,0 +1,134 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSampler(t *testing.T) {
+	keptTraceID := test.RandomBytes(16)
+	spans := []*trace.Span{
+		{TraceId: keptTraceID, SpanId: test.RandomBytes(8), Name: "kept", Events: []*trace.Span_Event{{Name: "event"}}},
+		{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "dropped"},
+	}
+	var traceIDs []string
+	sampler := SamplerFunc(func(traceID string, event Event) (bool, int32) {
+		traceIDs = append(traceIDs, traceID)
+		if name, _ := event.GetString("name"); name == "event" {
+			// keep the span event's existing sample rate
+			return true, 0
+		}
+		return traceID == hex.EncodeToString(keptTraceID), 20
+	})
+
+	result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, spans...), testRequestInfo(), TranslateOptions{Sampler: sampler})
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(traceIDs))
+	assert.Equal(t, 1, result.SampledOutEvents)
+	events := result.Batches[0].Events
+	require.Equal(t, 2, len(events))
+	assert.Equal(t, "kept", events[0].Attributes["name"])
+	assert.Equal(t, int32(20), events[0].SampleRate)
+	assert.Equal(t, "event", events[1].Attributes["name"])
+	assert.Equal(t, int32(1), events[1].SampleRate)
+}
+
+func TestSamplerLogs(t *testing.T) {
+	traceID := test.RandomBytes(16)
+	req := buildExportLogsServiceRequest(traceID, test.RandomBytes(8), time.Now(), "my-service")
+	var sampledTraceID string
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{
+		Sampler: SamplerFunc(func(traceID string, event Event) (bool, int32) {
+			sampledTraceID = traceID
+			return false, 0
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(traceID), sampledTraceID)
+	assert.Equal(t, 1, result.SampledOutEvents)
+	assert.Empty(t, result.Batches[0].Events)
+}
+
+func TestDeterministicSampler(t *testing.T) {
+	sampler := NewDeterministicSampler(10)
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		traceID := hex.EncodeToString(test.RandomBytes(16))
+		keep := sampler.Keep(traceID)
+		assert.Equal(t, keep, sampler.Keep(traceID))
+		if keep {
+			kept++
+		}
+	}
+	assert.InDelta(t, 1000, kept, 200)
+
+	// a salt changes which traces are kept
+	salted := &DeterministicSampler{SampleRate: 10, Salt: RefinerySalt}
+	differ := false
+	for i := 0; i < 100 && !differ; i++ {
+		traceID := hex.EncodeToString(test.RandomBytes(16))
+		differ = sampler.Keep(traceID) != salted.Keep(traceID)
+	}
+	assert.True(t, differ)
+
+	// a rate of 1 or less keeps everything at the existing rate
+	for _, rate := range []uint32{0, 1} {
+		keep, sampleRate := NewDeterministicSampler(rate).Sample("abc", Event{})
+		assert.True(t, keep)
+		assert.Equal(t, int32(0), sampleRate)
+	}
+	// events without a trace ID are kept
+	keep, sampleRate := sampler.Sample("", Event{SampleRate: 5})
+	assert.True(t, keep)
+	assert.Equal(t, int32(0), sampleRate)
+}
+
+func TestDeterministicSamplerSampleRate(t *testing.T) {
+	sampler := NewDeterministicSampler(4)
+	var traceID string
+	for traceID == "" || !sampler.Keep(traceID) {
+		traceID = hex.EncodeToString(test.RandomBytes(16))
+	}
+	for _, tc := range []struct {
+		existing, expected int32
+	}{
+		{existing: 0, expected: 4},
+		{existing: 1, expected: 4},
+		{existing: 5, expected: 20},
+		{existing: math.MaxInt32, expected: math.MaxInt32},
+	} {
+		keep, sampleRate := sampler.Sample(traceID, Event{SampleRate: tc.existing})
+		assert.True(t, keep)
+		assert.Equal(t, tc.expected, sampleRate)
+	}
+}
+
+func TestDeterministicSamplerKeepsWholeTraces(t *testing.T) {
+	var spans []*trace.Span
+	for i := 0; i < 50; i++ {
+		traceID := test.RandomBytes(16)
+		for j := 0; j < 3; j++ {
+			spans = append(spans, &trace.Span{TraceId: traceID, SpanId: test.RandomBytes(8), Name: "span"})
+		}
+	}
+	result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(nil, spans...), testRequestInfo(), TranslateOptions{
+		Sampler: NewDeterministicSampler(3),
+	})
+	require.NoError(t, err)
+	keptSpans := map[string]int{}
+	for _, event := range result.Batches[0].Events {
+		keptSpans[event.Attributes["trace.trace_id"].(string)]++
+		assert.Equal(t, int32(3), event.SampleRate)
+	}
+	for _, n := range keptSpans {
+		assert.Equal(t, 3, n)
+	}
+	assert.Equal(t, 150, len(result.Batches[0].Events)+result.SampledOutEvents)
+}
@@ -0,0 +1,218 @@
+package otlp
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// SpanExporter is an OpenTelemetry Go SDK exporter that translates finished spans
+// in-process and passes the result to its ResultHandler, so services embedding this
+// library don't need to serialize spans to OTLP.
+type SpanExporter struct {
+	ri      RequestInfo
+	handler ResultHandler
+
+	// Options are applied when translating each batch of spans
+	Options TranslateOptions
+}
+
+var _ sdktrace.SpanExporter = (*SpanExporter)(nil)
+
+// NewSpanExporter returns a SpanExporter that translates spans with ri and sends
+// the result to handler. ri only needs an API key, and a dataset for classic keys.
+func NewSpanExporter(ri RequestInfo, handler ResultHandler) *SpanExporter {
+	if ri.ContentType == "" {
+		// spans are never encoded, but translation requires a supported content type
+		ri.ContentType = "application/protobuf"
+	}
+	return &SpanExporter{ri: ri, handler: handler}
+}
+
+// ExportSpans translates spans and hands the result to the exporter's handler
+func (e *SpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	result, err := TranslateTraceRequestWithOptions(spansToRequest(spans), e.ri, e.Options)
+	if err != nil {
+		return err
+	}
+	return e.handler(ctx, e.ri, result)
+}
+
+// Shutdown is a no-op; the exporter holds no resources
+func (e *SpanExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// spansToRequest groups SDK spans by resource and instrumentation scope
+func spansToRequest(spans []sdktrace.ReadOnlySpan) *collectorTrace.ExportTraceServiceRequest {
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	resourceSpans := map[*sdkresource.Resource]*trace.ResourceSpans{}
+	scopeSpans := map[*sdkresource.Resource]map[string]*trace.ScopeSpans{}
+	for _, s := range spans {
+		res := s.Resource()
+		rs, ok := resourceSpans[res]
+		if !ok {
+			rs = &trace.ResourceSpans{Resource: &resource.Resource{}}
+			if res != nil {
+				rs.Resource.Attributes = sdkAttributes(res.Attributes())
+				rs.SchemaUrl = res.SchemaURL()
+			}
+			resourceSpans[res] = rs
+			scopeSpans[res] = map[string]*trace.ScopeSpans{}
+			request.ResourceSpans = append(request.ResourceSpans, rs)
+		}
+		scope := s.InstrumentationScope()
+		scopeKey := scope.Name + "\x00" + scope.Version + "\x00" + scope.SchemaURL
+		ss, ok := scopeSpans[res][scopeKey]
+		if !ok {
+			ss = &trace.ScopeSpans{
+				Scope:     &common.InstrumentationScope{Name: scope.Name, Version: scope.Version},
+				SchemaUrl: scope.SchemaURL,
+			}
+			scopeSpans[res][scopeKey] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, sdkSpan(s))
+	}
+	return request
+}
+
+func sdkSpan(s sdktrace.ReadOnlySpan) *trace.Span {
+	sc := s.SpanContext()
+	span := &trace.Span{
+		TraceId:                sdkTraceID(sc.TraceID()),
+		SpanId:                 sdkSpanID(sc.SpanID()),
+		TraceState:             sc.TraceState().String(),
+		ParentSpanId:           sdkSpanID(s.Parent().SpanID()),
+		Name:                   s.Name(),
+		Kind:                   trace.Span_SpanKind(s.SpanKind()),
+		StartTimeUnixNano:      sdkTimestamp(s.StartTime()),
+		EndTimeUnixNano:        sdkTimestamp(s.EndTime()),
+		Attributes:             sdkAttributes(s.Attributes()),
+		DroppedAttributesCount: uint32(s.DroppedAttributes()),
+		DroppedEventsCount:     uint32(s.DroppedEvents()),
+		DroppedLinksCount:      uint32(s.DroppedLinks()),
+		Status: &trace.Status{
+			Code:    sdkStatusCode(s.Status().Code),
+			Message: s.Status().Description,
+		},
+	}
+	for _, e := range s.Events() {
+		span.Events = append(span.Events, &trace.Span_Event{
+			TimeUnixNano:           sdkTimestamp(e.Time),
+			Name:                   e.Name,
+			Attributes:             sdkAttributes(e.Attributes),
+			DroppedAttributesCount: uint32(e.DroppedAttributeCount),
+		})
+	}
+	for _, l := range s.Links() {
+		span.Links = append(span.Links, &trace.Span_Link{
+			TraceId:                sdkTraceID(l.SpanContext.TraceID()),
+			SpanId:                 sdkSpanID(l.SpanContext.SpanID()),
+			TraceState:             l.SpanContext.TraceState().String(),
+			Attributes:             sdkAttributes(l.Attributes),
+			DroppedAttributesCount: uint32(l.DroppedAttributeCount),
+			Flags:                  uint32(l.SpanContext.TraceFlags()),
+		})
+	}
+	return span
+}
+
+// sdkStatusCode maps SDK status codes, which order Error before Ok, to OTLP's
+func sdkStatusCode(code otelcodes.Code) trace.Status_StatusCode {
+	switch code {
+	case otelcodes.Ok:
+		return trace.Status_STATUS_CODE_OK
+	case otelcodes.Error:
+		return trace.Status_STATUS_CODE_ERROR
+	}
+	return trace.Status_STATUS_CODE_UNSET
+}
+
+func sdkTimestamp(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// sdkTraceID returns nil for an invalid trace ID, matching an unset bytes field
+func sdkTraceID(id oteltrace.TraceID) []byte {
+	if !id.IsValid() {
+		return nil
+	}
+	return id[:]
+}
+
+// sdkSpanID returns nil for an invalid span ID, matching an unset bytes field
+func sdkSpanID(id oteltrace.SpanID) []byte {
+	if !id.IsValid() {
+		return nil
+	}
+	return id[:]
+}
+
+func sdkAttributes(kvs []attribute.KeyValue) []*common.KeyValue {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make([]*common.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		attrs = append(attrs, &common.KeyValue{Key: string(kv.Key), Value: sdkValue(kv.Value)})
+	}
+	return attrs
+}
+
+func sdkValue(v attribute.Value) *common.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		var values []*common.AnyValue
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: b}})
+		}
+		return sdkArrayValue(values)
+	case attribute.INT64SLICE:
+		var values []*common.AnyValue
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: i}})
+		}
+		return sdkArrayValue(values)
+	case attribute.FLOAT64SLICE:
+		var values []*common.AnyValue
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: f}})
+		}
+		return sdkArrayValue(values)
+	case attribute.STRINGSLICE:
+		var values []*common.AnyValue
+		for _, s := range v.AsStringSlice() {
+			values = append(values, &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: s}})
+		}
+		return sdkArrayValue(values)
+	}
+	return nil
+}
+
+func sdkArrayValue(values []*common.AnyValue) *common.AnyValue {
+	return &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{Values: values}}}
+}
@@ -0,0 +1,76 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestSpanExporter(t *testing.T) {
+	var events []Event
+	exporter := NewSpanExporter(RequestInfo{ApiKey: "abc123DEF456ghi789jklm"}, func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		for _, batch := range result.Batches {
+			assert.Equal(t, "my-service", batch.Dataset)
+			events = append(events, batch.Events...)
+		}
+		return nil
+	})
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithResource(sdkresource.NewSchemaless(attribute.String("service.name", "my-service"))),
+	)
+	tracer := tp.Tracer("my-library")
+
+	ctx, parent := tracer.Start(context.Background(), "parent", oteltrace.WithSpanKind(oteltrace.SpanKindServer))
+	_, child := tracer.Start(ctx, "child", oteltrace.WithAttributes(
+		attribute.Int("retries", 2),
+		attribute.StringSlice("tags", []string{"a", "b"}),
+	))
+	child.AddEvent("retrying")
+	child.SetStatus(otelcodes.Error, "failed")
+	child.End()
+	parent.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	require.Equal(t, 3, len(events))
+	childEvent, spanEvent, parentEvent := events[0], events[1], events[2]
+	assert.Equal(t, "child", childEvent.Attributes["name"])
+	assert.Equal(t, parentEvent.Attributes["trace.trace_id"], childEvent.Attributes["trace.trace_id"])
+	assert.Equal(t, parentEvent.Attributes["trace.span_id"], childEvent.Attributes["trace.parent_id"])
+	assert.Equal(t, int64(2), childEvent.Attributes["retries"])
+	assert.Equal(t, "[\"a\",\"b\"]\n", childEvent.Attributes["tags"])
+	assert.Equal(t, true, childEvent.Attributes["error"])
+	assert.Equal(t, "failed", childEvent.Attributes["status_message"])
+	assert.Equal(t, "my-library", childEvent.Attributes["library.name"])
+	assert.Equal(t, "retrying", spanEvent.Attributes["name"])
+	assert.Equal(t, "parent", parentEvent.Attributes["name"])
+	assert.Equal(t, "server", parentEvent.Attributes["span.kind"])
+	assert.NotContains(t, parentEvent.Attributes, "trace.parent_id")
+	assert.NotContains(t, parentEvent.Attributes, "error")
+}
+
+func TestSpanExporterHandlerError(t *testing.T) {
+	exporter := NewSpanExporter(RequestInfo{ApiKey: "abc123DEF456ghi789jklm"}, func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		return errors.New("sink unavailable")
+	})
+	span := finishedTestSpan(t)
+	assert.EqualError(t, exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span}), "sink unavailable")
+}
+
+// finishedTestSpan records and returns a single finished span
+func finishedTestSpan(t *testing.T) sdktrace.ReadOnlySpan {
+	tp := sdktrace.NewTracerProvider()
+	_, span := tp.Tracer("test").Start(context.Background(), "test_span")
+	span.End()
+	ro, ok := span.(sdktrace.ReadOnlySpan)
+	require.True(t, ok)
+	return ro
+}
@@ -0,0 +1,44 @@
+package otlp
+
+import (
+	"bufio"
+	"bytes"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// allowSniffedContentType lets requests with a missing or unsupported content type
+// through header validation when SniffContentType is set, since their bodies are
+// decoded according to what they contain
+func (opts TranslateOptions) allowSniffedContentType(ri RequestInfo) RequestInfo {
+	if opts.SniffContentType && !IsContentTypeSupported(ri.ContentType) {
+		ri.ContentType = "application/protobuf"
+	}
+	return ri
+}
+
+// sniffContentEncoding returns "gzip" or "zstd" if the body starts with their magic
+// bytes, and "" otherwise
+func sniffContentEncoding(r *bufio.Reader) string {
+	header, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return "gzip"
+	case bytes.HasPrefix(header, zstdMagic):
+		return "zstd"
+	}
+	return ""
+}
+
+// sniffContentType returns "application/json" if the decompressed body looks like a
+// JSON object, and "application/protobuf" otherwise. Every OTLP export request
+// starts with a length-delimited field, so its first byte can never be '{'.
+func sniffContentType(body []byte) string {
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return "application/json"
+	}
+	return "application/protobuf"
+}
@@ -0,0 +1,60 @@
+package otlp
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSniffContentType(t *testing.T) {
+	req := buildTestTraceRequest([]*common.KeyValue{stringAttr("service.name", "my-service")},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "sniffed"})
+
+	for _, contentType := range GetSupportedContentTypes() {
+		for _, encoding := range GetSupportedContentEncodings() {
+			t.Run(testCaseNameForContentType(contentType)+"/"+testCaseNameForEncoding(encoding), func(t *testing.T) {
+				body, err := prepareOtlpRequestHttpBody(req, contentType, encoding)
+				require.NoError(t, err)
+
+				// headers that are missing or wrong fail without sniffing
+				ri := testRequestInfo()
+				ri.ContentType = ""
+				_, err = TranslateTraceRequestFromReader(io.NopCloser(strings.NewReader(body)), ri)
+				assert.Equal(t, ErrInvalidContentType, err)
+
+				for _, headerType := range []string{"", "text/plain", "application/protobuf", "application/json"} {
+					ri.ContentType = headerType
+					result, err := TranslateTraceRequestFromReaderWithOptions(io.NopCloser(strings.NewReader(body)), ri, TranslateOptions{SniffContentType: true})
+					require.NoError(t, err, headerType)
+					require.Equal(t, 1, len(result.Batches))
+					assert.Equal(t, "sniffed", result.Batches[0].Events[0].Attributes["name"])
+				}
+			})
+		}
+	}
+}
+
+func TestSkipDecompression(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "decompressed"})
+	body, err := prepareOtlpRequestHttpBody(req, "application/protobuf", "")
+	require.NoError(t, err)
+	ri := testRequestInfo()
+	ri.ContentEncoding = "gzip"
+
+	_, err = TranslateTraceRequestFromReaderNoClose(strings.NewReader(body), ri)
+	assert.Equal(t, ErrFailedParseBody, err)
+
+	result, err := TranslateTraceRequestFromReaderNoCloseWithOptions(strings.NewReader(body), ri, TranslateOptions{SkipDecompression: true})
+	require.NoError(t, err)
+	assert.Equal(t, "decompressed", result.Batches[0].Events[0].Attributes["name"])
+
+	result, err = TranslateTraceRequestFromReaderNoCloseWithOptions(strings.NewReader(body), ri, TranslateOptions{SniffContentType: true})
+	require.NoError(t, err)
+	assert.Equal(t, "decompressed", result.Batches[0].Events[0].Attributes["name"])
+}
@@ -0,0 +1,164 @@
+package otlp
+
+import (
+	"io"
+
+	collectorLogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestStats summarizes what translating a request would produce, without
+// building any events. Counts are taken before per-event options such as
+// sampling, dropping invalid IDs or the oversized event policy are applied.
+type RequestStats struct {
+	RequestSize int
+	Spans       int
+	SpanEvents  int
+	Links       int
+	LogRecords  int
+	// Events is the number of events translation would produce: one per span,
+	// span event, link and log record, excluding those without a dataset
+	Events int
+	// EventsByDataset counts events by the dataset they'd be sent to
+	EventsByDataset map[string]int
+	// DroppedEvents counts events that would be dropped for having no dataset
+	DroppedEvents int
+	// AttributeKeys counts how often each resource, scope, span, span event,
+	// link and log record attribute key appears in the request
+	AttributeKeys map[string]int
+
+	opts TranslateOptions
+}
+
+// TraceRequestStatsFromReader is like TraceRequestStats but parses the request
+// from an OTLP/HTTP body. The body is never closed.
+func TraceRequestStatsFromReader(body io.Reader, ri RequestInfo, opts TranslateOptions) (*RequestStats, error) {
+	ri = opts.allowSniffedContentType(ri)
+	if err := ri.ValidateTracesHeaders(); err != nil {
+		return nil, err
+	}
+	request := &collectorTrace.ExportTraceServiceRequest{}
+	if err := parseOtlpRequestBody(body, ri, request, opts); err != nil {
+		opts.logger().Debugf("failed to parse trace request body: %v", err)
+		if err == ErrRequestTooLarge {
+			return nil, err
+		}
+		return nil, ErrFailedParseBody
+	}
+	return TraceRequestStats(request, ri, opts)
+}
+
+// TraceRequestStats walks a trace request and counts what TranslateTraceRequestWithOptions
+// would produce, for validation endpoints and capacity planning that don't need the events.
+// It validates headers and enforces request limits in the same way.
+func TraceRequestStats(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo, opts TranslateOptions) (*RequestStats, error) {
+	if err := ri.ValidateTracesHeaders(); err != nil {
+		return nil, err
+	}
+	upgradeLegacyTraceRequest(request)
+	if err := checkTraceRequestLimits(request, opts); err != nil {
+		return nil, err
+	}
+	stats := newRequestStats(request, opts)
+	for _, resourceSpan := range request.ResourceSpans {
+		stats.countKeys(resourceSpan.Resource.GetAttributes())
+		dataset := getResourceDataset(ri, resourceSpan.Resource, opts)
+		for _, scopeSpan := range resourceSpan.ScopeSpans {
+			stats.countKeys(scopeSpan.Scope.GetAttributes())
+			scopeDataset, ok := attributeDatasetOverride(scopeSpan.Scope.GetAttributes(), opts)
+			if !ok {
+				scopeDataset = dataset
+			}
+			for _, span := range scopeSpan.Spans {
+				stats.Spans++
+				stats.SpanEvents += len(span.Events)
+				stats.Links += len(span.Links)
+				stats.countKeys(span.Attributes)
+				for _, sevent := range span.Events {
+					stats.countKeys(sevent.Attributes)
+				}
+				for _, slink := range span.Links {
+					stats.countKeys(slink.Attributes)
+				}
+				spanDataset, ok := attributeDatasetOverride(span.Attributes, opts)
+				if !ok {
+					spanDataset = scopeDataset
+				}
+				// resources without a dataset are dropped whole, overrides and all
+				if dataset == "" {
+					spanDataset = ""
+				}
+				stats.addEvents(spanDataset, 1+len(span.Events)+len(span.Links))
+			}
+		}
+	}
+	return stats, nil
+}
+
+// LogsRequestStats walks a logs request and counts what TranslateLogsRequestWithOptions
+// would produce, for validation endpoints and capacity planning that don't need the events.
+// It validates headers and enforces request limits in the same way.
+func LogsRequestStats(request *collectorLogs.ExportLogsServiceRequest, ri RequestInfo, opts TranslateOptions) (*RequestStats, error) {
+	if err := ri.ValidateLogsHeaders(); err != nil {
+		return nil, err
+	}
+	upgradeLegacyLogsRequest(request)
+	if err := checkLogsRequestLimits(request, opts); err != nil {
+		return nil, err
+	}
+	stats := newRequestStats(request, opts)
+	for _, resourceLog := range request.ResourceLogs {
+		stats.countKeys(resourceLog.Resource.GetAttributes())
+		dataset := getLogsResourceDataset(ri, resourceLog.Resource, opts)
+		for _, scopeLog := range resourceLog.ScopeLogs {
+			stats.countKeys(scopeLog.Scope.GetAttributes())
+			scopeDataset, ok := attributeDatasetOverride(scopeLog.Scope.GetAttributes(), opts)
+			if !ok {
+				scopeDataset = dataset
+			}
+			for _, log := range scopeLog.LogRecords {
+				stats.LogRecords++
+				stats.countKeys(log.Attributes)
+				logDataset, ok := attributeDatasetOverride(log.Attributes, opts)
+				if !ok {
+					logDataset = scopeDataset
+				}
+				// resources without a dataset are dropped whole, overrides and all
+				if dataset == "" {
+					logDataset = ""
+				}
+				stats.addEvents(logDataset, 1)
+			}
+		}
+	}
+	return stats, nil
+}
+
+func newRequestStats(request proto.Message, opts TranslateOptions) *RequestStats {
+	return &RequestStats{
+		opts:            opts,
+		RequestSize:     proto.Size(request),
+		EventsByDataset: map[string]int{},
+		AttributeKeys:   map[string]int{},
+	}
+}
+
+func (s *RequestStats) addEvents(dataset string, n int) {
+	if dataset == "" {
+		s.DroppedEvents += n
+		return
+	}
+	s.Events += n
+	s.EventsByDataset[sentDatasetName(dataset, s.opts)] += n
+}
+
+// countKeys counts the keys of attributes that translation would keep
+func (s *RequestStats) countKeys(attributes []*common.KeyValue) {
+	for _, attr := range attributes {
+		if attr.Key != "" && attr.Value != nil {
+			s.AttributeKeys[attr.Key]++
+		}
+	}
+}
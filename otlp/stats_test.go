@@ -0,0 +1,117 @@
+package otlp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTraceRequestStats(t *testing.T) {
+	newSpan := func(attrs ...*common.KeyValue) *trace.Span {
+		return &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "span", Attributes: attrs}
+	}
+	routed := newSpan(stringAttr("honeycomb.dataset", "routed"), stringAttr("route", "/users"))
+	routed.Events = []*trace.Span_Event{{Name: "event", Attributes: []*common.KeyValue{stringAttr("route", "/users")}}}
+	routed.Links = []*trace.Span_Link{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}}
+	req := &collectortrace.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+		ScopeSpans: []*trace.ScopeSpans{
+			{Spans: []*trace.Span{newSpan(stringAttr("route", "/")), routed}},
+			{
+				Scope: &common.InstrumentationScope{Attributes: []*common.KeyValue{stringAttr("honeycomb.table", "scoped")}},
+				Spans: []*trace.Span{newSpan(), newSpan(&common.KeyValue{Key: "nil_value"})},
+			},
+		},
+	}, {
+		Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "")}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{newSpan(stringAttr("honeycomb.dataset", "ignored"))}}},
+	}}}
+	opts := TranslateOptions{RejectUnknownService: true}
+
+	stats, err := TraceRequestStats(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, proto.Size(req), stats.RequestSize)
+	assert.Equal(t, 5, stats.Spans)
+	assert.Equal(t, 1, stats.SpanEvents)
+	assert.Equal(t, 1, stats.Links)
+	assert.Equal(t, 6, stats.Events)
+	assert.Equal(t, 1, stats.DroppedEvents)
+	assert.Equal(t, map[string]int{"my-service": 1, "routed": 3, "scoped": 2}, stats.EventsByDataset)
+	assert.Equal(t, map[string]int{
+		"service.name":      2,
+		"honeycomb.dataset": 2,
+		"honeycomb.table":   1,
+		"route":             3,
+	}, stats.AttributeKeys)
+
+	// the counts match what translation produces
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	translated := map[string]int{}
+	for _, batch := range result.Batches {
+		translated[batch.Dataset] += len(batch.Events)
+	}
+	assert.Equal(t, stats.EventsByDataset, translated)
+	assert.Equal(t, stats.DroppedEvents, result.DroppedSpans)
+
+	// datasets are counted by the sanitized names translation sends them with
+	opts.SanitizeDatasetNames = true
+	stats, err = TraceRequestStats(buildTestTraceRequest([]*common.KeyValue{stringAttr("service.name", "team/a")}, newSpan()), testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"team-a": 1}, stats.EventsByDataset)
+}
+
+func TestTraceRequestStatsErrors(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{}, &trace.Span{})
+	_, err := TraceRequestStats(req, RequestInfo{ContentType: "application/protobuf"}, TranslateOptions{})
+	assert.Equal(t, ErrMissingAPIKeyHeader, err)
+	_, err = TraceRequestStats(req, testRequestInfo(), TranslateOptions{MaxSpansPerRequest: 1})
+	assert.Equal(t, ErrTooManySpans, err)
+}
+
+func TestTraceRequestStatsFromReader(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+	stats, err := TraceRequestStatsFromReader(bytes.NewReader(body), testRequestInfo(), TranslateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Spans)
+	assert.Equal(t, map[string]int{defaultServiceName: 1}, stats.EventsByDataset)
+
+	_, err = TraceRequestStatsFromReader(bytes.NewReader([]byte("not protobuf")), testRequestInfo(), TranslateOptions{})
+	assert.Equal(t, ErrFailedParseBody, err)
+}
+
+func TestLogsRequestStats(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	stats, err := LogsRequestStats(req, testRequestInfo(), TranslateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, proto.Size(req), stats.RequestSize)
+	assert.Equal(t, 1, stats.LogRecords)
+	assert.Equal(t, 1, stats.Events)
+	assert.Equal(t, map[string]int{"my-service": 1}, stats.EventsByDataset)
+	assert.Equal(t, 1, stats.AttributeKeys["service.name"])
+
+	result, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.Equal(t, stats.Events, len(result.Batches[0].Events))
+
+	// logs resolved to no dataset are dropped, like translation drops them
+	opts := TranslateOptions{DatasetResolver: DatasetResolverFunc(func(ri RequestInfo, resourceAttrs map[string]interface{}) string {
+		return ""
+	})}
+	stats, err = LogsRequestStats(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Events)
+	assert.Equal(t, 1, stats.DroppedEvents)
+	assert.Empty(t, PeekDatasets(req, testRequestInfo(), opts))
+}
@@ -0,0 +1,158 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Team identifies the Honeycomb team and environment an API key belongs to
+type Team struct {
+	Slug            string `json:"slug"`
+	Name            string `json:"name,omitempty"`
+	EnvironmentSlug string `json:"environment_slug,omitempty"`
+	EnvironmentName string `json:"environment_name,omitempty"`
+}
+
+// TeamResolver verifies an API key and returns the team it belongs to.
+// Implementations return an OTLPError or RetryableError to control the response
+// sent for a rejected key; any other error is treated as an invalid key.
+type TeamResolver interface {
+	ResolveTeam(ctx context.Context, apiKey string) (Team, error)
+}
+
+// TeamResolverFunc adapts an ordinary function to the TeamResolver interface
+type TeamResolverFunc func(ctx context.Context, apiKey string) (Team, error)
+
+// ResolveTeam calls f(ctx, apiKey)
+func (f TeamResolverFunc) ResolveTeam(ctx context.Context, apiKey string) (Team, error) {
+	return f(ctx, apiKey)
+}
+
+type teamContextKey struct{}
+
+// ContextWithTeam returns a copy of ctx carrying team, which GetRequestInfoFromHTTPRequest
+// and GetRequestInfoFromGrpcMetadata copy into RequestInfo.Team
+func ContextWithTeam(ctx context.Context, team Team) context.Context {
+	return context.WithValue(ctx, teamContextKey{}, team)
+}
+
+// TeamFromContext returns the team stored in ctx by ContextWithTeam, if any
+func TeamFromContext(ctx context.Context) (Team, bool) {
+	team, ok := ctx.Value(teamContextKey{}).(Team)
+	return team, ok
+}
+
+// TeamAuthMiddleware is like AuthMiddleware but resolves the API key's team and
+// stores it in the request context for translation to attach to its result.
+// Like AuthMiddleware, it passes CORS preflight requests straight to next.
+func TeamAuthMiddleware(resolver TeamResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isCORSPreflight(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ri := GetRequestInfoFromHTTPRequest(r)
+		team, err := resolveTeam(r.Context(), resolver, ri.ApiKey)
+		if err != nil {
+			WriteOtlpHttpError(w, ri, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithTeam(r.Context(), team)))
+	})
+}
+
+// TeamAuthUnaryServerInterceptor is like AuthUnaryServerInterceptor but resolves the
+// API key's team and stores it in the handler's context
+func TeamAuthUnaryServerInterceptor(resolver TeamResolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ri := GetRequestInfoFromGrpcMetadata(ctx)
+		team, err := resolveTeam(ctx, resolver, ri.ApiKey)
+		if err != nil {
+			return nil, AsGRPCError(err)
+		}
+		return handler(ContextWithTeam(ctx, team), req)
+	}
+}
+
+// resolveTeam is like authenticate, always returning an OTLPError or a RetryableError
+func resolveTeam(ctx context.Context, resolver TeamResolver, apiKey string) (Team, error) {
+	var team Team
+	err := authenticate(ctx, KeyValidatorFunc(func(ctx context.Context, apiKey string) error {
+		var err error
+		team, err = resolver.ResolveTeam(ctx, apiKey)
+		return err
+	}), apiKey)
+	return team, err
+}
+
+// defaultHoneycombAPIHost is where HoneycombTeamResolver verifies keys if no APIHost is set
+const defaultHoneycombAPIHost = "https://api.honeycomb.io"
+
+// maxHoneycombAuthResponseSize caps how much of an /1/auth response is decoded
+const maxHoneycombAuthResponseSize = 64 << 10
+
+// HoneycombTeamResolver resolves teams with Honeycomb's auth API
+type HoneycombTeamResolver struct {
+	// APIHost is the Honeycomb API to call, https://api.honeycomb.io if empty
+	APIHost string
+	// Client makes the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+type honeycombAuthResponse struct {
+	Team struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"team"`
+	Environment struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"environment"`
+}
+
+// ResolveTeam implements TeamResolver by calling the /1/auth endpoint. Keys the API
+// rejects return ErrInvalidAPIKey; other failures, including a malformed APIHost,
+// return an error asking the client to retry, since the key may well be valid.
+// TeamCache never caches those.
+func (h HoneycombTeamResolver) ResolveTeam(ctx context.Context, apiKey string) (Team, error) {
+	host := h.APIHost
+	if host == "" {
+		host = defaultHoneycombAPIHost
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(host, "/")+"/1/auth", nil)
+	if err != nil {
+		// a misconfigured host says nothing about the key
+		return Team{}, NewUnavailableError(time.Second)
+	}
+	req.Header.Set(apiKeyHeader, apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Team{}, NewUnavailableError(time.Second)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return Team{}, ErrInvalidAPIKey
+	case resp.StatusCode != http.StatusOK:
+		return Team{}, NewUnavailableError(time.Second)
+	}
+	var auth honeycombAuthResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxHoneycombAuthResponseSize)).Decode(&auth); err != nil {
+		return Team{}, NewUnavailableError(time.Second)
+	}
+	return Team{
+		Slug:            auth.Team.Slug,
+		Name:            auth.Team.Name,
+		EnvironmentSlug: auth.Environment.Slug,
+		EnvironmentName: auth.Environment.Name,
+	}, nil
+}
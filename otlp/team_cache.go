@@ -0,0 +1,151 @@
+package otlp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTeamCacheTTL         = 5 * time.Minute
+	defaultTeamCacheNegativeTTL = 30 * time.Second
+)
+
+// TeamCacheHooks receives TeamCache lookups, so callers can track its hit rate.
+// Methods are called synchronously, so they should be cheap.
+// Embed NoopTeamCacheHooks to implement only the methods you need.
+type TeamCacheHooks interface {
+	// Hit is called when a key is found in the cache; negative is true if it
+	// was cached as rejected
+	Hit(negative bool)
+	// Miss is called when a key has to be resolved
+	Miss()
+	// Evicted is called when a key is removed to make room for another
+	Evicted()
+}
+
+// NoopTeamCacheHooks implements TeamCacheHooks by doing nothing
+type NoopTeamCacheHooks struct{}
+
+func (NoopTeamCacheHooks) Hit(negative bool) {}
+func (NoopTeamCacheHooks) Miss()             {}
+func (NoopTeamCacheHooks) Evicted()          {}
+
+// TeamCache is a TeamResolver that caches another resolver's results, so busy
+// ingest doesn't call the auth API for every request. It holds at most its size
+// in keys, evicting the least recently used. Rejected keys are cached too, but
+// RetryableErrors and context errors are not, since the key may be valid.
+// It's safe for concurrent use.
+type TeamCache struct {
+	resolver TeamResolver
+	size     int
+
+	// TTL is how long a resolved team is cached, 5 minutes if zero
+	TTL time.Duration
+	// NegativeTTL is how long a rejected key is cached, 30 seconds if zero
+	NegativeTTL time.Duration
+	// Hooks, if set, is told about each lookup
+	Hooks TeamCacheHooks
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type teamCacheEntry struct {
+	apiKey  string
+	team    Team
+	err     error
+	expires time.Time
+}
+
+// NewTeamCache returns a TeamCache holding up to size keys resolved by resolver
+func NewTeamCache(resolver TeamResolver, size int) *TeamCache {
+	return &TeamCache{
+		resolver: resolver,
+		size:     size,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// ResolveTeam implements TeamResolver, returning a cached result if there is one
+func (c *TeamCache) ResolveTeam(ctx context.Context, apiKey string) (Team, error) {
+	if entry, ok := c.get(apiKey); ok {
+		if c.Hooks != nil {
+			c.Hooks.Hit(entry.err != nil)
+		}
+		return entry.team, entry.err
+	}
+	if c.Hooks != nil {
+		c.Hooks.Miss()
+	}
+	team, err := c.resolver.ResolveTeam(ctx, apiKey)
+	if err == nil {
+		c.put(teamCacheEntry{apiKey: apiKey, team: team}, c.TTL, defaultTeamCacheTTL)
+	} else if isCacheableTeamError(err) {
+		c.put(teamCacheEntry{apiKey: apiKey, err: err}, c.NegativeTTL, defaultTeamCacheNegativeTTL)
+	}
+	return team, err
+}
+
+// Len returns the number of keys in the cache, including expired ones not yet removed
+func (c *TeamCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *TeamCache) get(apiKey string) (teamCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[apiKey]
+	if !ok {
+		return teamCacheEntry{}, false
+	}
+	entry := elem.Value.(*teamCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, apiKey)
+		return teamCacheEntry{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return *entry, true
+}
+
+func (c *TeamCache) put(entry teamCacheEntry, ttl, defaultTTL time.Duration) {
+	if c.size <= 0 {
+		return
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	entry.expires = c.now().Add(ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.apiKey]; ok {
+		*elem.Value.(*teamCacheEntry) = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.apiKey] = c.lru.PushFront(&entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*teamCacheEntry).apiKey)
+		if c.Hooks != nil {
+			c.Hooks.Evicted()
+		}
+	}
+}
+
+// isCacheableTeamError reports whether a resolver error rejects the key itself,
+// rather than being a failure to check it
+func isCacheableTeamError(err error) bool {
+	var retryable RetryableError
+	return !errors.As(err, &retryable) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
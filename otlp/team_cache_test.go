@@ -0,0 +1,115 @@
+package otlp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTeamCacheHooks struct {
+	NoopTeamCacheHooks
+	hits, negativeHits, misses, evictions int
+}
+
+func (h *testTeamCacheHooks) Hit(negative bool) {
+	if negative {
+		h.negativeHits++
+	} else {
+		h.hits++
+	}
+}
+func (h *testTeamCacheHooks) Miss()    { h.misses++ }
+func (h *testTeamCacheHooks) Evicted() { h.evictions++ }
+
+func newTestTeamCache(size int) (*TeamCache, map[string]int, *time.Time) {
+	calls := map[string]int{}
+	cache := NewTeamCache(TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+		calls[apiKey]++
+		return testTeamResolver(ctx, apiKey)
+	}), size)
+	now := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	return cache, calls, &now
+}
+
+func TestTeamCache(t *testing.T) {
+	cache, calls, now := newTestTeamCache(10)
+	hooks := &testTeamCacheHooks{}
+	cache.Hooks = hooks
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		team, err := cache.ResolveTeam(ctx, "good-key")
+		require.NoError(t, err)
+		assert.Equal(t, "my-team", team.Slug)
+		_, err = cache.ResolveTeam(ctx, "bad-key")
+		assert.Error(t, err)
+		_, err = cache.ResolveTeam(ctx, "flaky-key")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, map[string]int{"good-key": 1, "bad-key": 1, "flaky-key": 3}, calls)
+	assert.Equal(t, 2, hooks.hits)
+	assert.Equal(t, 2, hooks.negativeHits)
+	assert.Equal(t, 5, hooks.misses)
+
+	// rejections expire sooner than teams
+	*now = now.Add(time.Minute)
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "bad-key")
+	assert.Equal(t, 1, calls["good-key"])
+	assert.Equal(t, 2, calls["bad-key"])
+
+	*now = now.Add(5 * time.Minute)
+	cache.ResolveTeam(ctx, "good-key")
+	assert.Equal(t, 2, calls["good-key"])
+}
+
+func TestTeamCacheTTL(t *testing.T) {
+	cache, calls, now := newTestTeamCache(10)
+	cache.TTL = time.Second
+	cache.NegativeTTL = time.Hour
+	ctx := context.Background()
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "bad-key")
+	*now = now.Add(time.Minute)
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "bad-key")
+	assert.Equal(t, map[string]int{"good-key": 2, "bad-key": 1}, calls)
+}
+
+func TestTeamCacheEviction(t *testing.T) {
+	cache, calls, _ := newTestTeamCache(2)
+	hooks := &testTeamCacheHooks{}
+	cache.Hooks = hooks
+	ctx := context.Background()
+
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "bad-key")
+	// using good-key keeps it over bad-key
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "other-key")
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, 1, hooks.evictions)
+
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "bad-key")
+	assert.Equal(t, map[string]int{"good-key": 1, "bad-key": 2, "other-key": 1}, calls)
+
+	// a cache without room caches nothing
+	cache, calls, _ = newTestTeamCache(0)
+	cache.ResolveTeam(ctx, "good-key")
+	cache.ResolveTeam(ctx, "good-key")
+	assert.Equal(t, 2, calls["good-key"])
+}
+
+func TestTeamCacheWithMiddleware(t *testing.T) {
+	cache, calls, _ := newTestTeamCache(10)
+	for i := 0; i < 2; i++ {
+		_, err := resolveTeam(context.Background(), cache, "bad-key")
+		assert.Equal(t, ErrInvalidAPIKey, err)
+	}
+	assert.Equal(t, 1, calls["bad-key"])
+}
@@ -0,0 +1,138 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var testTeamResolver = TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+	switch apiKey {
+	case "good-key":
+		return Team{Slug: "my-team", EnvironmentSlug: "prod"}, nil
+	case "flaky-key":
+		return Team{}, NewUnavailableError(2 * time.Second)
+	}
+	return Team{}, errors.New("nope")
+})
+
+func TestTeamAuthMiddleware(t *testing.T) {
+	var ri RequestInfo
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ri = GetRequestInfoFromHTTPRequest(r)
+		w.Write([]byte("ok"))
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/traces", nil)
+	r.Header.Set("X-Honeycomb-Team", "good-key")
+	w := httptest.NewRecorder()
+	TeamAuthMiddleware(testTeamResolver, next).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &Team{Slug: "my-team", EnvironmentSlug: "prod"}, ri.Team)
+
+	r.Header.Set("X-Honeycomb-Team", "bad-key")
+	w = httptest.NewRecorder()
+	TeamAuthMiddleware(testTeamResolver, next).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	decoded := &spb.Status{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), decoded))
+	assert.Equal(t, ErrInvalidAPIKey.Message, decoded.Message)
+
+	r.Header.Set("X-Honeycomb-Team", "flaky-key")
+	w = httptest.NewRecorder()
+	TeamAuthMiddleware(testTeamResolver, next).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestTeamAuthMiddlewarePassesCORSPreflights(t *testing.T) {
+	handler := NewHTTPHandler(func(ctx context.Context, ri RequestInfo, result *TranslateOTLPRequestResult) error {
+		return nil
+	})
+	handler.CORS = &CORSOptions{AllowedOrigins: []string{"https://example.com"}}
+	server := TeamAuthMiddleware(NewTeamCache(testTeamResolver, 10), handler)
+
+	r := httptest.NewRequest(http.MethodOptions, TracesPath, nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestTeamAuthUnaryServerInterceptor(t *testing.T) {
+	var ri RequestInfo
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		ri = GetRequestInfoFromGrpcMetadata(ctx)
+		return "ok", nil
+	}
+	interceptor := TeamAuthUnaryServerInterceptor(testTeamResolver)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(apiKeyHeader, "good-key"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, &Team{Slug: "my-team", EnvironmentSlug: "prod"}, ri.Team)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(apiKeyHeader, "bad-key"))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTeamAttachedToResult(t *testing.T) {
+	ri := testRequestInfo()
+	ri.Team = &Team{Slug: "my-team"}
+	result, err := TranslateTraceRequest(buildTestTraceRequest(nil), ri)
+	require.NoError(t, err)
+	assert.Equal(t, ri.Team, result.Team)
+}
+
+func TestHoneycombTeamResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/1/auth", r.URL.Path)
+		switch r.Header.Get("X-Honeycomb-Team") {
+		case "good-key":
+			w.Write([]byte(`{"id":"abc","team":{"name":"My Team","slug":"my-team"},"environment":{"name":"Production","slug":"prod"}}`))
+		case "bad-key":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "huge-key":
+			w.Write([]byte(`{"team":{"name":"` + strings.Repeat("a", maxHoneycombAuthResponseSize) + `"}}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	resolver := HoneycombTeamResolver{APIHost: server.URL + "/", Client: server.Client()}
+
+	team, err := resolver.ResolveTeam(context.Background(), "good-key")
+	require.NoError(t, err)
+	assert.Equal(t, Team{Slug: "my-team", Name: "My Team", EnvironmentSlug: "prod", EnvironmentName: "Production"}, team)
+
+	_, err = resolver.ResolveTeam(context.Background(), "bad-key")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+
+	_, err = resolver.ResolveTeam(context.Background(), "other-key")
+	assert.Equal(t, http.StatusServiceUnavailable, httpStatusCode(err))
+
+	// responses are only decoded up to a limit
+	_, err = resolver.ResolveTeam(context.Background(), "huge-key")
+	assert.Equal(t, http.StatusServiceUnavailable, httpStatusCode(err))
+
+	// a bad host is a configuration problem, not a bad key
+	_, err = HoneycombTeamResolver{APIHost: "http://[::1"}.ResolveTeam(context.Background(), "good-key")
+	assert.Equal(t, http.StatusServiceUnavailable, httpStatusCode(err))
+	assert.False(t, isCacheableTeamError(err))
+}
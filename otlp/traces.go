@@ -2,12 +2,17 @@ package otlp
 
 import (
 	"encoding/hex"
+	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
 	"google.golang.org/protobuf/proto"
 )
@@ -16,165 +21,560 @@ const (
 	traceIDShortLength = 8
 	traceIDLongLength  = 16
 	defaultSampleRate  = int32(1)
+	// traceFlagSampled is the W3C sampled bit within a span, link or log record's flags
+	traceFlagSampled = uint32(0x01)
+
+	// roughly how many fields we add to each event ourselves, on top of resource,
+	// scope and span attributes, so event maps can be allocated at their final size
+	spanFieldCount      = 16
+	spanEventFieldCount = 8
+	linkFieldCount      = 10
 )
 
+// defaultSampleRateKeys are the span attributes checked for a sample rate, in order of precedence
+var defaultSampleRateKeys = []string{"sampleRate", "SampleRate"}
+
 // TranslateTraceRequestFromReader translates an OTLP/HTTP request into Honeycomb-friendly structure
 // RequestInfo is the parsed information from the HTTP headers
+// The body is closed exactly once, whether or not translation succeeds
 func TranslateTraceRequestFromReader(body io.ReadCloser, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateTraceRequestFromReaderWithOptions(body, ri, TranslateOptions{})
+}
+
+// TranslateTraceRequestFromReaderWithOptions is like TranslateTraceRequestFromReader
+// but applies the given TranslateOptions
+func TranslateTraceRequestFromReaderWithOptions(body io.ReadCloser, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	defer body.Close()
+	return TranslateTraceRequestFromReaderNoCloseWithOptions(body, ri, opts)
+}
+
+// TranslateTraceRequestFromReaderNoClose is like TranslateTraceRequestFromReader but takes a plain
+// io.Reader and never closes it, for callers that manage the body themselves
+func TranslateTraceRequestFromReaderNoClose(body io.Reader, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateTraceRequestFromReaderNoCloseWithOptions(body, ri, TranslateOptions{})
+}
+
+// TranslateTraceRequestFromReaderNoCloseWithOptions is like TranslateTraceRequestFromReaderNoClose
+// but applies the given TranslateOptions
+func TranslateTraceRequestFromReaderNoCloseWithOptions(body io.Reader, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	ri = opts.allowSniffedContentType(ri)
 	if err := ri.ValidateTracesHeaders(); err != nil {
 		return nil, err
 	}
 	request := &collectorTrace.ExportTraceServiceRequest{}
-	if err := parseOtlpRequestBody(body, ri.ContentType, ri.ContentEncoding, request); err != nil {
+	if err := parseOtlpRequestBody(body, ri, request, opts); err != nil {
+		opts.logger().Debugf("failed to parse trace request body: %v", err)
+		if err == ErrRequestTooLarge {
+			return nil, err
+		}
 		return nil, ErrFailedParseBody
 	}
-	return TranslateTraceRequest(request, ri)
+	return TranslateTraceRequestWithOptions(request, ri, opts)
 }
 
 // TranslateTraceRequest translates an OTLP/gRPC request into Honeycomb-friendly structure
 // RequestInfo is the parsed information from the gRPC metadata
+// Spans sent by old SDKs as instrumentation_library_spans are moved into the
+// request's ScopeSpans, so the request may be modified
 func TranslateTraceRequest(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo) (*TranslateOTLPRequestResult, error) {
+	return TranslateTraceRequestWithOptions(request, ri, TranslateOptions{})
+}
+
+// TranslateTraceRequestWithOptions is like TranslateTraceRequest but applies the given TranslateOptions
+func TranslateTraceRequestWithOptions(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
+	instr := startInstrumentation(opts, "traces")
+	result, err := translateTraceRequest(request, ri, opts)
+	instr.end(result, err)
+	return result, err
+}
+
+func translateTraceRequest(request *collectorTrace.ExportTraceServiceRequest, ri RequestInfo, opts TranslateOptions) (*TranslateOTLPRequestResult, error) {
 	if err := ri.ValidateTracesHeaders(); err != nil {
 		return nil, err
 	}
-	var batches []Batch
+	labels := startProfilerLabels(opts, "traces", request)
+	defer labels.end()
+	upgradeLegacyTraceRequest(request)
+	if err := checkTraceRequestLimits(request, opts); err != nil {
+		return nil, err
+	}
+	repairedStrings := repairRequestUTF8(request, opts)
+	verifiedKeys, err := verifyAPIKeyOverrides(len(request.ResourceSpans), func(i int) *resource.Resource {
+		return request.ResourceSpans[i].Resource
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	result, opts := opts.acquireResult()
+	batches := opts.newBatches(len(request.ResourceSpans))
+	totalEvents := 0
+	for _, resourceSpan := range request.ResourceSpans {
+		totalEvents += countSpanEvents(resourceSpan)
+	}
+	arena := newEventArena(opts.EventBuffer, totalEvents)
+	var eventsBySDK map[TelemetrySDK]int
+	var droppedSpans int
+	var coercedSampleRates int
+	var truncatedSpanNames int
+	var truncatedStatusMessages int
+	var translatedSpans, droppedAttributes int
+	var shortenedTraceIDs int
+	var invalidIDSpans int
+	var sampledOutEvents int
+	var clampedInvalidDurations, droppedInvalidDurations int
+	var warnings []string
 	for _, resourceSpan := range request.ResourceSpans {
-		var events []Event
+		var routed routedBatches
 		resourceAttrs := getResourceAttributes(resourceSpan.Resource)
-		dataset := getDataset(ri, resourceAttrs)
+		resourceRI, apiKey, ok := verifiedResourceRequestInfo(ri, resourceAttrs, opts, verifiedKeys)
+		if !ok {
+			n := countSpans(resourceSpan)
+			droppedSpans += n
+			warnings = append(warnings, fmt.Sprintf("dropped %d spans with a rejected API key override", n))
+			continue
+		}
+		dataset, ok := popDatasetOverride(resourceAttrs, opts)
+		if !ok {
+			dataset = getDataset(resourceRI, resourceAttrs, opts)
+		}
+		if dataset == "" {
+			n := countSpans(resourceSpan)
+			droppedSpans += n
+			warnings = append(warnings, fmt.Sprintf("dropped %d spans without a dataset", n))
+			continue
+		}
+		labels.dataset(dataset)
+		events := arena.take(countSpanEvents(resourceSpan))
+		addRequestAttributes(resourceAttrs, resourceRI, opts)
+		sdk, hasSDK := getTelemetrySDK(resourceAttrs)
+		if opts.IncludeTelemetrySDK && hasSDK {
+			addTelemetrySDKAttributes(resourceAttrs, sdk)
+		}
+
+		var conflicts map[string]struct{}
+		if opts.ReportAttributeConflicts {
+			conflicts = map[string]struct{}{}
+		}
 
 		for _, scopeSpan := range resourceSpan.ScopeSpans {
-			scopeAttrs := getScopeAttributes(scopeSpan.Scope)
+			inheritedAttrs := mergeAttributes(resourceAttrs, getScopeAttributes(scopeSpan.Scope, opts))
+			shareInherited := canShareAttributes(inheritedAttrs, opts)
 
 			for _, span := range scopeSpan.GetSpans() {
-				traceID := BytesToTraceID(span.TraceId)
+				validTraceID, validSpanID := isValidTraceID(span.TraceId), isValidSpanID(span.SpanId)
+				if !validTraceID || !validSpanID {
+					invalidIDSpans++
+					if opts.DropInvalidIDs {
+						droppedSpans++
+						continue
+					}
+				}
+				// the span and its span events and links are appended straight to the
+				// resource's events, from here
+				first := len(events)
+				traceID := opts.traceID(span.TraceId)
+				if opts.shortensTraceID(span.TraceId) {
+					shortenedTraceIDs++
+				}
 				spanID := hex.EncodeToString(span.SpanId)
+				spanName := span.Name
+				if spanName == "" && opts.DefaultSpanName != "" {
+					spanName = opts.DefaultSpanName
+				}
+				if opts.MaxSpanNameLength > 0 && len(spanName) > opts.MaxSpanNameLength {
+					spanName = truncateString(spanName, opts.MaxSpanNameLength)
+					truncatedSpanNames++
+				}
+
+				// the subtraction would wrap around for spans that end before they start
+				invalidDuration := span.EndTimeUnixNano < span.StartTimeUnixNano
+				if invalidDuration && opts.DropInvalidDurations {
+					droppedSpans++
+					droppedInvalidDurations++
+					continue
+				}
+				var durationNanos uint64
+				if invalidDuration {
+					clampedInvalidDurations++
+				} else {
+					durationNanos = span.EndTimeUnixNano - span.StartTimeUnixNano
+				}
 
 				spanKind := getSpanKind(span.Kind)
 				statusCode, isError := getSpanStatusCode(span.Status)
+				if opts.SpanStatusErrorMapper != nil {
+					isError = opts.SpanStatusErrorMapper(span.Status)
+				}
 
-				eventAttrs := map[string]interface{}{
-					"trace.trace_id":   traceID,
-					"trace.span_id":    spanID,
-					"type":             spanKind,
-					"span.kind":        spanKind,
-					"name":             span.Name,
-					"duration_ms":      float64(span.EndTimeUnixNano-span.StartTimeUnixNano) / float64(time.Millisecond),
-					"status_code":      statusCode,
-					"span.num_links":   len(span.Links),
-					"span.num_events":  len(span.Events),
-					"meta.signal_type": "trace",
+				var spanFields *SpanFields
+				var eventAttrs map[string]interface{}
+				if opts.TypedSpanFields {
+					spanFields = &SpanFields{
+						TraceID:    traceID,
+						SpanID:     spanID,
+						Name:       spanName,
+						Kind:       spanKind,
+						DurationMs: float64(durationNanos) / float64(time.Millisecond),
+						NumLinks:   len(span.Links),
+						NumEvents:  len(span.Events),
+						kindFormat: opts.SpanKindFormat,
+					}
+					eventAttrs = make(map[string]interface{}, eventMapSize(spanFieldCount-typedSpanFieldCount, inheritedAttrs, shareInherited, span.Attributes, opts))
+				} else {
+					eventAttrs = make(map[string]interface{}, eventMapSize(spanFieldCount, inheritedAttrs, shareInherited, span.Attributes, opts))
+					eventAttrs["trace.trace_id"] = traceID
+					eventAttrs["trace.span_id"] = spanID
+					addSpanKindAttributes(eventAttrs, spanKind, opts.SpanKindFormat)
+					eventAttrs["name"] = spanName
+					eventAttrs["duration_ms"] = float64(durationNanos) / float64(time.Millisecond)
+					eventAttrs["span.num_links"] = len(span.Links)
+					eventAttrs["span.num_events"] = len(span.Events)
+					eventAttrs["meta.signal_type"] = "trace"
+				}
+				addOriginalTraceID(eventAttrs, span.TraceId, opts)
+				if opts.FlagInvalidIDs && !validTraceID {
+					eventAttrs["meta.invalid_trace_id"] = true
 				}
-				if span.ParentSpanId != nil {
-					eventAttrs["trace.parent_id"] = hex.EncodeToString(span.ParentSpanId)
+				if opts.FlagInvalidIDs && !validSpanID {
+					eventAttrs["meta.invalid_span_id"] = true
 				}
-				if isError {
-					eventAttrs["error"] = true
+				if invalidDuration {
+					eventAttrs["meta.invalid_duration"] = true
+				}
+				addStatusCodeAttributes(eventAttrs, statusCode, opts.StatusCodeFormat)
+				addSpanTimeAttributes(eventAttrs, span, opts.SpanTimeFormat)
+				if opts.IncludeDurationMicros {
+					eventAttrs["duration_us"] = int64(durationNanos / uint64(time.Microsecond))
+				}
+				if opts.IncludeDurationNanos {
+					eventAttrs["duration_ns"] = int64(durationNanos)
+				}
+				if spanFields != nil {
+					spanFields.ParentID = hex.EncodeToString(span.ParentSpanId)
+					spanFields.Error = isError
+				} else {
+					if span.ParentSpanId != nil {
+						eventAttrs["trace.parent_id"] = hex.EncodeToString(span.ParentSpanId)
+					}
+					if isError {
+						eventAttrs["error"] = true
+					}
 				}
 				if span.Status != nil && len(span.Status.Message) > 0 {
-					eventAttrs["status_message"] = span.Status.Message
+					// some SDKs copy whole stack traces in here, invalid bytes and all
+					statusMessage := strings.ToValidUTF8(span.Status.Message, "")
+					if opts.MaxStatusMessageLength > 0 && len(statusMessage) > opts.MaxStatusMessageLength {
+						statusMessage = truncateString(statusMessage, opts.MaxStatusMessageLength)
+						truncatedStatusMessages++
+					}
+					eventAttrs["status_message"] = statusMessage
 				}
 
-				// copy resource & scope attributes then span attributes
-				for k, v := range resourceAttrs {
-					eventAttrs[k] = v
+				// add resource & scope attributes then span attributes
+				inherited := inheritAttributes(eventAttrs, inheritedAttrs, shareInherited)
+				var spanValues []Attribute
+				if span.Attributes != nil {
+					droppedAttributes += addSpanAttributes(eventAttrs, opts.typedValues(&spanValues), span.Attributes, resourceAttrs, opts, conflicts)
 				}
-				for k, v := range scopeAttrs {
-					eventAttrs[k] = v
+				spanDataset, routeSpan := popDatasetOverride(eventAttrs, opts)
+				if !isError && opts.HTTPServerErrorsAsErrors &&
+					span.Status.GetCode() == trace.Status_STATUS_CODE_UNSET && isHTTPServerError(eventAttrs) {
+					isError = true
+					setSpanError(eventAttrs, spanFields)
 				}
-				if span.Attributes != nil {
-					addAttributesToMap(eventAttrs, span.Attributes)
+				if !isError && opts.PromoteExceptions && hasExceptionEvent(span) {
+					isError = true
+					setSpanError(eventAttrs, spanFields)
 				}
 
 				// get sample rate after resource and scope attributes have been added
-				sampleRate := getSampleRate(eventAttrs)
+				sampleRate, coerced := getSampleRate(eventAttrs, opts, ri.defaultSampleRate())
+				if coerced {
+					coercedSampleRates++
+				}
 
 				// Now we need to wrap the eventAttrs in an event so we can specify the timestamp
 				// which is the StartTime as a time.Time object
 				timestamp := time.Unix(0, int64(span.StartTimeUnixNano)).UTC()
+				if span.StartTimeUnixNano == 0 && opts.ReplaceZeroTimestamps {
+					timestamp = ri.now().UTC()
+					eventAttrs["meta.invalid_timestamp"] = true
+				}
+				addTimestampNanos(eventAttrs, timestamp, opts)
 				events = append(events, Event{
 					Attributes: eventAttrs,
+					Inherited:  inherited,
+					Values:     spanValues,
+					Span:       spanFields,
 					Timestamp:  timestamp,
 					SampleRate: sampleRate,
 				})
 
 				for _, sevent := range span.Events {
 					timestamp := time.Unix(0, int64(sevent.TimeUnixNano)).UTC()
-					attrs := map[string]interface{}{
-						"trace.trace_id":       traceID,
-						"trace.parent_id":      spanID,
-						"name":                 sevent.Name,
-						"parent_name":          span.Name,
-						"meta.annotation_type": "span_event",
-						"meta.signal_type":     "trace",
-					}
+					attrs := make(map[string]interface{}, eventMapSize(spanEventFieldCount, inheritedAttrs, shareInherited, sevent.Attributes, opts))
+					attrs["trace.trace_id"] = traceID
+					attrs["trace.parent_id"] = spanID
+					attrs["name"] = sevent.Name
+					attrs["parent_name"] = spanName
+					attrs["meta.annotation_type"] = "span_event"
+					attrs["meta.signal_type"] = "trace"
+					addOriginalTraceID(attrs, span.TraceId, opts)
 
-					// copy resource & scope attributes then span event attributes
-					for k, v := range resourceAttrs {
-						attrs[k] = v
-					}
-					for k, v := range scopeAttrs {
-						attrs[k] = v
-					}
+					// add resource & scope attributes then span event attributes
+					inherited := inheritAttributes(attrs, inheritedAttrs, shareInherited)
+					var values []Attribute
 
 					if sevent.Attributes != nil {
-						addAttributesToMap(attrs, sevent.Attributes)
+						droppedAttributes += addSpanAttributes(attrs, opts.typedValues(&values), sevent.Attributes, resourceAttrs, opts, conflicts)
+					}
+					popDatasetOverride(attrs, opts) // span events follow their span's dataset
+					if opts.PromoteExceptions && sevent.Name == "exception" {
+						promoteException(eventAttrs, attrs)
+					}
+					if opts.MarkExceptionEvents && sevent.Name == "exception" {
+						markExceptionEvent(attrs)
 					}
 					if isError {
 						attrs["error"] = true
 					}
+					addTimestampNanos(attrs, timestamp, opts)
 
 					events = append(events, Event{
 						Attributes: attrs,
+						Inherited:  inherited,
+						Values:     values,
 						Timestamp:  timestamp,
 						SampleRate: sampleRate,
 					})
 				}
 
 				for _, slink := range span.Links {
-					attrs := map[string]interface{}{
-						"trace.trace_id":       traceID,
-						"trace.parent_id":      spanID,
-						"trace.link.trace_id":  BytesToTraceID(slink.TraceId),
-						"trace.link.span_id":   hex.EncodeToString(slink.SpanId),
-						"parent_name":          span.Name,
-						"meta.annotation_type": "link",
-						"meta.signal_type":     "trace",
+					attrs := make(map[string]interface{}, eventMapSize(linkFieldCount, inheritedAttrs, shareInherited, slink.Attributes, opts))
+					attrs["trace.trace_id"] = traceID
+					attrs["trace.parent_id"] = spanID
+					attrs["trace.link.trace_id"] = opts.traceID(slink.TraceId)
+					attrs["trace.link.span_id"] = hex.EncodeToString(slink.SpanId)
+					attrs["parent_name"] = spanName
+					attrs["meta.annotation_type"] = "link"
+					attrs["meta.signal_type"] = "trace"
+					addOriginalTraceID(attrs, span.TraceId, opts)
+					// older SDKs leave flags unset, so a zero value tells us nothing
+					if slink.Flags != 0 {
+						attrs["trace.link.flags"] = int64(slink.Flags)
+						attrs["trace.link.sampled"] = slink.Flags&traceFlagSampled != 0
 					}
 
-					// copy resource & scope attributes then span link attributes
-					for k, v := range resourceAttrs {
-						attrs[k] = v
-					}
-					for k, v := range scopeAttrs {
-						attrs[k] = v
-					}
+					// add resource & scope attributes then span link attributes
+					inherited := inheritAttributes(attrs, inheritedAttrs, shareInherited)
+					var values []Attribute
 
 					if slink.Attributes != nil {
-						addAttributesToMap(attrs, slink.Attributes)
+						droppedAttributes += addSpanAttributes(attrs, opts.typedValues(&values), slink.Attributes, resourceAttrs, opts, conflicts)
 					}
+					popDatasetOverride(attrs, opts) // links follow their span's dataset
 					if isError {
 						attrs["error"] = true
 					}
+					addTimestampNanos(attrs, timestamp, opts)
 
 					events = append(events, Event{
 						Attributes: attrs,
+						Inherited:  inherited,
+						Values:     values,
 						Timestamp:  timestamp, // use timestamp from parent span
 						SampleRate: sampleRate,
 					})
 				}
+
+				if opts.Sampler != nil {
+					kept, n := sampleEvents(opts.Sampler, traceID, events[first:])
+					events = events[:first+len(kept)]
+					sampledOutEvents += n
+				}
+
+				translatedSpans++
+				if routeSpan && spanDataset != dataset {
+					// routed batches copy the events, so their slots can be reused
+					routed.add(spanDataset, proto.Size(span), events[first:])
+					events = events[:first]
+				}
 			}
 		}
+		if len(conflicts) > 0 {
+			warnings = append(warnings, fmt.Sprintf("span attributes conflicted with resource attributes in dataset %q: %s", dataset, sortedKeys(conflicts)))
+		}
 		batches = append(batches, Batch{
 			Dataset:   dataset,
-			SizeBytes: proto.Size(resourceSpan),
+			SizeBytes: proto.Size(resourceSpan) - routed.sizeBytes(),
 			Events:    events,
+			APIKey:    apiKey,
+			Team:      resourceRI.Team,
 		})
+		for i := range routed.batches {
+			routed.batches[i].APIKey = apiKey
+			routed.batches[i].Team = resourceRI.Team
+		}
+		batches = append(batches, routed.batches...)
+		if opts.IncludeTelemetrySDK && hasSDK {
+			if eventsBySDK == nil {
+				eventsBySDK = map[TelemetrySDK]int{}
+			}
+			eventsBySDK[sdk] += len(events) + routed.eventCount()
+		}
+	}
+	if clamped := clampEventTimestamps(batches, ri.now(), opts.TimestampTolerance); clamped > 0 {
+		warnings = append(warnings, fmt.Sprintf("clamped %d event timestamps outside the allowed window", clamped))
+	}
+	if clampedInvalidDurations > 0 {
+		warnings = append(warnings, fmt.Sprintf("clamped %d spans with end time before start time to zero duration", clampedInvalidDurations))
+	}
+	if droppedInvalidDurations > 0 {
+		warnings = append(warnings, fmt.Sprintf("dropped %d spans with end time before start time", droppedInvalidDurations))
+	}
+	if coercedSampleRates > 0 {
+		warnings = append(warnings, fmt.Sprintf("rounded %d non-integral sample rates", coercedSampleRates))
+	}
+	if invalidIDSpans > 0 {
+		if opts.DropInvalidIDs {
+			warnings = append(warnings, fmt.Sprintf("dropped %d spans with invalid trace or span IDs", invalidIDSpans))
+		} else if opts.FlagInvalidIDs {
+			warnings = append(warnings, fmt.Sprintf("flagged %d spans with invalid trace or span IDs", invalidIDSpans))
+		}
+	}
+	if truncatedSpanNames > 0 {
+		warnings = append(warnings, fmt.Sprintf("truncated %d span names to %d bytes", truncatedSpanNames, opts.MaxSpanNameLength))
+	}
+	if repairedStrings > 0 {
+		warnings = append(warnings, fmt.Sprintf("replaced invalid UTF-8 in %d strings", repairedStrings))
+	}
+	if truncatedStatusMessages > 0 {
+		warnings = append(warnings, fmt.Sprintf("truncated %d status messages to %d bytes", truncatedStatusMessages, opts.MaxStatusMessageLength))
+	}
+	if shortenedTraceIDs > 0 {
+		opts.logger().Debugf("shortened %d trace IDs with zero high bits to 64 bits", shortenedTraceIDs)
+	}
+	if droppedAttributes > 0 {
+		opts.logger().Debugf("dropped %d attributes without a key or a usable value", droppedAttributes)
+	}
+	sanitizeBatchDatasets(batches, opts)
+	warnings = append(warnings, foldOverflowColumns(batches, opts)...)
+	oversizedEvents, warning := applyOversizedEventPolicy(batches, opts)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	applyMetaFieldOptions(batches, opts)
+	*result = TranslateOTLPRequestResult{
+		RequestSize:          proto.Size(request),
+		Batches:              batches,
+		EventsByTelemetrySDK: eventsBySDK,
+		DroppedSpans:         droppedSpans,
+		InvalidIDSpans:       invalidIDSpans,
+		OversizedEvents:      oversizedEvents,
+		SampledOutEvents:     sampledOutEvents,
+		Team:                 ri.Team,
+		Warnings:             warnings,
+	}
+	if opts.PoolResults {
+		result.events = arena.buf
+	}
+	reportMetrics(opts.Hooks, result, translatedSpans, droppedAttributes)
+	return result, nil
+}
+
+// httpStatusCodeKeys are the old and current semantic convention attributes for an HTTP response status
+var httpStatusCodeKeys = []string{"http.status_code", "http.response.status_code"}
+
+// isHTTPServerError reports whether the span's HTTP status code is 5xx
+func isHTTPServerError(attrs map[string]interface{}) bool {
+	for _, key := range httpStatusCodeKeys {
+		var code int64
+		switch v := attrs[key].(type) {
+		case int64:
+			code = v
+		case float64:
+			code = int64(v)
+		case string:
+			code, _ = strconv.ParseInt(v, 10, 64)
+		}
+		if code >= 500 && code < 600 {
+			return true
+		}
 	}
-	return &TranslateOTLPRequestResult{
-		RequestSize: proto.Size(request),
-		Batches:     batches,
-	}, nil
+	return false
+}
+
+// exceptionAttributeKeys are the semantic convention attributes of an exception span event
+var exceptionAttributeKeys = []string{"exception.type", "exception.message", "exception.stacktrace"}
+
+// promoteException copies the exception details from an exception span event onto
+// its parent span. Values already on the span, e.g. from an earlier exception
+// event, are not overwritten. The span is marked as an error up front, see
+// hasExceptionEvent, so its other span events and links are marked too.
+func promoteException(spanAttrs map[string]interface{}, exceptionAttrs map[string]interface{}) {
+	for _, key := range exceptionAttributeKeys {
+		if val, ok := exceptionAttrs[key]; ok {
+			if _, exists := spanAttrs[key]; !exists {
+				spanAttrs[key] = val
+			}
+		}
+	}
+}
+
+// hasExceptionEvent reports whether span has an "exception" span event
+func hasExceptionEvent(span *trace.Span) bool {
+	for _, sevent := range span.Events {
+		if sevent.Name == "exception" {
+			return true
+		}
+	}
+	return false
+}
+
+// setSpanError marks a span as an error, in its typed fields if it has them or
+// its attributes otherwise
+func setSpanError(eventAttrs map[string]interface{}, spanFields *SpanFields) {
+	if spanFields != nil {
+		spanFields.Error = true
+		delete(eventAttrs, "error")
+	} else {
+		eventAttrs["error"] = true
+	}
+}
+
+// markExceptionEvent sets error=true on an exception span event, with its exception
+// message, or failing that its type, as error.message. An error.message the event
+// already has is kept.
+func markExceptionEvent(attrs map[string]interface{}) {
+	attrs["error"] = true
+	if _, exists := attrs["error.message"]; exists {
+		return
+	}
+	if msg, ok := attrs["exception.message"]; ok {
+		attrs["error.message"] = msg
+	} else if typ, ok := attrs["exception.type"]; ok {
+		attrs["error.message"] = typ
+	}
+}
+
+// countSpanEvents returns how many events a resource's spans translate to at
+// most: one per span, span event and link
+func countSpanEvents(resourceSpan *trace.ResourceSpans) int {
+	n := 0
+	for _, scopeSpan := range resourceSpan.ScopeSpans {
+		for _, span := range scopeSpan.Spans {
+			n += 1 + len(span.Events) + len(span.Links)
+		}
+	}
+	return n
+}
+
+func countSpans(resourceSpan *trace.ResourceSpans) int {
+	n := 0
+	for _, scopeSpan := range resourceSpan.ScopeSpans {
+		n += len(scopeSpan.Spans)
+	}
+	return n
 }
 
 func getSpanKind(kind trace.Span_SpanKind) string {
@@ -222,6 +622,63 @@ func BytesToTraceID(traceID []byte) string {
 	return string(encoded)
 }
 
+// addSpanAttributes adds span, span event or link attributes to attrs, which
+// already holds the resource attributes. Keys also set on the resource are
+// recorded in conflicts, if not nil, and resolved according to opts.AttributePrecedence.
+// If values isn't nil, attributes that translation doesn't read again are added to it.
+func addSpanAttributes(attrs map[string]interface{}, values *[]Attribute, attributes []*common.KeyValue, resourceAttrs map[string]interface{}, opts TranslateOptions, conflicts map[string]struct{}) (dropped int) {
+	if attributes == nil {
+		return 0
+	}
+	dropped = addTypedAttributes(attrs, values, attributes, opts)
+	if conflicts == nil && opts.AttributePrecedence == SpanAttributesFirst {
+		return dropped
+	}
+	for _, attr := range attributes {
+		v, ok := resourceAttrs[attr.Key]
+		if !ok {
+			continue
+		}
+		if conflicts != nil {
+			conflicts[attr.Key] = struct{}{}
+		}
+		if opts.AttributePrecedence == ResourceAttributesFirst {
+			attrs[attr.Key] = v
+			deleteTypedValue(values, attr.Key)
+		}
+	}
+	return dropped
+}
+
+// sortedKeys returns the keys of set as a sorted, comma-separated list
+func sortedKeys(set map[string]struct{}) string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// isValidTraceID reports whether traceID is a non-zero 64 or 128-bit ID
+func isValidTraceID(traceID []byte) bool {
+	return (len(traceID) == traceIDLongLength || len(traceID) == traceIDShortLength) && !isAllZero(traceID)
+}
+
+// isValidSpanID reports whether spanID is a non-zero 64-bit ID
+func isValidSpanID(spanID []byte) bool {
+	return len(spanID) == 8 && !isAllZero(spanID)
+}
+
+func isAllZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func shouldTrimTraceId(traceID []byte) bool {
 	for i := 0; i < 8; i++ {
 		if traceID[i] != 0 {
@@ -241,16 +698,75 @@ func getSpanStatusCode(status *trace.Status) (int, bool) {
 	if status == nil {
 		return int(trace.Status_STATUS_CODE_UNSET), false
 	}
+	if status.Code == trace.Status_STATUS_CODE_UNSET && legacyStatusIsError(status) {
+		return int(trace.Status_STATUS_CODE_ERROR), true
+	}
 	return int(status.Code), status.Code == trace.Status_STATUS_CODE_ERROR
 }
 
-func getSampleRate(attrs map[string]interface{}) int32 {
-	sampleRateKey := getSampleRateKey(attrs)
+// getSpanStatusText returns the human readable name of a status code
+func getSpanStatusText(statusCode int) string {
+	switch trace.Status_StatusCode(statusCode) {
+	case trace.Status_STATUS_CODE_OK:
+		return "OK"
+	case trace.Status_STATUS_CODE_ERROR:
+		return "ERROR"
+	default:
+		return "UNSET"
+	}
+}
+
+func addStatusCodeAttributes(attrs map[string]interface{}, statusCode int, format StatusCodeFormat) {
+	switch format {
+	case StatusCodeAsText:
+		attrs["status_code"] = getSpanStatusText(statusCode)
+	case StatusCodeAsNumberAndText:
+		attrs["status_code"] = statusCode
+		attrs["status_code_text"] = getSpanStatusText(statusCode)
+	default:
+		attrs["status_code"] = statusCode
+	}
+}
+
+func addSpanKindAttributes(attrs map[string]interface{}, kind string, format SpanKindFormat) {
+	if format != SpanKindAsSpanKindOnly {
+		attrs["type"] = kind
+	}
+	if format != SpanKindAsTypeOnly {
+		attrs["span.kind"] = kind
+	}
+}
+
+func addSpanTimeAttributes(attrs map[string]interface{}, span *trace.Span, format SpanTimeFormat) {
+	switch format {
+	case SpanTimesAsRFC3339Nano:
+		attrs["start_time"] = time.Unix(0, int64(span.StartTimeUnixNano)).UTC().Format(time.RFC3339Nano)
+		attrs["end_time"] = time.Unix(0, int64(span.EndTimeUnixNano)).UTC().Format(time.RFC3339Nano)
+	case SpanTimesAsUnixNano:
+		attrs["start_time"] = int64(span.StartTimeUnixNano)
+		attrs["end_time"] = int64(span.EndTimeUnixNano)
+	}
+}
+
+// getSampleRate returns the event's sample rate and removes the attribute it came from,
+// or returns fallback if there's no sample rate attribute. The bool reports whether
+// a non-integral rate was rounded to the nearest integer.
+func getSampleRate(attrs map[string]interface{}, opts TranslateOptions, fallback int32) (int32, bool) {
+	if _, ok := attrs[sampleRateOverrideKey]; ok && !opts.DisableControlAttributes {
+		return popSampleRate(attrs, sampleRateOverrideKey)
+	}
+	sampleRateKey := getSampleRateKey(attrs, opts.sampleRateKeys())
 	if sampleRateKey == "" {
-		return defaultSampleRate
+		return fallback, false
 	}
+	return popSampleRate(attrs, sampleRateKey)
+}
 
+// popSampleRate parses and removes the sample rate attribute sampleRateKey, which
+// must be present in attrs
+func popSampleRate(attrs map[string]interface{}, sampleRateKey string) (int32, bool) {
 	sampleRate := defaultSampleRate
+	coerced := false
 	sampleRateVal := attrs[sampleRateKey]
 	switch v := sampleRateVal.(type) {
 	case string:
@@ -260,6 +776,8 @@ func getSampleRate(attrs map[string]interface{}) int32 {
 			} else {
 				sampleRate = math.MaxInt32
 			}
+		} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+			sampleRate, coerced = sampleRateFromFloat(f)
 		}
 	case int32:
 		sampleRate = v
@@ -275,6 +793,28 @@ func getSampleRate(attrs map[string]interface{}) int32 {
 		} else {
 			sampleRate = math.MaxInt32
 		}
+	case uint32:
+		if v < math.MaxInt32 {
+			sampleRate = int32(v)
+		} else {
+			sampleRate = math.MaxInt32
+		}
+	case uint:
+		if v < math.MaxInt32 {
+			sampleRate = int32(v)
+		} else {
+			sampleRate = math.MaxInt32
+		}
+	case uint64:
+		if v < math.MaxInt32 {
+			sampleRate = int32(v)
+		} else {
+			sampleRate = math.MaxInt32
+		}
+	case float32:
+		sampleRate, coerced = sampleRateFromFloat(float64(v))
+	case float64:
+		sampleRate, coerced = sampleRateFromFloat(v)
 	}
 	// To make sampleRate consistent between Otel and Honeycomb, we coerce all 0 values to 1 here
 	// A value of 1 means the span was not sampled
@@ -283,15 +823,30 @@ func getSampleRate(attrs map[string]interface{}) int32 {
 		sampleRate = defaultSampleRate
 	}
 	delete(attrs, sampleRateKey) // remove attr
-	return sampleRate
+	return sampleRate, coerced
 }
 
-func getSampleRateKey(attrs map[string]interface{}) string {
-	if _, ok := attrs["sampleRate"]; ok {
-		return "sampleRate"
+// sampleRateFromFloat rounds f to the nearest integer (halves round away from zero),
+// reporting whether f had a fractional part. Negative and NaN values are invalid
+// and give the default sample rate.
+func sampleRateFromFloat(f float64) (int32, bool) {
+	if math.IsNaN(f) || f < 0 {
+		return defaultSampleRate, false
+	}
+	rounded := math.Round(f)
+	coerced := rounded != f
+	if rounded >= math.MaxInt32 {
+		return math.MaxInt32, coerced
 	}
-	if _, ok := attrs["SampleRate"]; ok {
-		return "SampleRate"
+	return int32(rounded), coerced
+}
+
+// getSampleRateKey returns the first of keys present in attrs, or "" if none are
+func getSampleRateKey(attrs map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if _, ok := attrs[key]; ok {
+			return key
+		}
 	}
 	return ""
 }
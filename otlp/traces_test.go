@@ -2,14 +2,17 @@ package otlp
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"io"
 	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/honeycombio/husky"
 	"github.com/honeycombio/husky/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,6 +20,8 @@ import (
 	common "go.opentelemetry.io/proto/otlp/common/v1"
 	resource "go.opentelemetry.io/proto/otlp/resource/v1"
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -65,8 +70,8 @@ func TestTranslateGrpcTraceRequest(t *testing.T) {
 					Name:              "test_span",
 					Kind:              trace.Span_SPAN_KIND_CLIENT,
 					Status:            &trace.Status{Code: trace.Status_STATUS_CODE_OK},
-					StartTimeUnixNano: uint64(startTimestamp.Nanosecond()),
-					EndTimeUnixNano:   uint64(endTimestamp.Nanosecond()),
+					StartTimeUnixNano: uint64(startTimestamp.UnixNano()),
+					EndTimeUnixNano:   uint64(endTimestamp.UnixNano()),
 					Attributes: []*common.KeyValue{
 						{
 							Key: "span_attr",
@@ -151,7 +156,7 @@ func TestTranslateGrpcTraceRequest(t *testing.T) {
 			assert.Equal(t, "client", ev.Attributes["type"])
 			assert.Equal(t, "client", ev.Attributes["span.kind"])
 			assert.Equal(t, "test_span", ev.Attributes["name"])
-			assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["duration_ms"])
+			assert.Equal(t, float64(endTimestamp.Sub(startTimestamp))/float64(time.Millisecond), ev.Attributes["duration_ms"])
 			assert.Equal(t, int(trace.Status_STATUS_CODE_OK), ev.Attributes["status_code"])
 			assert.Equal(t, "span_attr_val", ev.Attributes["span_attr"])
 			assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
@@ -353,8 +358,8 @@ func TestTranslateHttpTraceRequest(t *testing.T) {
 					Name:              "test_span",
 					Kind:              trace.Span_SPAN_KIND_CLIENT,
 					Status:            &trace.Status{Code: trace.Status_STATUS_CODE_OK},
-					StartTimeUnixNano: uint64(startTimestamp.Nanosecond()),
-					EndTimeUnixNano:   uint64(endTimestamp.Nanosecond()),
+					StartTimeUnixNano: uint64(startTimestamp.UnixNano()),
+					EndTimeUnixNano:   uint64(endTimestamp.UnixNano()),
 					Attributes: []*common.KeyValue{{
 						Key: "span_attr",
 						Value: &common.AnyValue{
@@ -440,7 +445,7 @@ func TestTranslateHttpTraceRequest(t *testing.T) {
 							assert.Equal(t, "client", ev.Attributes["span.kind"])
 							assert.Equal(t, "test_span", ev.Attributes["name"])
 							assert.Equal(t, "my-service", ev.Attributes["service.name"])
-							assert.Equal(t, float64(endTimestamp.Nanosecond()-startTimestamp.Nanosecond())/float64(time.Millisecond), ev.Attributes["duration_ms"])
+							assert.Equal(t, float64(endTimestamp.Sub(startTimestamp))/float64(time.Millisecond), ev.Attributes["duration_ms"])
 							assert.Equal(t, int(trace.Status_STATUS_CODE_OK), ev.Attributes["status_code"])
 							assert.Equal(t, "span_attr_val", ev.Attributes["span_attr"])
 							assert.Equal(t, "resource_attr_val", ev.Attributes["resource_attr"])
@@ -575,7 +580,7 @@ func TestNoSampleRateKeyReturnOne(t *testing.T) {
 	attrs := map[string]interface{}{
 		"not_a_sample_rate": 10,
 	}
-	sampleRate := getSampleRate(attrs)
+	sampleRate, _ := getSampleRate(attrs, TranslateOptions{}, defaultSampleRate)
 	assert.Equal(t, int32(1), sampleRate)
 }
 
@@ -584,22 +589,47 @@ func TestCanDetectSampleRateCapitalizations(t *testing.T) {
 		attrs := map[string]interface{}{
 			"sampleRate": 10,
 		}
-		key := getSampleRateKey(attrs)
+		key := getSampleRateKey(attrs, defaultSampleRateKeys)
 		assert.Equal(t, "sampleRate", key)
 	})
 	t.Run("uppercase", func(t *testing.T) {
 		attrs := map[string]interface{}{
 			"SampleRate": 10,
 		}
-		key := getSampleRateKey(attrs)
+		key := getSampleRateKey(attrs, defaultSampleRateKeys)
 		assert.Equal(t, "SampleRate", key)
 	})
 }
 
+func TestConfiguredSampleRateKeys(t *testing.T) {
+	opts := TranslateOptions{SampleRateKeys: []string{"hny.sample_rate", "sample.rate", "sampleRate"}}
+
+	attrs := map[string]interface{}{
+		"sample.rate":     int64(20),
+		"hny.sample_rate": int64(10),
+		"sampleRate":      int64(5),
+	}
+	sampleRate, _ := getSampleRate(attrs, opts, defaultSampleRate)
+	assert.Equal(t, int32(10), sampleRate)
+	assert.NotContains(t, attrs, "hny.sample_rate")
+	assert.Contains(t, attrs, "sample.rate")
+
+	attrs = map[string]interface{}{"sample.rate": int64(20)}
+	sampleRate, _ = getSampleRate(attrs, opts, defaultSampleRate)
+	assert.Equal(t, int32(20), sampleRate)
+
+	// the defaults are replaced, not extended
+	attrs = map[string]interface{}{"SampleRate": int64(20)}
+	sampleRate, _ = getSampleRate(attrs, opts, defaultSampleRate)
+	assert.Equal(t, int32(1), sampleRate)
+	assert.Contains(t, attrs, "SampleRate")
+}
+
 func TestGetSampleRateConversions(t *testing.T) {
 	testCases := []struct {
 		sampleRate interface{}
 		expected   int32
+		coerced    bool
 	}{
 		{sampleRate: nil, expected: 1},
 		{sampleRate: "0", expected: 1},
@@ -625,17 +655,75 @@ func TestGetSampleRateConversions(t *testing.T) {
 		{sampleRate: int64(100), expected: 100},
 		{sampleRate: int64(math.MaxInt32), expected: math.MaxInt32},
 		{sampleRate: int64(math.MaxInt64), expected: math.MaxInt32},
+
+		{sampleRate: uint32(100), expected: 100},
+		{sampleRate: uint64(100), expected: 100},
+		{sampleRate: uint64(math.MaxUint64), expected: math.MaxInt32},
+
+		{sampleRate: float64(0), expected: 1},
+		{sampleRate: float64(10), expected: 10},
+		{sampleRate: float64(10.4), expected: 10, coerced: true},
+		{sampleRate: float64(10.5), expected: 11, coerced: true},
+		{sampleRate: float64(0.3), expected: 1, coerced: true},
+		{sampleRate: float64(-10), expected: 1},
+		{sampleRate: math.NaN(), expected: 1},
+		{sampleRate: math.Inf(1), expected: math.MaxInt32},
+		{sampleRate: float64(math.MaxInt64), expected: math.MaxInt32},
+		{sampleRate: float32(2.5), expected: 3, coerced: true},
+		{sampleRate: "10.0", expected: 10},
+		{sampleRate: "10.7", expected: 11, coerced: true},
 	}
 
 	for _, tc := range testCases {
 		attrs := map[string]interface{}{
 			"sampleRate": tc.sampleRate,
 		}
-		assert.Equal(t, tc.expected, getSampleRate(attrs))
+		sampleRate, coerced := getSampleRate(attrs, TranslateOptions{}, defaultSampleRate)
+		assert.Equal(t, tc.expected, sampleRate, "sample rate %v", tc.sampleRate)
+		assert.Equal(t, tc.coerced, coerced, "sample rate %v", tc.sampleRate)
 		assert.Equal(t, 0, len(attrs))
 	}
 }
 
+func TestRequestSampleRateIsUsedAsDefault(t *testing.T) {
+	req := buildTestTraceRequest(nil,
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Events: []*trace.Span_Event{{Name: "span_event"}}},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Attributes: []*common.KeyValue{intAttr("sampleRate", 5)}},
+	)
+	ri := testRequestInfo()
+	ri.SampleRate = 20
+
+	result, err := TranslateTraceRequest(req, ri)
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 3, len(events))
+	assert.Equal(t, int32(20), events[0].SampleRate)
+	assert.Equal(t, int32(20), events[1].SampleRate)
+	assert.Equal(t, int32(5), events[2].SampleRate)
+}
+
+func TestNonIntegralSampleRateIsReportedInWarnings(t *testing.T) {
+	spanWithRate := func(rate float64) *trace.Span {
+		return &trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Attributes: []*common.KeyValue{{
+				Key:   "sampleRate",
+				Value: &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: rate}},
+			}},
+		}
+	}
+	req := buildTestTraceRequest(nil, spanWithRate(10.0), spanWithRate(2.5), spanWithRate(7.2))
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, int32(10), events[0].SampleRate)
+	assert.Equal(t, int32(3), events[1].SampleRate)
+	assert.Equal(t, int32(7), events[2].SampleRate)
+	assert.Equal(t, []string{"rounded 2 non-integral sample rates"}, result.Warnings)
+}
+
 func TestDefaultServiceNameApplied(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -946,3 +1034,1115 @@ func TestBadTraceRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestUserAgentIsAddedWhenEnabled(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Name:    "test_span",
+		Events:  []*trace.Span_Event{{Name: "span_event"}},
+	})
+	ri := testRequestInfo()
+	ri.UserAgent = "OTel-OTLP-Exporter-Go/1.11.1"
+
+	result, err := TranslateTraceRequest(req, ri)
+	require.NoError(t, err)
+	for _, ev := range result.Batches[0].Events {
+		assert.NotContains(t, ev.Attributes, "meta.otlp.user_agent")
+	}
+
+	result, err = TranslateTraceRequestWithOptions(req, ri, TranslateOptions{IncludeUserAgent: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches[0].Events))
+	for _, ev := range result.Batches[0].Events {
+		assert.Equal(t, "OTel-OTLP-Exporter-Go/1.11.1", ev.Attributes["meta.otlp.user_agent"])
+	}
+}
+
+func TestTelemetrySDKMetaFields(t *testing.T) {
+	span := func() *trace.Span {
+		return &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Name: "test_span"}
+	}
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{
+			{
+				Resource: &resource.Resource{Attributes: []*common.KeyValue{
+					stringAttr("telemetry.sdk.name", " OpenTelemetry"),
+					stringAttr("telemetry.sdk.language", "Go"),
+					stringAttr("telemetry.sdk.version", "v1.11.1"),
+				}},
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span(), span()}}},
+			},
+			{
+				Resource: &resource.Resource{Attributes: []*common.KeyValue{
+					stringAttr("telemetry.sdk.name", "opentelemetry"),
+					stringAttr("telemetry.sdk.language", "go"),
+					stringAttr("telemetry.sdk.version", "1.11.1"),
+				}},
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span()}}},
+			},
+			{
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span()}}},
+			},
+		},
+	}
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.Nil(t, result.EventsByTelemetrySDK)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "meta.telemetry_sdk.name")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeTelemetrySDK: true})
+	require.NoError(t, err)
+	ev := result.Batches[0].Events[0]
+	assert.Equal(t, "opentelemetry", ev.Attributes["meta.telemetry_sdk.name"])
+	assert.Equal(t, "go", ev.Attributes["meta.telemetry_sdk.language"])
+	assert.Equal(t, "1.11.1", ev.Attributes["meta.telemetry_sdk.version"])
+	assert.Equal(t, " OpenTelemetry", ev.Attributes["telemetry.sdk.name"])
+	assert.NotContains(t, result.Batches[2].Events[0].Attributes, "meta.telemetry_sdk.name")
+	assert.Equal(t, map[TelemetrySDK]int{
+		{Name: "opentelemetry", Language: "go", Version: "1.11.1"}: 3,
+	}, result.EventsByTelemetrySDK)
+}
+
+func TestHuskyVersionIsAddedWhenEnabled(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "meta.husky.version")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeHuskyVersion: true})
+	require.NoError(t, err)
+	assert.Equal(t, husky.Version, result.Batches[0].Events[0].Attributes["meta.husky.version"])
+}
+
+func TestDatasetResolverOverridesDefaultRouting(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "my-service"), stringAttr("tenant", "acme")},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+	)
+	resolver := DatasetResolverFunc(func(ri RequestInfo, resourceAttrs map[string]interface{}) string {
+		return ri.ProxyToken + "-" + resourceAttrs["tenant"].(string)
+	})
+	ri := testRequestInfo()
+	ri.ProxyToken = "proxy"
+
+	result, err := TranslateTraceRequestWithOptions(req, ri, TranslateOptions{DatasetResolver: resolver})
+	require.NoError(t, err)
+	assert.Equal(t, "proxy-acme", result.Batches[0].Dataset)
+}
+
+func TestServiceNamespaceIsIncludedInDataset(t *testing.T) {
+	testCases := []struct {
+		name            string
+		resourceAttrs   []*common.KeyValue
+		separator       string
+		expectedDataset string
+	}{
+		{
+			name:            "disabled",
+			resourceAttrs:   []*common.KeyValue{stringAttr("service.name", "checkout"), stringAttr("service.namespace", "shop")},
+			expectedDataset: "checkout",
+		},
+		{
+			name:            "slash separator",
+			resourceAttrs:   []*common.KeyValue{stringAttr("service.name", "checkout"), stringAttr("service.namespace", " shop ")},
+			separator:       "/",
+			expectedDataset: "shop/checkout",
+		},
+		{
+			name:            "dot separator",
+			resourceAttrs:   []*common.KeyValue{stringAttr("service.name", "checkout"), stringAttr("service.namespace", "shop")},
+			separator:       ".",
+			expectedDataset: "shop.checkout",
+		},
+		{
+			name:            "no namespace",
+			resourceAttrs:   []*common.KeyValue{stringAttr("service.name", "checkout")},
+			separator:       "/",
+			expectedDataset: "checkout",
+		},
+		{
+			name:            "unknown service ignores namespace",
+			resourceAttrs:   []*common.KeyValue{stringAttr("service.namespace", "shop")},
+			separator:       "/",
+			expectedDataset: "unknown_service",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := buildTestTraceRequest(tc.resourceAttrs, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+			result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{ServiceNamespaceSeparator: tc.separator})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedDataset, result.Batches[0].Dataset)
+		})
+	}
+}
+
+func TestDatasetOverrideAttributes(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "my-service")},
+		&trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Name:    "default_span",
+		},
+		&trace.Span{
+			TraceId:    test.RandomBytes(16),
+			SpanId:     test.RandomBytes(8),
+			Name:       "audit_span",
+			Attributes: []*common.KeyValue{stringAttr("honeycomb.dataset", "audit")},
+			Events:     []*trace.Span_Event{{Name: "span_event"}},
+		},
+		&trace.Span{
+			TraceId:    test.RandomBytes(16),
+			SpanId:     test.RandomBytes(8),
+			Name:       "table_span",
+			Attributes: []*common.KeyValue{stringAttr("honeycomb.table", "audit")},
+		},
+	)
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+
+	assert.Equal(t, "my-service", result.Batches[0].Dataset)
+	require.Equal(t, 1, len(result.Batches[0].Events))
+	assert.Equal(t, "default_span", result.Batches[0].Events[0].Attributes["name"])
+
+	audit := result.Batches[1]
+	assert.Equal(t, "audit", audit.Dataset)
+	require.Equal(t, 3, len(audit.Events))
+	assert.Equal(t, "audit_span", audit.Events[0].Attributes["name"])
+	assert.Equal(t, "span_event", audit.Events[1].Attributes["name"])
+	assert.Equal(t, "table_span", audit.Events[2].Attributes["name"])
+	for _, ev := range audit.Events {
+		assert.NotContains(t, ev.Attributes, "honeycomb.dataset")
+		assert.NotContains(t, ev.Attributes, "honeycomb.table")
+	}
+	assert.Equal(t, proto.Size(req.ResourceSpans[0]), result.Batches[0].SizeBytes+audit.SizeBytes)
+}
+
+func TestResourceDatasetOverrideAttribute(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "my-service"), stringAttr("honeycomb.dataset", " resource-dataset ")},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+	)
+
+	// the override applies to classic keys as well
+	ri := RequestInfo{ApiKey: "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", Dataset: "legacy-dataset", ContentType: "application/protobuf"}
+	result, err := TranslateTraceRequest(req, ri)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "resource-dataset", result.Batches[0].Dataset)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "honeycomb.dataset")
+	assert.Equal(t, "my-service", result.Batches[0].Events[0].Attributes["service.name"])
+}
+
+func TestResourceAPIKeyOverrideAttribute(t *testing.T) {
+	span := func(attrs ...*common.KeyValue) *trace.Span {
+		return &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Attributes: attrs}
+	}
+	req := &collectortrace.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			stringAttr("service.name", "tenant-service"),
+			stringAttr("honeycomb.api_key", " tenant-key "),
+		}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span(), span(stringAttr("honeycomb.dataset", "audit"))}}},
+	}, {
+		Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span()}}},
+	}, {
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			stringAttr("service.name", "intruder"),
+			stringAttr("honeycomb.api_key", "stolen-key"),
+		}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span()}}},
+	}}}
+	headerTeam := &Team{Slug: "gateway"}
+	ri := testRequestInfo()
+	ri.Team = headerTeam
+	var resolved []string
+	resolver := TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+		resolved = append(resolved, apiKey)
+		if apiKey == "tenant-key" {
+			return Team{Slug: "tenant"}, nil
+		}
+		return Team{}, ErrInvalidAPIKey
+	})
+
+	// overrides are off by default
+	result, err := TranslateTraceRequest(req, ri)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(result.Batches))
+	assert.Equal(t, "", result.Batches[0].APIKey)
+	assert.Equal(t, " tenant-key ", result.Batches[0].Events[0].Attributes["honeycomb.api_key"])
+
+	// without a resolver they're applied unverified, with no known team
+	result, err = TranslateTraceRequestWithOptions(req, ri, TranslateOptions{APIKeyAttribute: "honeycomb.api_key"})
+	require.NoError(t, err)
+	require.Equal(t, 4, len(result.Batches))
+	assert.Equal(t, "tenant-key", result.Batches[0].APIKey)
+	assert.Nil(t, result.Batches[0].Team)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "honeycomb.api_key")
+	assert.Equal(t, "my-service", result.Batches[2].Dataset)
+	assert.Equal(t, "", result.Batches[2].APIKey)
+	assert.Equal(t, headerTeam, result.Batches[2].Team)
+	assert.Equal(t, "stolen-key", result.Batches[3].APIKey)
+
+	opts := TranslateOptions{APIKeyAttribute: "honeycomb.api_key", APIKeyResolver: resolver}
+	result, err = TranslateTraceRequestWithOptions(req, ri, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant-key", "stolen-key"}, resolved)
+	require.Equal(t, 3, len(result.Batches))
+	assert.Equal(t, "tenant-service", result.Batches[0].Dataset)
+	assert.Equal(t, "tenant-key", result.Batches[0].APIKey)
+	assert.Equal(t, &Team{Slug: "tenant"}, result.Batches[0].Team)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "honeycomb.api_key")
+	assert.Equal(t, "audit", result.Batches[1].Dataset)
+	assert.Equal(t, "tenant-key", result.Batches[1].APIKey)
+	assert.Equal(t, &Team{Slug: "tenant"}, result.Batches[1].Team)
+	assert.Equal(t, "my-service", result.Batches[2].Dataset)
+	assert.Equal(t, "", result.Batches[2].APIKey)
+	assert.Equal(t, headerTeam, result.Batches[2].Team)
+	assert.Equal(t, headerTeam, result.Team)
+	assert.Equal(t, 1, result.DroppedSpans)
+	assert.Equal(t, []string{"dropped 1 spans with a rejected API key override"}, result.Warnings)
+
+	// failing to check a key fails the request so it can be retried
+	opts.APIKeyResolver = TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+		return Team{}, NewUnavailableError(time.Second)
+	})
+	_, err = TranslateTraceRequestWithOptions(req, ri, opts)
+	var retryable RetryableError
+	assert.ErrorAs(t, err, &retryable)
+
+	// keys are checked with the translation's context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts.Context = ctx
+	opts.APIKeyResolver = TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+		return Team{}, ctx.Err()
+	})
+	_, err = TranslateTraceRequestWithOptions(req, ri, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// a classic key override names its dataset from the request
+	req = buildTestTraceRequest([]*common.KeyValue{
+		stringAttr("service.name", "my-service"),
+		stringAttr("tenant.key", "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"),
+	}, span())
+	ri = testRequestInfo()
+	ri.Dataset = "legacy-dataset"
+	result, err = TranslateTraceRequestWithOptions(req, ri, TranslateOptions{
+		APIKeyAttribute: "tenant.key",
+		APIKeyResolver: TeamResolverFunc(func(ctx context.Context, apiKey string) (Team, error) {
+			return Team{Slug: "classic"}, nil
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-dataset", result.Batches[0].Dataset)
+	assert.Equal(t, "a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1", result.Batches[0].APIKey)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "tenant.key")
+}
+
+func TestUnknownServiceDatasetOptions(t *testing.T) {
+	req := &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{
+			{
+				Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "unknown_service:go")}},
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{
+					{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+					{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+				}}},
+			},
+			{
+				Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "my-service")}},
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}}}},
+			},
+		},
+	}
+
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{UnknownServiceDataset: "quarantine"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Batches))
+	assert.Equal(t, "quarantine", result.Batches[0].Dataset)
+	assert.Equal(t, "my-service", result.Batches[1].Dataset)
+	assert.Equal(t, 0, result.DroppedSpans)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{RejectUnknownService: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches))
+	assert.Equal(t, "my-service", result.Batches[0].Dataset)
+	assert.Equal(t, 2, result.DroppedSpans)
+	assert.Equal(t, []string{"dropped 2 spans without a dataset"}, result.Warnings)
+}
+
+func TestUnknownServiceProcessIsPreservedWhenEnabled(t *testing.T) {
+	testCases := []struct {
+		serviceName     string
+		expectedDataset string
+	}{
+		{serviceName: "unknown_service:python", expectedDataset: "unknown_service:python"},
+		{serviceName: "unknown_service:java ", expectedDataset: "unknown_service:java"},
+		{serviceName: "unknown_service:", expectedDataset: "unknown_service"},
+		{serviceName: "unknown_service", expectedDataset: "unknown_service"},
+		{serviceName: "unknown_servicego", expectedDataset: "unknown_service"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.serviceName, func(t *testing.T) {
+			req := buildTestTraceRequest(
+				[]*common.KeyValue{stringAttr("service.name", tc.serviceName)},
+				&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+			)
+			result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{PreserveUnknownServiceProcess: true})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedDataset, result.Batches[0].Dataset)
+		})
+	}
+}
+
+func TestDatasetNamesAreSanitizedWhenEnabled(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{stringAttr("service.name", "checkout"), stringAttr("service.namespace", "shop")},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+	)
+	opts := TranslateOptions{ServiceNamespaceSeparator: "/"}
+
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "shop/checkout", result.Batches[0].Dataset)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "meta.original_dataset")
+
+	opts.SanitizeDatasetNames = true
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "shop-checkout", result.Batches[0].Dataset)
+	assert.Equal(t, "shop/checkout", result.Batches[0].Events[0].Attributes["meta.original_dataset"])
+}
+
+func TestExceptionsArePromotedWhenEnabled(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Name:    "test_span",
+		Events: []*trace.Span_Event{
+			{Name: "log", Attributes: []*common.KeyValue{stringAttr("exception.type", "not.an.exception")}},
+			{Name: "exception", Attributes: []*common.KeyValue{
+				stringAttr("exception.type", "java.lang.NullPointerException"),
+				stringAttr("exception.message", "oops"),
+				stringAttr("exception.stacktrace", "at Foo.bar()"),
+				stringAttr("other", "not copied"),
+			}},
+			{Name: "exception", Attributes: []*common.KeyValue{
+				stringAttr("exception.type", "second.Exception"),
+			}},
+		},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	span := result.Batches[0].Events[0]
+	assert.NotContains(t, span.Attributes, "exception.type")
+	assert.NotContains(t, span.Attributes, "error")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{PromoteExceptions: true})
+	require.NoError(t, err)
+	span = result.Batches[0].Events[0]
+	assert.Equal(t, "test_span", span.Attributes["name"])
+	assert.Equal(t, true, span.Attributes["error"])
+	assert.Equal(t, "java.lang.NullPointerException", span.Attributes["exception.type"])
+	assert.Equal(t, "oops", span.Attributes["exception.message"])
+	assert.Equal(t, "at Foo.bar()", span.Attributes["exception.stacktrace"])
+	assert.NotContains(t, span.Attributes, "other")
+	// the span's other events are marked as errors too, even those before the exception
+	assert.Equal(t, true, result.Batches[0].Events[1].Attributes["error"])
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{PromoteExceptions: true, TypedSpanFields: true})
+	require.NoError(t, err)
+	span = result.Batches[0].Events[0]
+	assert.True(t, span.Span.Error)
+	assert.NotContains(t, span.Attributes, "error")
+	assert.Equal(t, "oops", span.Attributes["exception.message"])
+	assert.Equal(t, true, result.Batches[0].Events[1].Attributes["error"])
+}
+
+func TestExceptionEventsAreMarkedWhenEnabled(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Events: []*trace.Span_Event{
+			{Name: "log", Attributes: []*common.KeyValue{stringAttr("exception.message", "not an exception")}},
+			{Name: "exception", Attributes: []*common.KeyValue{
+				stringAttr("exception.type", "java.lang.NullPointerException"),
+				stringAttr("exception.message", "oops"),
+			}},
+			{Name: "exception", Attributes: []*common.KeyValue{stringAttr("exception.type", "second.Exception")}},
+			{Name: "exception", Attributes: []*common.KeyValue{
+				stringAttr("exception.message", "raw message"),
+				stringAttr("error.message", "already normalized"),
+			}},
+		},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[2].Attributes, "error")
+	assert.NotContains(t, result.Batches[0].Events[2].Attributes, "error.message")
+
+	for _, opts := range []TranslateOptions{{MarkExceptionEvents: true}, {MarkExceptionEvents: true, TypedAttributeValues: true}} {
+		result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+		require.NoError(t, err)
+		events := result.Batches[0].Events
+		require.Equal(t, 5, len(events))
+		assert.NotContains(t, events[0].Attributes, "error")
+		assert.NotContains(t, events[1].Attributes, "error")
+		assert.NotContains(t, events[1].Fields(), "error.message")
+		assert.Equal(t, true, events[2].Attributes["error"])
+		assert.Equal(t, "oops", events[2].Attributes["error.message"])
+		assert.Equal(t, "second.Exception", events[3].Attributes["error.message"])
+		assert.Equal(t, "already normalized", events[4].Fields()["error.message"])
+	}
+}
+
+func TestHTTPServerErrorsAsErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        *trace.Status
+		attrs         []*common.KeyValue
+		expectedError bool
+	}{
+		{name: "no status code", attrs: nil, expectedError: false},
+		{name: "200", attrs: []*common.KeyValue{intAttr("http.status_code", 200)}, expectedError: false},
+		{name: "404", attrs: []*common.KeyValue{intAttr("http.status_code", 404)}, expectedError: false},
+		{name: "500", attrs: []*common.KeyValue{intAttr("http.status_code", 500)}, expectedError: true},
+		{name: "503 new semconv", attrs: []*common.KeyValue{intAttr("http.response.status_code", 503)}, expectedError: true},
+		{name: "string 502", attrs: []*common.KeyValue{stringAttr("http.status_code", "502")}, expectedError: true},
+		{name: "500 with OK status", status: &trace.Status{Code: trace.Status_STATUS_CODE_OK}, attrs: []*common.KeyValue{intAttr("http.status_code", 500)}, expectedError: false},
+		{name: "500 with explicit UNSET status", status: &trace.Status{Code: trace.Status_STATUS_CODE_UNSET}, attrs: []*common.KeyValue{intAttr("http.status_code", 500)}, expectedError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := buildTestTraceRequest(nil, &trace.Span{
+				TraceId:    test.RandomBytes(16),
+				SpanId:     test.RandomBytes(8),
+				Status:     tc.status,
+				Attributes: tc.attrs,
+				Events:     []*trace.Span_Event{{Name: "span_event"}},
+			})
+
+			result, err := TranslateTraceRequest(req, testRequestInfo())
+			require.NoError(t, err)
+			assert.NotContains(t, result.Batches[0].Events[0].Attributes, "error")
+
+			result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{HTTPServerErrorsAsErrors: true})
+			require.NoError(t, err)
+			for _, ev := range result.Batches[0].Events {
+				if tc.expectedError {
+					assert.Equal(t, true, ev.Attributes["error"])
+				} else {
+					assert.NotContains(t, ev.Attributes, "error")
+				}
+			}
+		})
+	}
+}
+
+func TestStatusCodeFormat(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Status:  &trace.Status{Code: trace.Status_STATUS_CODE_ERROR},
+	})
+
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{StatusCodeFormat: StatusCodeAsNumber})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Batches[0].Events[0].Attributes["status_code"])
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "status_code_text")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{StatusCodeFormat: StatusCodeAsText})
+	require.NoError(t, err)
+	assert.Equal(t, "ERROR", result.Batches[0].Events[0].Attributes["status_code"])
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "status_code_text")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{StatusCodeFormat: StatusCodeAsNumberAndText})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Batches[0].Events[0].Attributes["status_code"])
+	assert.Equal(t, "ERROR", result.Batches[0].Events[0].Attributes["status_code_text"])
+}
+
+func TestGetSpanStatusText(t *testing.T) {
+	assert.Equal(t, "UNSET", getSpanStatusText(int(trace.Status_STATUS_CODE_UNSET)))
+	assert.Equal(t, "OK", getSpanStatusText(int(trace.Status_STATUS_CODE_OK)))
+	assert.Equal(t, "ERROR", getSpanStatusText(int(trace.Status_STATUS_CODE_ERROR)))
+	assert.Equal(t, "UNSET", getSpanStatusText(42))
+}
+
+func TestSpanStatusErrorMapper(t *testing.T) {
+	req := buildTestTraceRequest(nil,
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Status: &trace.Status{Code: trace.Status_STATUS_CODE_ERROR}},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Status: &trace.Status{Code: trace.Status_STATUS_CODE_UNSET, Message: "timeout"}},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+	)
+
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{SpanStatusErrorMapper: NeverError})
+	require.NoError(t, err)
+	for _, ev := range result.Batches[0].Events {
+		assert.NotContains(t, ev.Attributes, "error")
+	}
+
+	unsetWithMessage := func(status *trace.Status) bool {
+		return status.GetCode() == trace.Status_STATUS_CODE_ERROR || status.GetMessage() != ""
+	}
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{SpanStatusErrorMapper: unsetWithMessage})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, true, events[0].Attributes["error"])
+	assert.Equal(t, true, events[1].Attributes["error"])
+	assert.NotContains(t, events[2].Attributes, "error")
+}
+
+func TestSpanTimeFormat(t *testing.T) {
+	start := time.Date(2022, 11, 7, 12, 30, 0, 123456789, time.UTC)
+	end := start.Add(1500 * time.Microsecond)
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId:           test.RandomBytes(16),
+		SpanId:            test.RandomBytes(8),
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(end.UnixNano()),
+		Events:            []*trace.Span_Event{{Name: "span_event"}},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "start_time")
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "end_time")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{SpanTimeFormat: SpanTimesAsRFC3339Nano})
+	require.NoError(t, err)
+	assert.Equal(t, "2022-11-07T12:30:00.123456789Z", result.Batches[0].Events[0].Attributes["start_time"])
+	assert.Equal(t, "2022-11-07T12:30:00.124956789Z", result.Batches[0].Events[0].Attributes["end_time"])
+	assert.NotContains(t, result.Batches[0].Events[1].Attributes, "start_time")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{SpanTimeFormat: SpanTimesAsUnixNano})
+	require.NoError(t, err)
+	assert.Equal(t, start.UnixNano(), result.Batches[0].Events[0].Attributes["start_time"])
+	assert.Equal(t, end.UnixNano(), result.Batches[0].Events[0].Attributes["end_time"])
+}
+
+func TestSpanKindFormat(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Kind:    trace.Span_SPAN_KIND_SERVER,
+	})
+
+	for _, typed := range []bool{false, true} {
+		result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{TypedSpanFields: typed})
+		require.NoError(t, err)
+		fields := result.Batches[0].Events[0].Fields()
+		assert.Equal(t, "server", fields["type"])
+		assert.Equal(t, "server", fields["span.kind"])
+
+		result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{TypedSpanFields: typed, SpanKindFormat: SpanKindAsSpanKindOnly})
+		require.NoError(t, err)
+		fields = result.Batches[0].Events[0].Fields()
+		assert.NotContains(t, fields, "type")
+		assert.Equal(t, "server", fields["span.kind"])
+
+		result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{TypedSpanFields: typed, SpanKindFormat: SpanKindAsTypeOnly})
+		require.NoError(t, err)
+		fields = result.Batches[0].Events[0].Fields()
+		assert.Equal(t, "server", fields["type"])
+		assert.NotContains(t, fields, "span.kind")
+	}
+}
+
+func TestScopeFieldFormat(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)})
+	req.ResourceSpans[0].ScopeSpans[0].Scope = &common.InstrumentationScope{Name: "net/http", Version: "0.45.0"}
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "net/http", attrs["library.name"])
+	assert.Equal(t, "0.45.0", attrs["library.version"])
+	assert.NotContains(t, attrs, "otel.scope.name")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{ScopeFieldFormat: ScopeAsOTelScopeFields})
+	require.NoError(t, err)
+	attrs = result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "net/http", attrs["otel.scope.name"])
+	assert.Equal(t, "0.45.0", attrs["otel.scope.version"])
+	assert.NotContains(t, attrs, "library.name")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{ScopeFieldFormat: ScopeAsLibraryAndOTelScopeFields})
+	require.NoError(t, err)
+	attrs = result.Batches[0].Events[0].Attributes
+	assert.Equal(t, "net/http", attrs["library.name"])
+	assert.Equal(t, "net/http", attrs["otel.scope.name"])
+}
+
+func TestHighPrecisionDurationFields(t *testing.T) {
+	start := time.Now()
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId:           test.RandomBytes(16),
+		SpanId:            test.RandomBytes(8),
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(start.UnixNano()) + 12345,
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "duration_us")
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "duration_ns")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeDurationMicros: true, IncludeDurationNanos: true})
+	require.NoError(t, err)
+	attrs := result.Batches[0].Events[0].Attributes
+	assert.Equal(t, 0.012345, attrs["duration_ms"])
+	assert.Equal(t, int64(12), attrs["duration_us"])
+	assert.Equal(t, int64(12345), attrs["duration_ns"])
+}
+
+func TestInvalidDurationIsClamped(t *testing.T) {
+	start := time.Now()
+	req := buildTestTraceRequest(nil,
+		&trace.Span{
+			TraceId:           test.RandomBytes(16),
+			SpanId:            test.RandomBytes(8),
+			StartTimeUnixNano: uint64(start.UnixNano()),
+			EndTimeUnixNano:   uint64(start.Add(-time.Second).UnixNano()),
+		},
+		&trace.Span{
+			TraceId:           test.RandomBytes(16),
+			SpanId:            test.RandomBytes(8),
+			StartTimeUnixNano: uint64(start.UnixNano()),
+			EndTimeUnixNano:   uint64(start.Add(time.Second).UnixNano()),
+		},
+	)
+
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeDurationNanos: true})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 2, len(events))
+	assert.Equal(t, float64(0), events[0].Attributes["duration_ms"])
+	assert.Equal(t, int64(0), events[0].Attributes["duration_ns"])
+	assert.Equal(t, true, events[0].Attributes["meta.invalid_duration"])
+	assert.Equal(t, float64(1000), events[1].Attributes["duration_ms"])
+	assert.NotContains(t, events[1].Attributes, "meta.invalid_duration")
+	assert.Equal(t, []string{"clamped 1 spans with end time before start time to zero duration"}, result.Warnings)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{DropInvalidDurations: true})
+	require.NoError(t, err)
+	events = result.Batches[0].Events
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, float64(1000), events[0].Attributes["duration_ms"])
+	assert.Equal(t, 1, result.DroppedSpans)
+	assert.Equal(t, []string{"dropped 1 spans with end time before start time"}, result.Warnings)
+}
+
+func TestTimestampsAreClampedWhenEnabled(t *testing.T) {
+	now := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	spanAt := func(ts time.Time) *trace.Span {
+		return &trace.Span{
+			TraceId:           test.RandomBytes(16),
+			SpanId:            test.RandomBytes(8),
+			StartTimeUnixNano: uint64(ts.UnixNano()),
+			EndTimeUnixNano:   uint64(ts.UnixNano()),
+		}
+	}
+	req := buildTestTraceRequest(nil,
+		spanAt(now.Add(-time.Minute)),
+		spanAt(now.Add(10*365*24*time.Hour)),
+		spanAt(time.Unix(0, 1)),
+	)
+	ri := testRequestInfo()
+	ri.ReceivedAt = now
+
+	result, err := TranslateTraceRequest(req, ri)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(10*365*24*time.Hour), result.Batches[0].Events[1].Timestamp)
+
+	result, err = TranslateTraceRequestWithOptions(req, ri, TranslateOptions{TimestampTolerance: time.Hour})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, now.Add(-time.Minute), events[0].Timestamp)
+	assert.NotContains(t, events[0].Attributes, "meta.original_timestamp")
+	assert.Equal(t, now.Add(time.Hour), events[1].Timestamp)
+	assert.Equal(t, "2032-11-04T12:00:00Z", events[1].Attributes["meta.original_timestamp"])
+	assert.Equal(t, now.Add(-time.Hour), events[2].Timestamp)
+	assert.Equal(t, "1970-01-01T00:00:00.000000001Z", events[2].Attributes["meta.original_timestamp"])
+	assert.Equal(t, []string{"clamped 2 event timestamps outside the allowed window"}, result.Warnings)
+}
+
+func TestZeroTimestampIsReplacedWhenEnabled(t *testing.T) {
+	now := time.Date(2022, 11, 7, 12, 0, 0, 0, time.UTC)
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Links:   []*trace.Span_Link{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}},
+	})
+	ri := testRequestInfo()
+	ri.ReceivedAt = now
+
+	result, err := TranslateTraceRequest(req, ri)
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(0, 0).UTC(), result.Batches[0].Events[0].Timestamp)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "meta.invalid_timestamp")
+
+	result, err = TranslateTraceRequestWithOptions(req, ri, TranslateOptions{ReplaceZeroTimestamps: true})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, now, events[0].Timestamp)
+	assert.Equal(t, true, events[0].Attributes["meta.invalid_timestamp"])
+	// links use their span's timestamp
+	assert.Equal(t, now, events[1].Timestamp)
+}
+
+func TestIncludeTimestampNanos(t *testing.T) {
+	start := uint64(1667822400123456789)
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId:           test.RandomBytes(16),
+		SpanId:            test.RandomBytes(8),
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   start + 1000,
+		Events:            []*trace.Span_Event{{Name: "span_event", TimeUnixNano: start + 1}},
+		Links:             []*trace.Span_Link{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.NotContains(t, result.Batches[0].Events[0].Attributes, "timestamp_ns")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeTimestampNanos: true})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 3, len(events))
+	assert.Equal(t, int64(start), events[0].Attributes["timestamp_ns"])
+	assert.Equal(t, int64(start+1), events[1].Attributes["timestamp_ns"])
+	// links use their span's timestamp
+	assert.Equal(t, int64(start), events[2].Attributes["timestamp_ns"])
+}
+
+func TestSpanLinkFlags(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Links: []*trace.Span_Link{
+			{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Flags: 0x01},
+			{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Flags: 0x02},
+			{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+		},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 4, len(events))
+	assert.Equal(t, int64(1), events[1].Attributes["trace.link.flags"])
+	assert.Equal(t, true, events[1].Attributes["trace.link.sampled"])
+	assert.Equal(t, int64(2), events[2].Attributes["trace.link.flags"])
+	assert.Equal(t, false, events[2].Attributes["trace.link.sampled"])
+	assert.NotContains(t, events[3].Attributes, "trace.link.flags")
+	assert.NotContains(t, events[3].Attributes, "trace.link.sampled")
+}
+
+func TestDecompressedBodySizeLimit(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId:    test.RandomBytes(16),
+		SpanId:     test.RandomBytes(8),
+		Attributes: []*common.KeyValue{stringAttr("padding", strings.Repeat("a", 10000))},
+	})
+	ri := testRequestInfo()
+	ri.ContentEncoding = "gzip"
+	body, err := prepareOtlpRequestHttpBody(req, ri.ContentType, ri.ContentEncoding)
+	require.NoError(t, err)
+	require.Less(t, len(body), 1000)
+
+	_, err = TranslateTraceRequestFromReaderWithOptions(io.NopCloser(strings.NewReader(body)), ri, TranslateOptions{MaxDecompressedBodySize: 1000})
+	assert.Equal(t, ErrRequestTooLarge, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ErrRequestTooLarge.HTTPStatusCode)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(AsGRPCError(err)))
+
+	result, err := TranslateTraceRequestFromReaderWithOptions(io.NopCloser(strings.NewReader(body)), ri, TranslateOptions{MaxDecompressedBodySize: int64(proto.Size(req))})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(result.Batches))
+}
+
+func TestSpanNameFallbackAndTruncation(t *testing.T) {
+	req := buildTestTraceRequest(nil,
+		&trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Events:  []*trace.Span_Event{{Name: "span_event"}},
+		},
+		&trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Name:    "a_very_long_span_name",
+		},
+	)
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, "", events[0].Attributes["name"])
+	assert.Equal(t, "a_very_long_span_name", events[2].Attributes["name"])
+	assert.Empty(t, result.Warnings)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{
+		DefaultSpanName:   "unnamed_span",
+		MaxSpanNameLength: 12,
+	})
+	require.NoError(t, err)
+	events = result.Batches[0].Events
+	assert.Equal(t, "unnamed_span", events[0].Attributes["name"])
+	assert.Equal(t, "unnamed_span", events[1].Attributes["parent_name"])
+	assert.Equal(t, "a_very_long_", events[2].Attributes["name"])
+	assert.Equal(t, []string{"truncated 1 span names to 12 bytes"}, result.Warnings)
+}
+
+func TestStatusMessageTruncationAndSanitization(t *testing.T) {
+	req := buildTestTraceRequest(nil,
+		&trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Status:  &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "panic: \xffboom\nstack trace follows"},
+		},
+		&trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Status:  &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "short"},
+		},
+	)
+
+	// with UTF-8 repair on, the invalid byte would be replaced before we get here
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{DisableUTF8Repair: true})
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, "panic: boom\nstack trace follows", events[0].Attributes["status_message"])
+	assert.Empty(t, result.Warnings)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{DisableUTF8Repair: true, MaxStatusMessageLength: 11})
+	require.NoError(t, err)
+	events = result.Batches[0].Events
+	assert.Equal(t, "panic: boom", events[0].Attributes["status_message"])
+	assert.Equal(t, "short", events[1].Attributes["status_message"])
+	assert.Equal(t, []string{"truncated 1 status messages to 11 bytes"}, result.Warnings)
+}
+
+func TestFullTraceIDs(t *testing.T) {
+	traceID := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xf7, 0x98, 0xa1, 0xe7, 0xf3, 0x3c, 0x8a, 0xf6}
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: traceID,
+		SpanId:  test.RandomBytes(8),
+		Events:  []*trace.Span_Event{{Name: "span_event"}},
+		Links:   []*trace.Span_Link{{TraceId: traceID, SpanId: test.RandomBytes(8)}},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, "f798a1e7f33c8af6", events[0].Attributes["trace.trace_id"])
+	assert.NotContains(t, events[0].Attributes, "meta.original_trace_id")
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{IncludeOriginalTraceID: true})
+	require.NoError(t, err)
+	for _, ev := range result.Batches[0].Events {
+		assert.Equal(t, "f798a1e7f33c8af6", ev.Attributes["trace.trace_id"])
+		assert.Equal(t, "0000000000000000f798a1e7f33c8af6", ev.Attributes["meta.original_trace_id"])
+	}
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{FullTraceIDs: true, IncludeOriginalTraceID: true})
+	require.NoError(t, err)
+	events = result.Batches[0].Events
+	for _, ev := range events {
+		assert.Equal(t, "0000000000000000f798a1e7f33c8af6", ev.Attributes["trace.trace_id"])
+		assert.NotContains(t, ev.Attributes, "meta.original_trace_id")
+	}
+	assert.Equal(t, "0000000000000000f798a1e7f33c8af6", events[2].Attributes["trace.link.trace_id"])
+}
+
+func TestInvalidIDs(t *testing.T) {
+	req := buildTestTraceRequest(nil,
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)},
+		&trace.Span{TraceId: make([]byte, 16), SpanId: test.RandomBytes(8)},
+		&trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(4)},
+	)
+
+	// by default they're only counted
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 3, len(events))
+	assert.NotContains(t, events[1].Attributes, "meta.invalid_trace_id")
+	assert.NotContains(t, events[2].Attributes, "meta.invalid_span_id")
+	assert.Equal(t, 2, result.InvalidIDSpans)
+	assert.Empty(t, result.Warnings)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{FlagInvalidIDs: true})
+	require.NoError(t, err)
+	events = result.Batches[0].Events
+	require.Equal(t, 3, len(events))
+	assert.NotContains(t, events[0].Attributes, "meta.invalid_trace_id")
+	assert.NotContains(t, events[0].Attributes, "meta.invalid_span_id")
+	assert.Equal(t, true, events[1].Attributes["meta.invalid_trace_id"])
+	assert.NotContains(t, events[1].Attributes, "meta.invalid_span_id")
+	assert.Equal(t, true, events[2].Attributes["meta.invalid_span_id"])
+	assert.Equal(t, 2, result.InvalidIDSpans)
+	assert.Equal(t, 0, result.DroppedSpans)
+	assert.Equal(t, []string{"flagged 2 spans with invalid trace or span IDs"}, result.Warnings)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{DropInvalidIDs: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.Batches[0].Events))
+	assert.Equal(t, 2, result.InvalidIDSpans)
+	assert.Equal(t, 2, result.DroppedSpans)
+	assert.Equal(t, []string{"dropped 2 spans with invalid trace or span IDs"}, result.Warnings)
+}
+
+func TestAttributePrecedence(t *testing.T) {
+	req := buildTestTraceRequest(
+		[]*common.KeyValue{
+			stringAttr("service.name", "my-service"),
+			stringAttr("deployment.environment", "production"),
+			stringAttr("host.name", "resource-host"),
+		},
+		&trace.Span{
+			TraceId:    test.RandomBytes(16),
+			SpanId:     test.RandomBytes(8),
+			Attributes: []*common.KeyValue{stringAttr("host.name", "span-host"), stringAttr("http.method", "GET")},
+			Events: []*trace.Span_Event{{
+				Name:       "span_event",
+				Attributes: []*common.KeyValue{stringAttr("deployment.environment", "staging")},
+			}},
+		},
+	)
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	assert.Equal(t, "span-host", events[0].Attributes["host.name"])
+	assert.Equal(t, "staging", events[1].Attributes["deployment.environment"])
+	assert.Empty(t, result.Warnings)
+
+	result, err = TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{
+		AttributePrecedence:      ResourceAttributesFirst,
+		ReportAttributeConflicts: true,
+	})
+	require.NoError(t, err)
+	events = result.Batches[0].Events
+	assert.Equal(t, "resource-host", events[0].Attributes["host.name"])
+	assert.Equal(t, "GET", events[0].Attributes["http.method"])
+	assert.Equal(t, "production", events[1].Attributes["deployment.environment"])
+	assert.Equal(t, []string{`span attributes conflicted with resource attributes in dataset "my-service": deployment.environment, host.name`}, result.Warnings)
+}
+
+// countingCloser counts how many times its body is closed
+type countingCloser struct {
+	io.Reader
+	closes int
+}
+
+func (c *countingCloser) Close() error {
+	c.closes++
+	return nil
+}
+
+func TestTranslateTraceRequestFromReaderClosesBody(t *testing.T) {
+	body, err := prepareOtlpRequestHttpBody(buildTestTraceRequest(nil, &trace.Span{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8)}), "application/protobuf", "")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		body string
+		ri   RequestInfo
+	}{
+		{name: "success", body: body, ri: testRequestInfo()},
+		{name: "invalid headers", body: body, ri: RequestInfo{}},
+		{name: "invalid body", body: "not protobuf", ri: testRequestInfo()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			closer := &countingCloser{Reader: strings.NewReader(tc.body)}
+			TranslateTraceRequestFromReader(closer, tc.ri)
+			assert.Equal(t, 1, closer.closes)
+
+			closer = &countingCloser{Reader: strings.NewReader(tc.body)}
+			TranslateTraceRequestFromReaderNoClose(closer, tc.ri)
+			assert.Equal(t, 0, closer.closes)
+		})
+	}
+
+	result, err := TranslateTraceRequestFromReaderNoClose(strings.NewReader(body), testRequestInfo())
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(result.Batches[0].Events))
+}
+
+func BenchmarkTranslateTraceRequest(b *testing.B) {
+	spans := make([]*trace.Span, 100)
+	for i := range spans {
+		spans[i] = &trace.Span{
+			TraceId: test.RandomBytes(16),
+			SpanId:  test.RandomBytes(8),
+			Name:    "span",
+			Attributes: []*common.KeyValue{
+				stringAttr("http.method", "GET"),
+				stringAttr("http.route", "/users/:id"),
+				intAttr("http.status_code", 200),
+			},
+			Events: []*trace.Span_Event{{Name: "event", Attributes: []*common.KeyValue{stringAttr("key", "value")}}},
+		}
+	}
+	req := buildTestTraceRequest([]*common.KeyValue{
+		stringAttr("service.name", "my-service"),
+		stringAttr("telemetry.sdk.language", "go"),
+		stringAttr("host.name", "host"),
+	}, spans...)
+	ri := testRequestInfo()
+
+	for name, opts := range map[string]TranslateOptions{
+		"default":                    {},
+		"typed span fields":          {TypedSpanFields: true},
+		"shared resource attributes": {SharedResourceAttributes: true},
+		"typed attribute values":     {TypedAttributeValues: true},
+		"caller buffers":             {EventBuffer: make([]Event, 0, 200), BatchBuffer: make([]Batch, 0, 1)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := TranslateTraceRequestWithOptions(req, ri, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// testRequestInfo returns RequestInfo for an E&S (non-legacy) key
+func testRequestInfo() RequestInfo {
+	return RequestInfo{
+		ApiKey:      "abc123DEF456ghi789jklm",
+		ContentType: "application/protobuf",
+	}
+}
+
+// buildTestTraceRequest wraps spans in a single resource and scope
+func buildTestTraceRequest(resourceAttrs []*common.KeyValue, spans ...*trace.Span) *collectortrace.ExportTraceServiceRequest {
+	return &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*trace.ResourceSpans{{
+			Resource:   &resource.Resource{Attributes: resourceAttrs},
+			ScopeSpans: []*trace.ScopeSpans{{Spans: spans}},
+		}},
+	}
+}
+
+func stringAttr(key, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intAttr(key string, value int64) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: value}}}
+}
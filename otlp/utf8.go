@@ -0,0 +1,63 @@
+package otlp
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// repairRequestUTF8 replaces invalid UTF-8 in every string of request, attribute
+// keys and values included, and returns how many strings it changed. Requests
+// decoded by protobuf are always valid, but ones built in-process may not be,
+// and invalid strings break downstream JSON encoders.
+func repairRequestUTF8(request proto.Message, opts TranslateOptions) int {
+	if opts.DisableUTF8Repair {
+		return 0
+	}
+	return repairMessageUTF8(request.ProtoReflect())
+}
+
+func repairMessageUTF8(m protoreflect.Message) (repaired int) {
+	type fix struct {
+		fd    protoreflect.FieldDescriptor
+		value string
+	}
+	// the message mustn't be changed while we range over it
+	var fixes []fix
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		// OTLP messages have no map fields
+		switch {
+		case fd.IsMap():
+		case fd.IsList():
+			repaired += repairListUTF8(fd, v.List())
+		case fd.Kind() == protoreflect.MessageKind:
+			repaired += repairMessageUTF8(v.Message())
+		case fd.Kind() == protoreflect.StringKind:
+			if s := v.String(); !utf8.ValidString(s) {
+				fixes = append(fixes, fix{fd, strings.ToValidUTF8(s, string(utf8.RuneError))})
+			}
+		}
+		return true
+	})
+	for _, f := range fixes {
+		m.Set(f.fd, protoreflect.ValueOfString(f.value))
+	}
+	return repaired + len(fixes)
+}
+
+func repairListUTF8(fd protoreflect.FieldDescriptor, list protoreflect.List) (repaired int) {
+	for i := 0; i < list.Len(); i++ {
+		switch fd.Kind() {
+		case protoreflect.MessageKind:
+			repaired += repairMessageUTF8(list.Get(i).Message())
+		case protoreflect.StringKind:
+			if s := list.Get(i).String(); !utf8.ValidString(s) {
+				list.Set(i, protoreflect.ValueOfString(strings.ToValidUTF8(s, string(utf8.RuneError))))
+				repaired++
+			}
+		}
+	}
+	return repaired
+}
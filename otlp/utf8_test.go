@@ -0,0 +1,78 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestRepairInvalidUTF8InTraceRequest(t *testing.T) {
+	req := buildTestTraceRequest([]*common.KeyValue{stringAttr("host.\xffname", "web-1")}, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Name:    "GET \xfe/users",
+		Attributes: []*common.KeyValue{
+			stringAttr("route", "/users/caf\xe9"),
+			{Key: "tags", Value: &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+				Values: []*common.AnyValue{{Value: &common.AnyValue_StringValue{StringValue: "ok\xff"}}},
+			}}}},
+		},
+		Events: []*trace.Span_Event{{Name: "exception", Attributes: []*common.KeyValue{stringAttr("exception.message", "bad \xc3")}}},
+	})
+
+	result, err := TranslateTraceRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	events := result.Batches[0].Events
+	require.Equal(t, 2, len(events))
+	span, spanEvent := events[0], events[1]
+	assert.Equal(t, "GET �/users", span.Attributes["name"])
+	assert.Equal(t, "/users/caf�", span.Attributes["route"])
+	assert.Equal(t, "web-1", span.Attributes["host.�name"])
+	assert.Equal(t, "[\"ok�\"]\n", span.Attributes["tags"])
+	assert.Equal(t, "bad �", spanEvent.Attributes["exception.message"])
+	assert.Equal(t, []string{"replaced invalid UTF-8 in 5 strings"}, result.Warnings)
+}
+
+func TestRepairInvalidUTF8InLogsRequest(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	log := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	log.Body = &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "line \xff"}}
+
+	result, err := TranslateLogsRequest(req, testRequestInfo())
+	require.NoError(t, err)
+	assert.Equal(t, "line �", result.Batches[0].Events[0].Attributes["body"])
+	assert.Equal(t, []string{"replaced invalid UTF-8 in 1 strings"}, result.Warnings)
+}
+
+func TestDisableUTF8Repair(t *testing.T) {
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId:    test.RandomBytes(16),
+		SpanId:     test.RandomBytes(8),
+		Attributes: []*common.KeyValue{stringAttr("route", "/users/caf\xe9")},
+	})
+
+	result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), TranslateOptions{DisableUTF8Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, "/users/caf\xe9", result.Batches[0].Events[0].Attributes["route"])
+	assert.Empty(t, result.Warnings)
+}
+
+func TestUTF8RepairIsOnByDefaultForPdata(t *testing.T) {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	span.Attributes().PutStr("route", "/users/caf\xe9")
+
+	result, err := TranslateTracesWithOptions(td, testRequestInfo(), TranslateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "/users/caf�", result.Batches[0].Events[0].Attributes["route"])
+	assert.Equal(t, []string{"replaced invalid UTF-8 in 1 strings"}, result.Warnings)
+}
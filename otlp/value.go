@@ -0,0 +1,321 @@
+package otlp
+
+import (
+	"fmt"
+	"math"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// ValueKind identifies the type held by a Value
+type ValueKind uint8
+
+const (
+	// ValueKindEmpty is the kind of the zero Value
+	ValueKindEmpty ValueKind = iota
+	ValueKindString
+	ValueKindInt64
+	ValueKindFloat64
+	ValueKindBool
+	ValueKindBytes
+	ValueKindSlice
+)
+
+// Value is a typed attribute value: a string, int64, float64, bool, byte slice or
+// slice of Values. Unlike an interface{}, it holds numbers and booleans without a
+// separate allocation and can be encoded without type switches or reflection.
+type Value struct {
+	kind ValueKind
+	num  uint64 // int64, float64 bits or bool
+	str  string // string or bytes
+	list *[]Value
+}
+
+// Attribute is a key with a typed Value
+type Attribute struct {
+	Key   string
+	Value Value
+}
+
+// StringValue returns a Value holding s
+func StringValue(s string) Value {
+	return Value{kind: ValueKindString, str: s}
+}
+
+// Int64Value returns a Value holding n
+func Int64Value(n int64) Value {
+	return Value{kind: ValueKindInt64, num: uint64(n)}
+}
+
+// Float64Value returns a Value holding f
+func Float64Value(f float64) Value {
+	return Value{kind: ValueKindFloat64, num: math.Float64bits(f)}
+}
+
+// BoolValue returns a Value holding b
+func BoolValue(b bool) Value {
+	v := Value{kind: ValueKindBool}
+	if b {
+		v.num = 1
+	}
+	return v
+}
+
+// BytesValue returns a Value holding a copy of b
+func BytesValue(b []byte) Value {
+	return Value{kind: ValueKindBytes, str: string(b)}
+}
+
+// SliceValue returns a Value holding values
+func SliceValue(values []Value) Value {
+	return Value{kind: ValueKindSlice, list: &values}
+}
+
+// ValueOf converts a Go value of one of the types found in translated events into a
+// Value. Unsigned integers too large for an int64 become floats, and values of any
+// other type are formatted as strings.
+func ValueOf(v interface{}) Value {
+	switch v := v.(type) {
+	case nil:
+		return Value{}
+	case Value:
+		return v
+	case string:
+		return StringValue(v)
+	case bool:
+		return BoolValue(v)
+	case int:
+		return Int64Value(int64(v))
+	case int32:
+		return Int64Value(int64(v))
+	case int64:
+		return Int64Value(v)
+	case uint32:
+		return Int64Value(int64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			return Float64Value(float64(v))
+		}
+		return Int64Value(int64(v))
+	case float32:
+		return Float64Value(float64(v))
+	case float64:
+		return Float64Value(v)
+	case []byte:
+		return BytesValue(v)
+	case []interface{}:
+		values := make([]Value, len(v))
+		for i, elem := range v {
+			values[i] = ValueOf(elem)
+		}
+		return SliceValue(values)
+	default:
+		return StringValue(fmt.Sprint(v))
+	}
+}
+
+// Kind returns the type of value held
+func (v Value) Kind() ValueKind { return v.kind }
+
+// AsString returns the value's string, or "" if it doesn't hold a string
+func (v Value) AsString() string {
+	if v.kind != ValueKindString {
+		return ""
+	}
+	return v.str
+}
+
+// AsInt64 returns the value's int64, or 0 if it doesn't hold an int64
+func (v Value) AsInt64() int64 {
+	if v.kind != ValueKindInt64 {
+		return 0
+	}
+	return int64(v.num)
+}
+
+// AsFloat64 returns the value's float64, or 0 if it doesn't hold a float64
+func (v Value) AsFloat64() float64 {
+	if v.kind != ValueKindFloat64 {
+		return 0
+	}
+	return math.Float64frombits(v.num)
+}
+
+// AsBool returns the value's bool, or false if it doesn't hold a bool
+func (v Value) AsBool() bool {
+	return v.kind == ValueKindBool && v.num != 0
+}
+
+// AsBytes returns the value's bytes, or nil if it doesn't hold bytes
+func (v Value) AsBytes() []byte {
+	if v.kind != ValueKindBytes {
+		return nil
+	}
+	return []byte(v.str)
+}
+
+// AsSlice returns the value's elements, or nil if it doesn't hold a slice
+func (v Value) AsSlice() []Value {
+	if v.kind != ValueKindSlice {
+		return nil
+	}
+	return *v.list
+}
+
+// Interface returns the value as the Go type translation uses in Attributes:
+// string, int64, float64, bool, []byte or []interface{}. The zero Value gives nil.
+func (v Value) Interface() interface{} {
+	switch v.kind {
+	case ValueKindString:
+		return v.str
+	case ValueKindInt64:
+		return v.AsInt64()
+	case ValueKindFloat64:
+		return v.AsFloat64()
+	case ValueKindBool:
+		return v.AsBool()
+	case ValueKindBytes:
+		return v.AsBytes()
+	case ValueKindSlice:
+		elems := make([]interface{}, len(*v.list))
+		for i, elem := range *v.list {
+			elems[i] = elem.Interface()
+		}
+		return elems
+	}
+	return nil
+}
+
+// sizeBytes estimates the value's serialized size the same way Event.SizeBytes
+// does for attributes
+func (v Value) sizeBytes() int {
+	switch v.kind {
+	case ValueKindString, ValueKindBytes:
+		return len(v.str)
+	case ValueKindBool:
+		return 1
+	case ValueKindInt64, ValueKindFloat64:
+		return 8
+	case ValueKindSlice:
+		return len(fmt.Sprint(v.Interface()))
+	}
+	return 0
+}
+
+// getTypedValue is like getValue but returns a Value, so scalar attributes
+// don't need to be boxed
+func getTypedValue(value *common.AnyValue) (result Value, truncatedBytes int) {
+	switch v := value.Value.(type) {
+	case *common.AnyValue_StringValue:
+		return StringValue(v.StringValue), 0
+	case *common.AnyValue_BoolValue:
+		return BoolValue(v.BoolValue), 0
+	case *common.AnyValue_DoubleValue:
+		return Float64Value(v.DoubleValue), 0
+	case *common.AnyValue_IntValue:
+		return Int64Value(v.IntValue), 0
+	}
+	val, truncatedBytes := getValue(value)
+	if s, ok := val.(string); ok {
+		return StringValue(s), truncatedBytes
+	}
+	return Value{}, 0
+}
+
+// readsTypedAttribute reports whether translation reads or rewrites the attribute
+// key after adding it, so it must stay in an event's Attributes map rather than
+// its typed Values
+func readsTypedAttribute(key string, opts TranslateOptions) bool {
+	if len(key) >= len("meta.") && key[:len("meta.")] == "meta." {
+		return true
+	}
+	for _, keys := range [][]string{unshareableKeys, opts.sampleRateKeys(), httpStatusCodeKeys, exceptionAttributeKeys} {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typedValues returns values when TypedAttributeValues is set, and nil otherwise
+func (opts TranslateOptions) typedValues(values *[]Attribute) *[]Attribute {
+	if !opts.TypedAttributeValues {
+		return nil
+	}
+	return values
+}
+
+// lookupTypedValue returns the value of key in values
+func lookupTypedValue(values []Attribute, key string) (Value, bool) {
+	for i := range values {
+		if values[i].Key == key {
+			return values[i].Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// setTypedValue sets key to v in values, replacing any existing value
+func setTypedValue(values *[]Attribute, key string, v Value) {
+	for i := range *values {
+		if (*values)[i].Key == key {
+			(*values)[i].Value = v
+			return
+		}
+	}
+	*values = append(*values, Attribute{Key: key, Value: v})
+}
+
+// deleteTypedValue removes key from values, if present
+func deleteTypedValue(values *[]Attribute, key string) {
+	if values == nil {
+		return
+	}
+	for i := range *values {
+		if (*values)[i].Key == key {
+			*values = append((*values)[:i], (*values)[i+1:]...)
+			return
+		}
+	}
+}
+
+// addTypedAttributes is like addAttributesToMap, but adds attributes that
+// translation doesn't read again to values instead of attrs. With nil values
+// it behaves exactly like addAttributesToMap.
+func addTypedAttributes(attrs map[string]interface{}, values *[]Attribute, attributes []*common.KeyValue, opts TranslateOptions) (dropped int) {
+	if values == nil {
+		return addAttributesToMap(attrs, attributes)
+	}
+	if *values == nil {
+		*values = make([]Attribute, 0, len(attributes))
+	}
+	for _, attr := range attributes {
+		if attr.Key == "" || attr.Value == nil {
+			dropped++
+			continue
+		}
+		if readsTypedAttribute(attr.Key, opts) {
+			if !addAttributeToMap(attrs, attr) {
+				dropped++
+			}
+			deleteTypedValue(values, attr.Key)
+			continue
+		}
+		val, truncatedBytes := getTypedValue(attr.Value)
+		if val.kind == ValueKindEmpty {
+			dropped++
+			continue
+		}
+		setTypedValue(values, attr.Key, val)
+		// typed values take precedence, but fields written earlier are removed so
+		// the event's layers never disagree
+		delete(attrs, attr.Key)
+		if truncatedBytes != 0 {
+			attrs["meta.truncated_bytes"] = truncatedBytes
+			attrs["meta.truncated_field"] = attr.Key
+		}
+	}
+	return dropped
+}
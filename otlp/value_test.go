@@ -0,0 +1,190 @@
+package otlp
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/husky/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestValue(t *testing.T) {
+	assert.Equal(t, ValueKindEmpty, Value{}.Kind())
+	assert.Nil(t, Value{}.Interface())
+
+	s := StringValue("hello")
+	assert.Equal(t, ValueKindString, s.Kind())
+	assert.Equal(t, "hello", s.AsString())
+	assert.Equal(t, int64(0), s.AsInt64())
+
+	assert.Equal(t, int64(-42), Int64Value(-42).AsInt64())
+	assert.Equal(t, 1.5, Float64Value(1.5).AsFloat64())
+	assert.True(t, BoolValue(true).AsBool())
+	assert.False(t, BoolValue(false).AsBool())
+	assert.Equal(t, []byte("raw"), BytesValue([]byte("raw")).AsBytes())
+	assert.Equal(t, "", Int64Value(1).AsString())
+
+	slice := SliceValue([]Value{StringValue("a"), Int64Value(1)})
+	assert.Equal(t, 2, len(slice.AsSlice()))
+	assert.Equal(t, []interface{}{"a", int64(1)}, slice.Interface())
+}
+
+func TestValueOf(t *testing.T) {
+	testCases := []struct {
+		in       interface{}
+		expected interface{}
+	}{
+		{in: "s", expected: "s"},
+		{in: true, expected: true},
+		{in: 1, expected: int64(1)},
+		{in: int32(2), expected: int64(2)},
+		{in: int64(3), expected: int64(3)},
+		{in: uint32(4), expected: int64(4)},
+		{in: uint64(5), expected: int64(5)},
+		{in: uint64(math.MaxUint64), expected: float64(math.MaxUint64)},
+		{in: float32(0.5), expected: 0.5},
+		{in: 1.25, expected: 1.25},
+		{in: []byte("b"), expected: []byte("b")},
+		{in: []interface{}{"a", 1}, expected: []interface{}{"a", int64(1)}},
+		{in: time.Duration(0), expected: "0s"},
+		{in: nil, expected: nil},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, ValueOf(tc.in).Interface())
+	}
+	assert.Equal(t, StringValue("v"), ValueOf(StringValue("v")))
+}
+
+func TestTypedValueHelpers(t *testing.T) {
+	var values []Attribute
+	setTypedValue(&values, "a", Int64Value(1))
+	setTypedValue(&values, "b", Int64Value(2))
+	setTypedValue(&values, "a", Int64Value(3))
+	assert.Equal(t, []Attribute{{Key: "a", Value: Int64Value(3)}, {Key: "b", Value: Int64Value(2)}}, values)
+
+	v, ok := lookupTypedValue(values, "b")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), v.AsInt64())
+
+	deleteTypedValue(&values, "a")
+	deleteTypedValue(&values, "missing")
+	deleteTypedValue(nil, "b")
+	assert.Equal(t, []Attribute{{Key: "b", Value: Int64Value(2)}}, values)
+}
+
+func TestTypedAttributeValues(t *testing.T) {
+	spans := []*trace.Span{{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Name:    "span",
+		Attributes: []*common.KeyValue{
+			stringAttr("http.route", "/users/:id"),
+			intAttr("http.status_code", 200),
+			intAttr("sampleRate", 5),
+			stringAttr("host.name", "span-host"),
+			{Key: "ratio", Value: &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: 0.5}}},
+			{Key: "cached", Value: &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}}},
+		},
+		Events: []*trace.Span_Event{{Name: "event", Attributes: []*common.KeyValue{stringAttr("key", "value")}}},
+		Links:  []*trace.Span_Link{{TraceId: test.RandomBytes(16), SpanId: test.RandomBytes(8), Attributes: []*common.KeyValue{stringAttr("link", "value")}}},
+	}}
+	resourceAttrs := []*common.KeyValue{stringAttr("service.name", "my-service"), stringAttr("host.name", "resource-host")}
+
+	for _, opts := range []TranslateOptions{
+		{},
+		{AttributePrecedence: ResourceAttributesFirst},
+		{TypedSpanFields: true, SharedResourceAttributes: true},
+	} {
+		expected, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo(), opts)
+		require.NoError(t, err)
+		opts.TypedAttributeValues = true
+		result, err := TranslateTraceRequestWithOptions(buildTestTraceRequest(resourceAttrs, spans...), testRequestInfo(), opts)
+		require.NoError(t, err)
+
+		events := result.Batches[0].Events
+		expectedEvents := expected.Batches[0].Events
+		require.Equal(t, 3, len(events))
+		for i := range events {
+			require.NotNil(t, events[i].Values)
+			assert.Equal(t, expectedEvents[i].Fields(), events[i].Fields())
+			assert.Equal(t, expectedEvents[i].NumFields(), events[i].NumFields())
+			assert.Equal(t, expectedEvents[i].SizeBytes(), events[i].SizeBytes())
+			assert.Equal(t, expectedEvents[i].SampleRate, events[i].SampleRate)
+		}
+
+		span := events[0]
+		assert.Contains(t, span.Values, Attribute{Key: "http.route", Value: StringValue("/users/:id")})
+		assert.Contains(t, span.Values, Attribute{Key: "ratio", Value: Float64Value(0.5)})
+		assert.Contains(t, span.Values, Attribute{Key: "cached", Value: BoolValue(true)})
+		// translation reads these, so they stay untyped
+		_, typed := lookupTypedValue(span.Values, "http.status_code")
+		assert.False(t, typed)
+		assert.Equal(t, int64(200), span.Attributes["http.status_code"])
+		_, typed = lookupTypedValue(span.Values, "sampleRate")
+		assert.False(t, typed)
+
+		expectedMsgpack, err := expected.Batches[0].MarshalMsgpack()
+		require.NoError(t, err)
+		actualMsgpack, err := result.Batches[0].MarshalMsgpack()
+		require.NoError(t, err)
+		assert.Equal(t, len(expectedMsgpack), len(actualMsgpack))
+	}
+}
+
+func TestTruncatedAttributesRecordTruncatedBytes(t *testing.T) {
+	long := strings.Repeat("a", fieldSizeMax+10)
+	req := buildTestTraceRequest(nil, &trace.Span{
+		TraceId: test.RandomBytes(16),
+		SpanId:  test.RandomBytes(8),
+		Attributes: []*common.KeyValue{{Key: "long", Value: &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+			Values: []*common.AnyValue{{Value: &common.AnyValue_StringValue{StringValue: long}}},
+		}}}}},
+	})
+	for _, opts := range []TranslateOptions{{}, {TypedAttributeValues: true}} {
+		result, err := TranslateTraceRequestWithOptions(req, testRequestInfo(), opts)
+		require.NoError(t, err)
+		ev := result.Batches[0].Events[0]
+		value, _ := ev.Get("long")
+		assert.Len(t, value, fieldSizeMax)
+		// the encoded array adds brackets, quotes and a newline to the string
+		assert.Equal(t, 15, ev.Attributes["meta.truncated_bytes"])
+		assert.Equal(t, "long", ev.Attributes["meta.truncated_field"])
+	}
+}
+
+func TestTypedAttributeValuesLogs(t *testing.T) {
+	req := buildExportLogsServiceRequest(test.RandomBytes(16), test.RandomBytes(8), time.Now(), "my-service")
+	req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes = []*common.KeyValue{stringAttr("user.id", "123")}
+	result, err := TranslateLogsRequestWithOptions(req, testRequestInfo(), TranslateOptions{TypedAttributeValues: true})
+	require.NoError(t, err)
+
+	event := result.Batches[0].Events[0]
+	assert.Equal(t, []Attribute{{Key: "user.id", Value: StringValue("123")}}, event.Values)
+	value, ok := event.Get("user.id")
+	assert.True(t, ok)
+	assert.Equal(t, "123", value)
+}
+
+func TestAppendMsgpackTypedValue(t *testing.T) {
+	testCases := []struct {
+		value    Value
+		expected []byte
+	}{
+		{value: Value{}, expected: []byte{0xc0}},
+		{value: StringValue("a"), expected: []byte{0xa1, 'a'}},
+		{value: Int64Value(1), expected: []byte{0x01}},
+		{value: BoolValue(true), expected: []byte{0xc3}},
+		{value: BytesValue([]byte{0x01}), expected: []byte{0xc4, 0x01, 0x01}},
+		{value: SliceValue([]Value{BoolValue(false)}), expected: []byte{0x91, 0xc2}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, appendMsgpackTypedValue(nil, tc.value))
+	}
+	assert.Equal(t, appendMsgpackValue(nil, 1.5), appendMsgpackTypedValue(nil, Float64Value(1.5)))
+	assert.Equal(t, appendMsgpackValue(nil, "s"), appendMsgpackValue(nil, StringValue("s")))
+}
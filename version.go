@@ -1,5 +1,7 @@
 package husky
 
+// Version is the husky library version. It can be overridden at build time with
+// -ldflags "-X github.com/honeycombio/husky.Version=<version>".
 var (
 	Version string = "0.18.0"
 )